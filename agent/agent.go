@@ -19,6 +19,8 @@ type Agent struct {
 	outgoing chan wire.Msg
 	// The agent's version - injected by the main binary in cmd/agent/main.go
 	version string
+	// The labels the agent advertises to the coordinator
+	labels map[string]string
 	// The list of commands running on the agent
 	pendingCommands []*pendingCommand
 	// The lock for pendingCommands
@@ -35,11 +37,12 @@ type pendingCommand struct {
 
 // NewAgent creates a new instance of the Agent class. Requires injection of the
 // version number from the main binary, as well as the coordinator's host and
-// port to connect to
+// port to connect to and the labels to advertise
 func NewAgent(
 	version string,
 	coordinatorHost string,
 	coordinatorPort int,
+	labels map[string]string,
 ) (*Agent, error) {
 	// Create new wire client to connect to the coordinator
 	clt, err := wire.NewClient(coordinatorHost, coordinatorPort)
@@ -50,6 +53,7 @@ func NewAgent(
 	// Create a new instance of the Agent
 	a := &Agent{
 		version:             version,
+		labels:              labels,
 		conn:                clt,
 		processingQueue:     make(chan wire.Msg, 100),
 		outgoing:            make(chan wire.Msg, 100),
@@ -100,5 +104,6 @@ func (a *Agent) composeHello() *wire.HelloMsg {
 	return &wire.HelloMsg{
 		SystemInfo:   GetSystemInfo(),
 		AgentVersion: a.version,
+		Labels:       a.labels,
 	}
 }
@@ -165,6 +165,7 @@ func (a *Agent) handleExecuteCommand(
 		done <- true // performance profiling (generic)
 		done <- true // performance profiling (perf)
 		done <- true // network recording
+		done <- true // live samples
 	}()
 	netFile := ""
 	if msg.RecordNetworkTraffic {
@@ -207,6 +208,17 @@ func (a *Agent) handleExecuteCommand(
 		)
 	}
 
+	if len(msg.LiveSampleFiles) > 0 {
+		// Report the samples the command writes to the controller while it
+		// is running
+		go a.reportLiveSamples(
+			ret.CommandID,
+			cmd.Dir,
+			msg.LiveSampleFiles,
+			done,
+		)
+	}
+
 	perfWg := sync.WaitGroup{}
 	if msg.PerfProfile {
 		// If we want to profile the performance using perf traces, then
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+	"github.com/mit-dci/opencbdc-tctl/wire"
+)
+
+// liveSamplesInterval is how often the agent reports the samples appended to
+// the live sample files of a command
+const liveSamplesInterval = 5 * time.Second
+
+// maxLiveSamplesRead limits how much of a sample file is read per report, the
+// rest is read in the next one
+const maxLiveSamplesRead = 64 * 1024 * 1024
+
+// sampleTail keeps track of the part of a sample file that has been read
+type sampleTail struct {
+	path   string
+	offset int64
+	// The incomplete last line that was read, which is completed by the next
+	// read
+	partial []byte
+}
+
+// read adds the samples appended to the file since the previous read to the
+// windows of their second
+func (s *sampleTail) read(windows map[int64]*wire.SampleWindow) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// The command has not written any samples yet
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	_, err = f.Seek(s.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(f, maxLiveSamplesRead))
+	if err != nil {
+		return err
+	}
+	s.offset += int64(len(b))
+
+	b = append(s.partial, b...)
+	end := bytes.LastIndexByte(b, '\n')
+	if end < 0 {
+		s.partial = b
+		return nil
+	}
+	s.partial = append([]byte{}, b[end+1:]...)
+
+	for _, line := range bytes.Split(b[:end], []byte{'\n'}) {
+		fields := strings.Fields(string(line))
+		if len(fields) != 2 {
+			continue
+		}
+		ts, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		latency, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		sec := ts / int64(time.Second)
+		w, ok := windows[sec]
+		if !ok {
+			w = &wire.SampleWindow{
+				Second:           sec,
+				LatencyHistogram: map[int32]int64{},
+			}
+			windows[sec] = w
+		}
+		w.Count++
+		w.LatencySum += latency
+		w.LatencyHistogram[common.LatencyBucket(latency)]++
+	}
+	return nil
+}
+
+// reportLiveSamples tails the sample files of a command in dir and sends the
+// windows of the samples appended to them to the controller every
+// liveSamplesInterval, until the command is done
+func (a *Agent) reportLiveSamples(
+	commandID []byte,
+	dir string,
+	files []string,
+	done chan bool,
+) {
+	tails := []*sampleTail{}
+	for _, f := range files {
+		tails = append(tails, &sampleTail{path: filepath.Join(dir, f)})
+	}
+
+	for {
+		exit := false
+		select {
+		case <-done:
+			exit = true
+		case <-time.After(liveSamplesInterval):
+		}
+
+		windows := map[int64]*wire.SampleWindow{}
+		for _, t := range tails {
+			err := t.read(windows)
+			if err != nil {
+				logging.Warnf("Could not read samples from %s: %v", t.path, err)
+			}
+		}
+		if len(windows) > 0 {
+			msg := &wire.LiveSamplesMsg{
+				CommandID: commandID,
+				Windows:   []wire.SampleWindow{},
+			}
+			for _, w := range windows {
+				msg.Windows = append(msg.Windows, *w)
+			}
+			sort.Slice(msg.Windows, func(i, j int) bool {
+				return msg.Windows[i].Second < msg.Windows[j].Second
+			})
+			a.outgoing <- msg
+		}
+
+		// The samples the command wrote before it exited have been reported
+		// above
+		if exit {
+			return
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package client implements a Go client for the HTTP API of the test
+// controller's coordinator. It authenticates either with a client
+// certificate, like the browser frontend does, or with an API token.
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Options configure how the client connects and authenticates to the
+// coordinator
+type Options struct {
+	// The base URL of the authenticated HTTPS endpoint, for instance
+	// https://tctl.example.com
+	URL string
+	// A client certificate and its private key, in PEM format
+	CertFile string
+	KeyFile  string
+	// An API token to use in stead of a client certificate
+	Token string
+	// A PEM file with the CA that signed the coordinator's certificate, for
+	// coordinators that use a self-signed certificate
+	CAFile string
+	// Skip verification of the coordinator's certificate
+	Insecure bool
+	// How long to wait for the coordinator to respond to a request. Reading
+	// the response (for instance downloading outputs) is not limited.
+	// Defaults to one minute
+	Timeout time.Duration
+}
+
+// Client talks to the HTTP API of the coordinator
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// StatusError is returned when the coordinator responds with an unexpected
+// HTTP status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("coordinator returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if err indicates the requested object does not
+// exist
+func IsNotFound(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusNotFound
+}
+
+// New creates a client with the given options
+func New(opts Options) (*Client, error) {
+	if opts.URL == "" {
+		return nil, errors.New("the coordinator URL is required")
+	}
+	if opts.Token == "" && opts.CertFile == "" {
+		return nil, errors.New("either a client certificate or token is required")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.Insecure,
+	}
+	if opts.CertFile != "" {
+		keyFile := opts.KeyFile
+		if keyFile == "" {
+			keyFile = opts.CertFile
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if opts.CAFile != "" {
+		caPEM, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+
+	return &Client{
+		baseURL: strings.TrimSuffix(opts.URL, "/"),
+		token:   opts.Token,
+		http: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig:       tlsConfig,
+				ResponseHeaderTimeout: timeout,
+			},
+		},
+	}, nil
+}
+
+// do performs a request against the API and returns the response if it has
+// a 2xx status. The caller is responsible for closing the response body
+func (c *Client) do(
+	method, path string,
+	query url.Values,
+	body io.Reader,
+	contentType string,
+) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+	return resp, nil
+}
+
+// doJSON performs a request with an optional JSON body and decodes the JSON
+// response into out, if out is not nil
+func (c *Client) doJSON(
+	method, path string,
+	query url.Values,
+	in interface{},
+	out interface{},
+) error {
+	var body io.Reader
+	contentType := ""
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = strings.NewReader(string(b))
+		contentType = "application/json"
+	}
+	resp, err := c.do(method, path, query, body, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// download performs a GET request and copies the response body to w
+func (c *Client) download(path string, w io.Writer) error {
+	resp, err := c.do("GET", path, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"gopkg.in/yaml.v2"
+)
+
+// LoadTestRunSpec reads a test run specification from a JSON or YAML file.
+// The format is determined by the file's extension. The YAML keys are the
+// same as the JSON ones
+func LoadTestRunSpec(path string) (*common.TestRun, error) {
+	b, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tr := common.TestRun{}
+	err = json.Unmarshal(b, &tr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	return &tr, nil
+}
+
+// LoadOverrides reads a partial test run specification, as accepted by
+// CloneTestRun, from a JSON or YAML file
+func LoadOverrides(path string) (map[string]interface{}, error) {
+	b, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides := map[string]interface{}{}
+	err = json.Unmarshal(b, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	return overrides, nil
+}
+
+// LoadDAG reads a set of test runs that depend on each other, as accepted by
+// ScheduleDAG, from a JSON or YAML file. The file holds a list of runs
+func LoadDAG(path string) ([]DAGRun, error) {
+	b, err := readSpecFile(path)
+	if err != nil {
+		return nil, err
+	}
+	runs := []DAGRun{}
+	err = json.Unmarshal(b, &runs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	return runs, nil
+}
+
+// readSpecFile reads a JSON or YAML file and returns its contents as JSON
+func readSpecFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		b, err = yamlToJSON(b)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+		}
+	}
+	return b, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, such that it can be decoded
+// using the json tags of the target type
+func yamlToJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	err := yaml.Unmarshal(b, &v)
+	if err != nil {
+		return nil, err
+	}
+	v, err = jsonCompatible(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// jsonCompatible converts the map[interface{}]interface{} values the YAML
+// decoder produces to map[string]interface{}
+func jsonCompatible(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			ks, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", k)
+			}
+			conv, err := jsonCompatible(val)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = conv
+		}
+		return m, nil
+	case []interface{}:
+		for i := range t {
+			conv, err := jsonCompatible(t[i])
+			if err != nil {
+				return nil, err
+			}
+			t[i] = conv
+		}
+		return t, nil
+	}
+	return v, nil
+}
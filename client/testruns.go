@@ -0,0 +1,283 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// TestRunSummary is the subset of test run properties returned when listing
+// test runs
+type TestRunSummary struct {
+	ID                  string               `json:"id"`
+	CreatedByThumbprint string               `json:"createdByuserThumbprint"`
+	Created             time.Time            `json:"created"`
+	Started             time.Time            `json:"started"`
+	Completed           time.Time            `json:"completed"`
+	Status              common.TestRunStatus `json:"status"`
+	Architecture        string               `json:"architectureID"`
+	SweepID             string               `json:"sweepID"`
+	Sweep               string               `json:"sweep"`
+	ParentID            string               `json:"parentID"`
+	Details             string               `json:"details"`
+	ScheduleReason      string               `json:"scheduleReason"`
+	AvgThroughput       float64              `json:"avgThroughput"`
+	TailLatency         float64              `json:"tailLatency"`
+}
+
+// ListFilter selects the test runs to list. Empty fields match all test runs
+type ListFilter struct {
+	Status    common.TestRunStatus
+	SweepID   string
+	CreatedBy string
+	Since     time.Time
+}
+
+// ScheduleResult holds the IDs of the test runs that were scheduled
+type ScheduleResult struct {
+	// The ID of the sweep the test runs belong to, if the scheduled test run
+	// was a sweep or was repeated
+	SweepID    string   `json:"sweepID"`
+	TestRunIDs []string `json:"testRunIDs"`
+}
+
+// IsFinished returns true if a test run with the given status will not change
+// status anymore
+func IsFinished(status common.TestRunStatus) bool {
+	switch status {
+	case common.TestRunStatusQueued, common.TestRunStatusRunning:
+		return false
+	}
+	return true
+}
+
+// ScheduleTestRun schedules the test run (or sweep) described by tr
+func (c *Client) ScheduleTestRun(tr *common.TestRun) (*ScheduleResult, error) {
+	res := ScheduleResult{}
+	err := c.doJSON("POST", "/api/testruns/schedule", nil, tr, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ValidateTestRun validates the test run (or sweep) described by tr with the
+// checks that are applied when scheduling it, and returns the errors found
+func (c *Client) ValidateTestRun(
+	tr *common.TestRun,
+) ([]*common.ValidationError, error) {
+	res := struct {
+		Errors []*common.ValidationError `json:"errors"`
+	}{}
+	err := c.doJSON("POST", "/api/testruns/validate", nil, tr, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Errors, nil
+}
+
+// DAGRun is a test run (or sweep) in a set of test runs that depend on each
+// other. The testRunID of a dependency is either the name of another DAGRun
+// in the same set, or the ID of an existing test run
+type DAGRun struct {
+	Name      string                 `json:"name"`
+	Spec      *common.TestRun        `json:"spec"`
+	DependsOn []common.RunDependency `json:"dependsOn"`
+}
+
+// ScheduleDAG schedules a set of test runs that depend on each other, and
+// returns the test runs scheduled for each of them by name
+func (c *Client) ScheduleDAG(
+	runs []DAGRun,
+) (map[string]ScheduleResult, error) {
+	res := struct {
+		Runs map[string]ScheduleResult `json:"runs"`
+	}{}
+	err := c.doJSON(
+		"POST",
+		"/api/testruns/dag",
+		nil,
+		map[string]interface{}{"runs": runs},
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return res.Runs, nil
+}
+
+// CloneResult holds the IDs of the test runs scheduled by cloning a test run
+// and the fields in which they differ from it
+type CloneResult struct {
+	ScheduleResult
+	Diff common.TestRunDiff `json:"diff"`
+}
+
+// CloneTestRun schedules a copy of the test run with the given ID, in which
+// the fields in overrides (keyed by their JSON name) are replaced
+func (c *Client) CloneTestRun(
+	id string,
+	overrides map[string]interface{},
+) (*CloneResult, error) {
+	res := CloneResult{}
+	err := c.doJSON(
+		"POST",
+		fmt.Sprintf("/api/testruns/%s/clone", url.PathEscape(id)),
+		nil,
+		overrides,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListTestRuns returns the test runs that match the filter, newest first
+func (c *Client) ListTestRuns(filter ListFilter) ([]TestRunSummary, error) {
+	q := url.Values{}
+	if filter.Status != "" {
+		q.Set("status", string(filter.Status))
+	}
+	if filter.SweepID != "" {
+		q.Set("sweepID", filter.SweepID)
+	}
+	if filter.CreatedBy != "" {
+		q.Set("createdBy", filter.CreatedBy)
+	}
+	if !filter.Since.IsZero() {
+		q.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	res := []TestRunSummary{}
+	err := c.doJSON("GET", "/api/testruns", q, nil, &res)
+	return res, err
+}
+
+// GetTestRun returns the full details of a test run
+func (c *Client) GetTestRun(id string) (*common.TestRun, error) {
+	tr := common.TestRun{}
+	err := c.doJSON(
+		"GET",
+		fmt.Sprintf("/api/testruns/%s/details", url.PathEscape(id)),
+		nil,
+		nil,
+		&tr,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &tr, nil
+}
+
+// GetTestRunLog writes the log of a test run to w
+func (c *Client) GetTestRunLog(id string, w io.Writer) error {
+	return c.download(
+		fmt.Sprintf("/api/testruns/%s/log", url.PathEscape(id)),
+		w,
+	)
+}
+
+// GetTestRunLogFrom writes the part of the log of a test run after the given
+// byte offset to w
+func (c *Client) GetTestRunLogFrom(id string, offset int, w io.Writer) error {
+	return c.download(
+		fmt.Sprintf("/api/testruns/%s/log/%d", url.PathEscape(id), offset),
+		w,
+	)
+}
+
+// TerminateTestRun terminates a running test run
+func (c *Client) TerminateTestRun(id string) error {
+	return c.doJSON(
+		"PUT",
+		fmt.Sprintf("/api/testruns/%s/terminate", url.PathEscape(id)),
+		nil,
+		nil,
+		nil,
+	)
+}
+
+// GetResults returns the results of a completed test run
+func (c *Client) GetResults(id string) (*common.TestResult, error) {
+	res := common.TestResult{}
+	err := c.doJSON(
+		"GET",
+		fmt.Sprintf("/api/testruns/%s/results", url.PathEscape(id)),
+		nil,
+		nil,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DownloadOutputs writes the outputs of a test run, as a gzipped tarball, to w
+func (c *Client) DownloadOutputs(id string, w io.Writer) error {
+	return c.download(
+		fmt.Sprintf("/api/testruns/%s/outputs", url.PathEscape(id)),
+		w,
+	)
+}
+
+// SweepMatrixCSV writes the result matrix of a sweep in CSV format to w
+func (c *Client) SweepMatrixCSV(sweepID string, w io.Writer) error {
+	return c.download(
+		fmt.Sprintf("/api/testruns/sweepMatrixCsv/%s", url.PathEscape(sweepID)),
+		w,
+	)
+}
+
+// FollowTestRun polls the test run with the given interval until it is
+// finished. Every time its status, details or log change, onUpdate is called
+// with the test run and the lines that were added to the log since the
+// previous call. It returns the finished test run
+func (c *Client) FollowTestRun(
+	id string,
+	interval time.Duration,
+	onUpdate func(tr *common.TestRun, newLog string),
+) (*common.TestRun, error) {
+	logLen := 0
+	lastStatus := common.TestRunStatus("")
+	lastDetails := ""
+	for {
+		tr, err := c.GetTestRun(id)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only fetch the part of the log that was added since the previous
+		// poll
+		var log stringWriter
+		err = c.GetTestRunLogFrom(id, logLen, &log)
+		if err != nil {
+			return nil, err
+		}
+		newLog := string(log)
+		logLen += len(log)
+
+		if newLog != "" || tr.Status != lastStatus ||
+			tr.Details != lastDetails {
+			onUpdate(tr, newLog)
+			lastStatus = tr.Status
+			lastDetails = tr.Details
+		}
+
+		if IsFinished(tr.Status) {
+			return tr, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// stringWriter collects everything written to it
+type stringWriter []byte
+
+func (s *stringWriter) Write(p []byte) (int, error) {
+	*s = append(*s, p...)
+	return len(p), nil
+}
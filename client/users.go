@@ -0,0 +1,69 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+)
+
+// User is a user that is authorized to access the coordinator
+type User struct {
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Organization string `json:"org"`
+	Thumbprint   string `json:"thumbPrint"`
+	Role         string `json:"role"`
+}
+
+// ListUsers returns all authorized users
+func (c *Client) ListUsers() ([]User, error) {
+	res := []User{}
+	err := c.doJSON("GET", "/api/users", nil, nil, &res)
+	return res, err
+}
+
+// AddUser authorizes the user with the given certificate (in PEM format) and
+// role. If role is empty, the coordinator's default role is assigned
+func (c *Client) AddUser(certPEM []byte, role string) error {
+	q := url.Values{}
+	if role != "" {
+		q.Set("role", role)
+	}
+	resp, err := c.do(
+		"POST",
+		"/api/users",
+		q,
+		bytes.NewReader(certPEM),
+		"application/x-pem-file",
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
+
+// SetUserRole changes the role of the user with the given thumbprint
+func (c *Client) SetUserRole(thumbprint, role string) error {
+	return c.doJSON(
+		"PUT",
+		fmt.Sprintf("/api/users/%s", url.PathEscape(thumbprint)),
+		nil,
+		map[string]string{"role": role},
+		nil,
+	)
+}
+
+// DeleteUser revokes access for the user with the given thumbprint
+func (c *Client) DeleteUser(thumbprint string) error {
+	return c.doJSON(
+		"DELETE",
+		fmt.Sprintf("/api/users/%s", url.PathEscape(thumbprint)),
+		nil,
+		nil,
+		nil,
+	)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/mit-dci/opencbdc-tctl/agent"
 	"github.com/mit-dci/opencbdc-tctl/agent/scripts"
+	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
@@ -21,6 +22,10 @@ var BuildDate string
 
 func main() {
 	logging.SetLogLevel(int(logging.LogLevelInfo))
+	// LOG_FORMAT=json makes the log lines machine readable for log shippers
+	if os.Getenv("LOG_FORMAT") == "json" {
+		logging.SetJSONOutput(true)
+	}
 	version := fmt.Sprintf("%s-%s", time.Now().Format("20060102"), "dev")
 	if GitCommit != "" && BuildDate != "" {
 		version = fmt.Sprintf("%s-%s", BuildDate, GitCommit[:7])
@@ -47,8 +52,15 @@ func main() {
 	// or the environment
 	host := ""
 	port := 0
+	labelsFlag := ""
 	flag.StringVar(&host, "host", "", "Coordinator host to connect to")
 	flag.IntVar(&port, "port", 0, "Coordinator port to connect to")
+	flag.StringVar(
+		&labelsFlag,
+		"labels",
+		"",
+		"Labels to advertise to the coordinator (key=value,key2=value2)",
+	)
 	flag.Parse()
 	if host == "" {
 		host = os.Getenv("COORDINATOR_HOST")
@@ -57,6 +69,14 @@ func main() {
 	if port == 0 {
 		port = 8000
 	}
+	if labelsFlag == "" {
+		labelsFlag = os.Getenv("AGENT_LABELS")
+	}
+	labels, err := common.ParseLabels(labelsFlag)
+	if err != nil {
+		logging.Errorf("Invalid labels: [%s], exiting...\n", err.Error())
+		os.Exit(128)
+	}
 	if os.Getenv("S3_INTERFACE_ENDPOINT") == "" {
 		logging.Infof(
 			"S3_INTERFACE_ENDPOINT not set, S3 will default to public endpoints",
@@ -76,7 +96,7 @@ func main() {
 
 	// Connect the agent to the coordinator
 	logging.Infof("Connecting to server %s on port %d...\n", host, port)
-	a, err := agent.NewAgent(version, host, port)
+	a, err := agent.NewAgent(version, host, port, labels)
 	if err != nil {
 		logging.Errorf("Failed to connect: [%s], exiting...\n", err.Error())
 		os.Exit(129)
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/agents"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/bisect"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/http"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/schedules"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/scripts"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/templates"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
 	"github.com/mit-dci/opencbdc-tctl/logging"
 )
@@ -22,7 +27,21 @@ var BuildDate string
 
 func main() {
 	logging.SetLogLevel(int(logging.LogLevelInfo))
-	coordinatorPort, httpPort := getPorts()
+
+	configPath := ""
+	flag.StringVar(
+		&configPath,
+		"config",
+		os.Getenv("CONFIG_FILE"),
+		"Path to the coordinator configuration file",
+	)
+	flag.Parse()
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		logging.Fatalf("Unable to load configuration: %v", err)
+	}
+	cfg.ApplyLogging()
 
 	ev := make(chan coordinator.Event, 10000)
 
@@ -39,7 +58,7 @@ func main() {
 
 	logging.Infof("Creating coordinator")
 
-	c, err := coordinator.NewCoordinator(ev, coordinatorPort)
+	c, err := coordinator.NewCoordinator(ev, cfg.CoordinatorPort)
 	if err != nil {
 		panic(err)
 	}
@@ -52,21 +71,51 @@ func main() {
 
 	logging.Infof("Creating sources manager")
 
-	s := sources.NewSourcesManager()
+	s := sources.NewSourcesManager(cfg)
 
 	logging.Infof("Creating agents manager")
 
-	am, err := agents.NewAgentsManager(c, s, ev)
+	am, err := agents.NewAgentsManager(c, s, ev, cfg)
 	if err != nil {
 		panic(err)
 	}
 
 	logging.Infof("Creating AWS manager")
 
-	awsm := awsmgr.NewAwsManager()
+	awsm := awsmgr.NewAwsManager(cfg)
+
+	// The AWS manager is also used for S3 and the shard preseeds, so it is
+	// created regardless of the provider the test agents run on
+	var prov provider.Provider = awsm
+	switch cfg.Provider.Type {
+	case "pool":
+		logging.Infof(
+			"Using the static host pool of %d hosts",
+			len(cfg.Provider.Pool),
+		)
+		prov = provider.NewPoolProvider(c, cfg.Provider.Pool)
+	case "docker":
+		logging.Infof(
+			"Using agent containers on network %s",
+			cfg.Provider.Docker.Network,
+		)
+		prov = provider.NewDockerProvider(
+			cfg.Provider.Docker,
+			cfg.CoordinatorPort,
+		)
+	}
 
 	logging.Infof("Creating TestRun manager")
-	tr, err := testruns.NewTestRunManager(c, am, s, ev, awsm, GitCommit)
+	tr, err := testruns.NewTestRunManager(
+		c,
+		am,
+		s,
+		ev,
+		awsm,
+		prov,
+		cfg,
+		GitCommit,
+	)
 	if err != nil {
 		panic(err)
 	}
@@ -93,14 +142,34 @@ func main() {
 
 	logging.Infof("Creating HTTP Server")
 
+	tm, err := templates.NewTemplateManager()
+	if err != nil {
+		panic(err)
+	}
+
+	sm, err := schedules.NewScheduleManager(tr, tm, s)
+	if err != nil {
+		panic(err)
+	}
+	go sm.Run()
+
+	bm, err := bisect.NewBisectManager(tr, s)
+	if err != nil {
+		panic(err)
+	}
+	go bm.Run()
+
 	chttp, err := http.NewHttpServer(
 		c,
 		s,
 		am,
 		tr,
+		tm,
+		sm,
+		bm,
 		ev,
 		awsm,
-		httpPort,
+		cfg,
 		fmt.Sprintf("%s-%s", BuildDate, GitCommit[:7]),
 	)
 	if err != nil {
@@ -122,17 +191,3 @@ func main() {
 		panic(err)
 	}
 }
-
-func getPorts() (int, int) {
-	coordinatorPort, _ := strconv.Atoi(os.Getenv("PORT"))
-	if coordinatorPort == 0 {
-		coordinatorPort = 8000
-	}
-
-	httpPort, _ := strconv.Atoi(os.Getenv("PORT"))
-	if httpPort == 0 {
-		httpPort = 8080
-	}
-
-	return coordinatorPort, httpPort
-}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/client"
+)
+
+const usage = `tctl is a command-line client for the OpenCBDC test controller.
+
+Usage:
+  tctl [global flags] <command> [flags] [arguments]
+
+Commands:
+  schedule -f <spec.json|spec.yaml> [-follow]  Schedule a test run or sweep
+  validate -f <spec.json|spec.yaml>            Check a test run or sweep for errors
+  pipeline -f <runs.json|runs.yaml>            Schedule runs that depend on each other
+  clone [-f file] [-set field=value]... [-follow] <runID>
+                                               Rerun a test run with changes
+  list [-status S] [-sweep ID] [-user T] [-since D]  List test runs
+  status <runID>                               Show the details of a test run
+  follow <runID>                               Follow a test run's status and log
+  terminate <runID>...                         Terminate test runs
+  results [-o file] <runID>                    Download the results of a test run
+  outputs [-o file] <runID>                    Download the outputs of a test run
+  sweep-csv [-o file] <sweepID>                Download the result matrix of a sweep
+  users list                                   List the authorized users
+  users add [-role R] <cert.crt>               Authorize a user
+  users role <thumbprint> <role>               Change the role of a user
+  users delete <thumbprint>                    Revoke access for a user
+
+Global flags (defaults are read from the environment variables in brackets):
+`
+
+func main() {
+	opts := client.Options{}
+	insecure, _ := strconv.ParseBool(os.Getenv("TCTL_INSECURE"))
+	flag.StringVar(
+		&opts.URL,
+		"url",
+		os.Getenv("TCTL_URL"),
+		"URL of the coordinator [TCTL_URL]",
+	)
+	flag.StringVar(
+		&opts.CertFile,
+		"cert",
+		os.Getenv("TCTL_CERT"),
+		"Client certificate file [TCTL_CERT]",
+	)
+	flag.StringVar(
+		&opts.KeyFile,
+		"key",
+		os.Getenv("TCTL_KEY"),
+		"Private key of the client certificate [TCTL_KEY]",
+	)
+	flag.StringVar(
+		&opts.Token,
+		"token",
+		os.Getenv("TCTL_TOKEN"),
+		"API token to use instead of a client certificate [TCTL_TOKEN]",
+	)
+	flag.StringVar(
+		&opts.CAFile,
+		"ca",
+		os.Getenv("TCTL_CA"),
+		"CA certificate of the coordinator [TCTL_CA]",
+	)
+	flag.BoolVar(
+		&opts.Insecure,
+		"insecure",
+		insecure,
+		"Skip verification of the coordinator's certificate [TCTL_INSECURE]",
+	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := client.New(opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "schedule":
+		err = scheduleCmd(c, args)
+	case "validate":
+		err = validateCmd(c, args)
+	case "pipeline":
+		err = pipelineCmd(c, args)
+	case "clone":
+		err = cloneCmd(c, args)
+	case "list":
+		err = listCmd(c, args)
+	case "status":
+		err = statusCmd(c, args)
+	case "follow":
+		err = followCmd(c, args)
+	case "terminate":
+		err = terminateCmd(c, args)
+	case "results":
+		err = resultsCmd(c, args)
+	case "outputs":
+		err = outputsCmd(c, args)
+	case "sweep-csv":
+		err = sweepCsvCmd(c, args)
+	case "users":
+		err = usersCmd(c, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "tctl: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// outputFile returns the writer for the -o flag of a command, which defaults
+// to stdout
+func outputFile(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// singleArg parses the flags of a subcommand and returns its only positional
+// argument
+func singleArg(fs *flag.FlagSet, args []string, name string) (string, error) {
+	err := fs.Parse(args)
+	if err != nil {
+		return "", err
+	}
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("%s expects exactly one %s", fs.Name(), name)
+	}
+	return fs.Arg(0), nil
+}
+
+// formatTime formats a timestamp for display, leaving unset timestamps blank
+func formatTime(t time.Time) string {
+	if t.IsZero() || t.Year() <= 1 {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
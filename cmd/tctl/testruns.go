@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/client"
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+const followInterval = 5 * time.Second
+
+func scheduleCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("schedule", flag.ExitOnError)
+	specFile := fs.String("f", "", "JSON or YAML file with the test run spec")
+	followRun := fs.Bool("follow", false, "Follow the (first) scheduled run")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *specFile == "" {
+		return errors.New("schedule requires a spec file (-f)")
+	}
+
+	tr, err := client.LoadTestRunSpec(*specFile)
+	if err != nil {
+		return err
+	}
+	res, err := c.ScheduleTestRun(tr)
+	if err != nil {
+		return err
+	}
+	if res.SweepID != "" {
+		fmt.Printf("Sweep %s\n", res.SweepID)
+	}
+	for _, id := range res.TestRunIDs {
+		fmt.Printf("Scheduled %s\n", id)
+	}
+	if *followRun && len(res.TestRunIDs) > 0 {
+		return follow(c, res.TestRunIDs[0])
+	}
+	return nil
+}
+
+func validateCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	specFile := fs.String("f", "", "JSON or YAML file with the test run spec")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *specFile == "" {
+		return errors.New("validate requires a spec file (-f)")
+	}
+
+	tr, err := client.LoadTestRunSpec(*specFile)
+	if err != nil {
+		return err
+	}
+	errs, err := c.ValidateTestRun(tr)
+	if err != nil {
+		return err
+	}
+	for _, e := range errs {
+		field := e.Field
+		if field == "" {
+			field = "(test run)"
+		}
+		fmt.Printf("%s: %s (%s)\n", field, e.Message, e.Code)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d error(s) in %s", len(errs), *specFile)
+	}
+	fmt.Printf("%s is valid\n", *specFile)
+	return nil
+}
+
+func pipelineCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("pipeline", flag.ExitOnError)
+	dagFile := fs.String(
+		"f",
+		"",
+		"JSON or YAML file with the list of runs and their dependencies",
+	)
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *dagFile == "" {
+		return errors.New("pipeline requires a file (-f)")
+	}
+
+	runs, err := client.LoadDAG(*dagFile)
+	if err != nil {
+		return err
+	}
+	res, err := c.ScheduleDAG(runs)
+	if err != nil {
+		return err
+	}
+	for _, r := range runs {
+		for _, id := range res[r.Name].TestRunIDs {
+			fmt.Printf("Scheduled %s: %s\n", r.Name, id)
+		}
+	}
+	return nil
+}
+
+// setFlags collects the repeated -set field=value flags of the clone command
+type setFlags map[string]interface{}
+
+func (s setFlags) String() string {
+	return fmt.Sprint(map[string]interface{}(s))
+}
+
+// Set parses a field=value pair. Values that are valid JSON (numbers,
+// booleans, objects, etc.) are passed as such, others as strings
+func (s setFlags) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected field=value, got %s", v)
+	}
+	var val interface{}
+	if json.Unmarshal([]byte(parts[1]), &val) != nil {
+		val = parts[1]
+	}
+	s[parts[0]] = val
+	return nil
+}
+
+func cloneCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("clone", flag.ExitOnError)
+	overridesFile := fs.String(
+		"f",
+		"",
+		"JSON or YAML file with the fields to change",
+	)
+	set := setFlags{}
+	fs.Var(set, "set", "Field to change, as field=value (repeatable)")
+	followRun := fs.Bool("follow", false, "Follow the (first) scheduled run")
+	id, err := singleArg(fs, args, "test run ID")
+	if err != nil {
+		return err
+	}
+
+	overrides := map[string]interface{}{}
+	if *overridesFile != "" {
+		overrides, err = client.LoadOverrides(*overridesFile)
+		if err != nil {
+			return err
+		}
+	}
+	for k, v := range set {
+		overrides[k] = v
+	}
+
+	res, err := c.CloneTestRun(id, overrides)
+	if err != nil {
+		return err
+	}
+	for _, ch := range res.Diff {
+		fmt.Printf("%s: %s -> %s\n", ch.Field, ch.From, ch.To)
+	}
+	if res.SweepID != "" {
+		fmt.Printf("Sweep %s\n", res.SweepID)
+	}
+	for _, id := range res.TestRunIDs {
+		fmt.Printf("Scheduled %s\n", id)
+	}
+	if *followRun && len(res.TestRunIDs) > 0 {
+		return follow(c, res.TestRunIDs[0])
+	}
+	return nil
+}
+
+func listCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	status := fs.String("status", "", "Only list runs with this status")
+	sweep := fs.String("sweep", "", "Only list runs in this sweep")
+	user := fs.String("user", "", "Only list runs created by this thumbprint")
+	since := fs.Duration(
+		"since",
+		0,
+		"Only list runs created in this period (e.g. 24h)",
+	)
+	asJSON := fs.Bool("json", false, "Print the list as JSON")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	filter := client.ListFilter{
+		Status:    common.TestRunStatus(*status),
+		SweepID:   *sweep,
+		CreatedBy: *user,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+	runs, err := c.ListTestRuns(filter)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(runs)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tCREATED\tCOMPLETED\tSWEEP\tTHROUGHPUT\tDETAILS")
+	for _, r := range runs {
+		details := r.Details
+		if r.Status == common.TestRunStatusQueued && r.ScheduleReason != "" {
+			details = r.ScheduleReason
+		}
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%s\t%.2f\t%s\n",
+			r.ID,
+			r.Status,
+			formatTime(r.Created),
+			formatTime(r.Completed),
+			r.SweepID,
+			r.AvgThroughput,
+			details,
+		)
+	}
+	return tw.Flush()
+}
+
+func statusCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	id, err := singleArg(fs, args, "test run ID")
+	if err != nil {
+		return err
+	}
+	tr, err := c.GetTestRun(id)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tr)
+}
+
+func followCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	id, err := singleArg(fs, args, "test run ID")
+	if err != nil {
+		return err
+	}
+	return follow(c, id)
+}
+
+// follow prints the log and status changes of a test run until it finishes,
+// and returns an error if it did not complete successfully
+func follow(c *client.Client, id string) error {
+	tr, err := c.FollowTestRun(
+		id,
+		followInterval,
+		func(tr *common.TestRun, newLog string) {
+			fmt.Print(newLog)
+			fmt.Fprintf(os.Stderr, "[%s] %s\n", tr.Status, tr.Details)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if tr.Status != common.TestRunStatusCompleted {
+		return fmt.Errorf("test run %s finished with status %s", id, tr.Status)
+	}
+	return nil
+}
+
+func terminateCmd(c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return errors.New("terminate expects one or more test run IDs")
+	}
+	for _, id := range args {
+		err := c.TerminateTestRun(id)
+		if err != nil {
+			return fmt.Errorf("unable to terminate %s: %v", id, err)
+		}
+		fmt.Printf("Terminated %s\n", id)
+	}
+	return nil
+}
+
+func resultsCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("results", flag.ExitOnError)
+	out := fs.String("o", "", "File to write the results to (default stdout)")
+	id, err := singleArg(fs, args, "test run ID")
+	if err != nil {
+		return err
+	}
+	res, err := c.GetResults(id)
+	if err != nil {
+		return err
+	}
+	w, err := outputFile(*out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+func outputsCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("outputs", flag.ExitOnError)
+	out := fs.String("o", "", "File to write the tarball to")
+	id, err := singleArg(fs, args, "test run ID")
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("testrun-output-%s.tar.gz", id)
+	}
+	w, err := outputFile(*out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return c.DownloadOutputs(id, w)
+}
+
+func sweepCsvCmd(c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("sweep-csv", flag.ExitOnError)
+	out := fs.String("o", "", "File to write the CSV to (default stdout)")
+	id, err := singleArg(fs, args, "sweep ID")
+	if err != nil {
+		return err
+	}
+	w, err := outputFile(*out)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return c.SweepMatrixCSV(id, w)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mit-dci/opencbdc-tctl/client"
+)
+
+func usersCmd(c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return errors.New("users expects a subcommand: list, add, role or delete")
+	}
+	switch args[0] {
+	case "list":
+		users, err := c.ListUsers()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "THUMBPRINT\tNAME\tEMAIL\tORGANIZATION\tROLE")
+		for _, u := range users {
+			fmt.Fprintf(
+				tw,
+				"%s\t%s\t%s\t%s\t%s\n",
+				u.Thumbprint,
+				u.Name,
+				u.Email,
+				u.Organization,
+				u.Role,
+			)
+		}
+		return tw.Flush()
+	case "add":
+		fs := flag.NewFlagSet("users add", flag.ExitOnError)
+		role := fs.String("role", "", "Role of the new user")
+		certFile, err := singleArg(fs, args[1:], "certificate file")
+		if err != nil {
+			return err
+		}
+		cert, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			return err
+		}
+		return c.AddUser(cert, *role)
+	case "role":
+		if len(args) != 3 {
+			return errors.New("users role expects a thumbprint and a role")
+		}
+		return c.SetUserRole(args[1], args[2])
+	case "delete":
+		if len(args) != 2 {
+			return errors.New("users delete expects a thumbprint")
+		}
+		return c.DeleteUser(args[1])
+	}
+	return fmt.Errorf("unknown users subcommand %s", args[0])
+}
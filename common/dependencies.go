@@ -0,0 +1,251 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DependencyCondition is the condition a test run must meet before the test
+// runs that depend on it can start
+type DependencyCondition string
+
+// DependencyCompleted is met once the dependency has finished, regardless of
+// its outcome
+const DependencyCompleted DependencyCondition = "completed"
+
+// DependencySucceeded is met once the dependency has completed successfully
+const DependencySucceeded DependencyCondition = "succeeded"
+
+// DependencyResult is met once the dependency has completed successfully and
+// its result meets the dependency's predicate
+const DependencyResult DependencyCondition = "result"
+
+// DependencyState is the outcome of checking a dependency
+type DependencyState int
+
+const (
+	// DependencyPending means the condition can still be met
+	DependencyPending DependencyState = iota
+	// DependencyMet means the condition is met
+	DependencyMet
+	// DependencyFailed means the condition can no longer be met
+	DependencyFailed
+)
+
+// RunDependency declares that a test run can only start once another test run
+// meets a condition
+type RunDependency struct {
+	// The ID of the test run that is depended on
+	TestRunID string              `json:"testRunID"`
+	Condition DependencyCondition `json:"condition"`
+	// The predicate the result must meet, for the result condition
+	Predicate *ResultPredicate `json:"predicate,omitempty"`
+}
+
+// Validate checks that the condition is known and that the predicate is set
+// (and valid) only for the result condition
+func (d RunDependency) Validate() error {
+	if d.TestRunID == "" {
+		return fmt.Errorf("dependency has no test run ID")
+	}
+	switch d.Condition {
+	case DependencyCompleted, DependencySucceeded:
+		if d.Predicate != nil {
+			return fmt.Errorf(
+				"dependency on %s: a predicate requires the %s condition",
+				d.TestRunID,
+				DependencyResult,
+			)
+		}
+	case DependencyResult:
+		if d.Predicate == nil {
+			return fmt.Errorf(
+				"dependency on %s: the %s condition requires a predicate",
+				d.TestRunID,
+				DependencyResult,
+			)
+		}
+		err := d.Predicate.Validate()
+		if err != nil {
+			return fmt.Errorf("dependency on %s: %v", d.TestRunID, err)
+		}
+	default:
+		return fmt.Errorf(
+			"dependency on %s: unknown condition %q",
+			d.TestRunID,
+			d.Condition,
+		)
+	}
+	return nil
+}
+
+// Check evaluates the dependency against the test run it depends on, and
+// returns whether it is met along with a description of the state
+func (d RunDependency) Check(dep *TestRun) (DependencyState, string) {
+	finished := dep.Status == TestRunStatusCompleted ||
+		dep.Status == TestRunStatusFailed ||
+		dep.Status == TestRunStatusAborted ||
+		dep.Status == TestRunStatusInterrupted ||
+		dep.Status == TestRunStatusCanceled ||
+		dep.Status == TestRunStatusGuardTripped
+	if !finished {
+		return DependencyPending, fmt.Sprintf(
+			"waiting for test run %s to finish",
+			dep.ID,
+		)
+	}
+	if d.Condition == DependencyCompleted {
+		return DependencyMet, ""
+	}
+	if dep.Status != TestRunStatusCompleted {
+		return DependencyFailed, fmt.Sprintf(
+			"test run %s did not succeed (%s)",
+			dep.ID,
+			dep.Status,
+		)
+	}
+	if d.Condition == DependencySucceeded {
+		return DependencyMet, ""
+	}
+
+	// The results are calculated before a test run completes, so a completed
+	// test run without them will not get any
+	if dep.Result == nil {
+		return DependencyFailed, fmt.Sprintf(
+			"test run %s completed without results",
+			dep.ID,
+		)
+	}
+	ok, err := d.Predicate.Evaluate(dep.Result)
+	if err != nil {
+		return DependencyFailed, fmt.Sprintf(
+			"unable to evaluate %s for test run %s: %v",
+			d.Predicate,
+			dep.ID,
+			err,
+		)
+	}
+	if !ok {
+		return DependencyFailed, fmt.Sprintf(
+			"result of test run %s does not meet %s",
+			dep.ID,
+			d.Predicate,
+		)
+	}
+	return DependencyMet, ""
+}
+
+// ResultPredicate compares a metric from a test result to a value
+type ResultPredicate struct {
+	// The JSON name of a numeric field of the TestResult (e.g. throughputAvg
+	// or latencyMax), or a percentile as latencyP<bucket> or
+	// throughputP<bucket> (e.g. latencyP99)
+	Metric string `json:"metric"`
+	// One of >, >=, <, <=, == or !=
+	Operator string  `json:"operator"`
+	Value    float64 `json:"value"`
+}
+
+func (p ResultPredicate) String() string {
+	return fmt.Sprintf("%s %s %g", p.Metric, p.Operator, p.Value)
+}
+
+// Validate checks that the metric and operator are known
+func (p ResultPredicate) Validate() error {
+	switch p.Operator {
+	case ">", ">=", "<", "<=", "==", "!=":
+	default:
+		return fmt.Errorf("unknown operator %q", p.Operator)
+	}
+	if _, ok := resultMetricField(p.Metric); ok {
+		return nil
+	}
+	if _, _, ok := resultPercentileMetric(p.Metric); ok {
+		return nil
+	}
+	return fmt.Errorf("unknown metric %q", p.Metric)
+}
+
+// Evaluate returns whether the test result meets the predicate
+func (p ResultPredicate) Evaluate(res *TestResult) (bool, error) {
+	val, err := p.MetricValue(res)
+	if err != nil {
+		return false, err
+	}
+	return p.Holds(val)
+}
+
+// Holds returns whether the value of the predicate's metric meets the
+// predicate
+func (p ResultPredicate) Holds(val float64) (bool, error) {
+	switch p.Operator {
+	case ">":
+		return val > p.Value, nil
+	case ">=":
+		return val >= p.Value, nil
+	case "<":
+		return val < p.Value, nil
+	case "<=":
+		return val <= p.Value, nil
+	case "==":
+		return val == p.Value, nil
+	case "!=":
+		return val != p.Value, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", p.Operator)
+}
+
+// MetricValue looks up the predicate's metric in the test result
+func (p ResultPredicate) MetricValue(res *TestResult) (float64, error) {
+	if i, ok := resultMetricField(p.Metric); ok {
+		return reflect.ValueOf(res).Elem().Field(i).Float(), nil
+	}
+	if latency, bucket, ok := resultPercentileMetric(p.Metric); ok {
+		percentiles := res.ThroughputPercentiles
+		if latency {
+			percentiles = res.LatencyPercentiles
+		}
+		for _, pc := range percentiles {
+			if pc.Bucket == bucket {
+				return pc.Value, nil
+			}
+		}
+		return 0, fmt.Errorf("result has no %s", p.Metric)
+	}
+	return 0, fmt.Errorf("unknown metric %q", p.Metric)
+}
+
+// resultMetricField returns the index of the float64 field of TestResult
+// with the given JSON name
+func resultMetricField(metric string) (int, bool) {
+	t := reflect.TypeOf(TestResult{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Float64 {
+			continue
+		}
+		if strings.Split(f.Tag.Get("json"), ",")[0] == metric {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// resultPercentileMetric parses percentile metrics like latencyP99 and
+// throughputP50
+func resultPercentileMetric(metric string) (bool, float64, bool) {
+	latency := strings.HasPrefix(metric, "latencyP")
+	if !latency && !strings.HasPrefix(metric, "throughputP") {
+		return false, 0, false
+	}
+	bucket, err := strconv.ParseFloat(
+		metric[strings.Index(metric, "P")+1:],
+		64,
+	)
+	if err != nil {
+		return false, 0, false
+	}
+	return latency, bucket, true
+}
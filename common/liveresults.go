@@ -0,0 +1,72 @@
+package common
+
+import (
+	"math"
+	"sort"
+)
+
+// The latency histograms of live results have buckets that grow by 10%,
+// starting at a microsecond, so percentiles are within 5% of the real value
+const (
+	latencyBucketGrowth = 1.1
+	latencyBucketStart  = 1000 // nanoseconds
+)
+
+// LatencyBucket returns the bucket of the live results latency histogram that
+// a latency in nanoseconds falls in
+func LatencyBucket(latency int64) int32 {
+	if latency <= latencyBucketStart {
+		return 0
+	}
+	return int32(math.Ceil(
+		math.Log(float64(latency)/latencyBucketStart) /
+			math.Log(latencyBucketGrowth),
+	))
+}
+
+// LatencyBucketValue returns the latency in seconds that represents the
+// bucket, which is halfway its bounds
+func LatencyBucketValue(bucket int32) float64 {
+	upper := latencyBucketStart * math.Pow(latencyBucketGrowth, float64(bucket))
+	if bucket == 0 {
+		return upper / 2 / 1e9
+	}
+	return (upper + upper/latencyBucketGrowth) / 2 / 1e9
+}
+
+// HistogramPercentile returns the p-th percentile of the latencies in a live
+// results histogram, in seconds
+func HistogramPercentile(histogram map[int32]int64, p float64) float64 {
+	buckets := []int32{}
+	total := int64(0)
+	for b, n := range histogram {
+		buckets = append(buckets, b)
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+	rank := int64(math.Ceil(p / 100 * float64(total)))
+	cum := int64(0)
+	for _, b := range buckets {
+		cum += histogram[b]
+		if cum >= rank {
+			return LatencyBucketValue(b)
+		}
+	}
+	return LatencyBucketValue(buckets[len(buckets)-1])
+}
+
+// LiveResultPoint is the throughput and latency of a test run in a second
+// while it is running, aggregated over the samples its load generators report
+type LiveResultPoint struct {
+	// Unix time of the second
+	Time       int64   `json:"time"`
+	Throughput float64 `json:"throughput"`
+	// Latencies in seconds
+	LatencyAvg  float64 `json:"latencyAvg"`
+	LatencyP50  float64 `json:"latencyP50"`
+	LatencyP99  float64 `json:"latencyP99"`
+	LatencyP999 float64 `json:"latencyP999"`
+}
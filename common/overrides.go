@@ -0,0 +1,199 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// testRunStateFields are the JSON names of the fields of a TestRun that hold
+// state accumulated while scheduling and executing it (ID, status,
+// timestamps, agents, commands, results, etc.) rather than its
+// configuration. Dependencies are state as well, since they refer to
+// specific other test runs
+var testRunStateFields = map[string]bool{
+	"id":                       true,
+	"createdByuserThumbprint":  true,
+	"created":                  true,
+	"started":                  true,
+	"completed":                true,
+	"status":                   true,
+	"details":                  true,
+	"scheduleReason":           true,
+	"dependencies":             true,
+	"sweepID":                  true,
+	"executedCommands":         true,
+	"testrunAgentData":         true,
+	"testrunAgentDataEnd":      true,
+	"performanceDataAvailable": true,
+	"controllerCommitHash":     true,
+	"result":                   true,
+	"seederHash":               true,
+	"AWSInstancesStopped":      true,
+	"parentID":                 true,
+	"scheduleID":               true,
+	"bisectID":                 true,
+	"budgetOverride":           true,
+	"parentDiff":               true,
+	"regression":               true,
+	"guardReason":              true,
+}
+
+// TestRunSpec returns a copy of the test run that only holds its
+// configuration: the fields that describe what to run. All state fields are
+// reset, and the agent IDs of the roles are set to -1 like GetTestRunCopy
+// does
+func TestRunSpec(tr *TestRun) (*TestRun, error) {
+	buf, _, err := GetTestRunCopy(tr)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(buf.Bytes(), &fields)
+	if err != nil {
+		return nil, err
+	}
+	for k := range testRunStateFields {
+		delete(fields, k)
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var spec TestRun
+	err = json.Unmarshal(b, &spec)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range spec.Roles {
+		r.AwsAgentInstanceId = ""
+	}
+	return &spec, nil
+}
+
+// testRunJSONFields returns the JSON names of all (serialized) fields of a
+// TestRun
+func testRunJSONFields() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(TestRun{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+// ApplyTestRunOverrides returns a copy of the test run in which the top-level
+// fields present in overrides (keyed by their JSON name) are replaced with
+// the given values. Nested values such as roles are replaced as a whole. An
+// error is returned for unknown fields, state fields that are not part of the
+// specification (see TestRunSpec) or values of the wrong type
+func ApplyTestRunOverrides(
+	tr *TestRun,
+	overrides map[string]json.RawMessage,
+) (*TestRun, error) {
+	buf, _, err := GetTestRunCopy(tr)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]json.RawMessage{}
+	err = json.Unmarshal(buf.Bytes(), &merged)
+	if err != nil {
+		return nil, err
+	}
+
+	known := testRunJSONFields()
+	for k, v := range overrides {
+		if !known[k] {
+			return nil, fmt.Errorf("unknown test run field %s", k)
+		}
+		if testRunStateFields[k] {
+			return nil, fmt.Errorf("test run field %s cannot be overridden", k)
+		}
+		merged[k] = v
+	}
+
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	var ret TestRun
+	err = json.Unmarshal(b, &ret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid override: %v", err)
+	}
+	return &ret, nil
+}
+
+// TestRunFieldChange describes a top-level test run field, by its JSON name,
+// that differs between two test run specifications
+type TestRunFieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
+}
+
+// TestRunDiff is the list of fields that changed between two test runs
+type TestRunDiff []TestRunFieldChange
+
+// DiffTestRunSpecs returns the configuration fields that differ between
+// test runs a and b, sorted by field name. State such as the IDs, status and
+// results of the test runs is not compared
+func DiffTestRunSpecs(a, b *TestRun) (TestRunDiff, error) {
+	fieldsA, err := testRunSpecFields(a)
+	if err != nil {
+		return nil, err
+	}
+	fieldsB, err := testRunSpecFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(fieldsA))
+	for k := range fieldsA {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := TestRunDiff{}
+	for _, k := range names {
+		if bytes.Equal(fieldsA[k], fieldsB[k]) {
+			continue
+		}
+		changes = append(changes, TestRunFieldChange{
+			Field: k,
+			From:  fieldsA[k],
+			To:    fieldsB[k],
+		})
+	}
+	return changes, nil
+}
+
+// testRunSpecFields returns the serialized configuration fields of the test
+// run, keyed by their JSON name
+func testRunSpecFields(tr *TestRun) (map[string]json.RawMessage, error) {
+	spec, err := TestRunSpec(tr)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(b, &fields)
+	return fields, err
+}
@@ -0,0 +1,43 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoleAntiAffinity spreads roles of the same kind over agents that have
+// different values for a label, for instance shard replicas over availability
+// zones
+type RoleAntiAffinity struct {
+	Label string `json:"label"`
+	// Only roles with the same group are spread, for instance the replicas of
+	// one shard. Empty spreads all roles of the same kind that have no group
+	Group string `json:"group"`
+}
+
+// RoleAffinity places a role on an agent that has the same value for a label
+// as the agent of another role, for instance a load generator in the same
+// region as its sentinel
+type RoleAffinity struct {
+	Label string     `json:"label"`
+	Role  SystemRole `json:"role"`
+	Index int        `json:"roleIdx"`
+}
+
+// ParseLabels parses labels in the form key=value,key2=value2 as they are
+// given to the agent on the command line or in the environment
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("label %q should be of the form key=value", kv)
+		}
+		labels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return labels, nil
+}
@@ -0,0 +1,259 @@
+package common
+
+import (
+	"math"
+	"time"
+)
+
+// RegressionStatus is the outcome of comparing a test run's result with the
+// results of the same configuration on an earlier commit
+type RegressionStatus string
+
+// RegressionNoBaseline means there are no completed test runs with the same
+// configuration on an earlier commit to compare with
+const RegressionNoBaseline RegressionStatus = "no-baseline"
+
+// RegressionPass means none of the metrics got worse by more than the
+// threshold
+const RegressionPass RegressionStatus = "pass"
+
+// RegressionSuspected means at least one metric got worse by more than the
+// threshold, but the difference is not statistically significant or there
+// are too few runs to test it
+const RegressionSuspected RegressionStatus = "suspected"
+
+// RegressionDetected means at least one metric got worse by more than the
+// threshold, and the difference is statistically significant
+const RegressionDetected RegressionStatus = "regression"
+
+// RegressionMetrics are the result metrics that are compared between commits,
+// in the notation of ResultPredicate, along with whether a higher value is
+// better
+var RegressionMetrics = []struct {
+	Metric         string
+	HigherIsBetter bool
+}{
+	{"throughputAvg", true},
+	{"latencyP50", false},
+	{"latencyP99", false},
+	{"latencyP99.9", false},
+}
+
+// RegressionVerdict records how a test run's result compares with the results
+// of the same configuration on the most recent earlier commit that has them
+type RegressionVerdict struct {
+	Status RegressionStatus `json:"status"`
+	// The earlier commit that was compared with, and its test runs
+	BaselineCommit string   `json:"baselineCommit"`
+	BaselineRunIDs []string `json:"baselineRunIDs"`
+	// The test runs on this run's commit that were compared, including this
+	// run itself
+	RunIDs []string `json:"runIDs"`
+	// The settings the verdict was made with
+	ThresholdPercent float64            `json:"thresholdPercent"`
+	Significance     float64            `json:"significance"`
+	Metrics          []RegressionMetric `json:"metrics"`
+	Checked          time.Time          `json:"checked"`
+}
+
+// RegressionMetric is the comparison of a single metric
+type RegressionMetric struct {
+	Metric         string  `json:"metric"`
+	HigherIsBetter bool    `json:"higherIsBetter"`
+	Baseline       float64 `json:"baseline"`
+	Current        float64 `json:"current"`
+	// The change from baseline to current, relative to the baseline. A
+	// negative change is an improvement for metrics where lower is better
+	ChangePercent float64 `json:"changePercent"`
+	// The one-sided p-value of Welch's t-test for the hypothesis that the
+	// metric got worse. Only set if Tested is true, which requires at least
+	// two runs on both commits
+	PValue float64 `json:"pValue"`
+	Tested bool    `json:"tested"`
+	// Whether the metric got worse by more than the threshold
+	Regressed bool `json:"regressed"`
+	// Whether the metric got worse with statistical significance
+	Significant bool `json:"significant"`
+}
+
+// CompareResults compares the results of test runs on a baseline commit with
+// those on the current commit, and returns the status and the comparison per
+// metric. Metrics that are missing from any of the results are skipped
+func CompareResults(
+	baseline, current []*TestResult,
+	thresholdPercent, significance float64,
+) (RegressionStatus, []RegressionMetric) {
+	if len(baseline) == 0 || len(current) == 0 {
+		return RegressionNoBaseline, []RegressionMetric{}
+	}
+	status := RegressionPass
+	metrics := []RegressionMetric{}
+	for _, rm := range RegressionMetrics {
+		b, ok := resultSamples(rm.Metric, baseline)
+		if !ok {
+			continue
+		}
+		c, ok := resultSamples(rm.Metric, current)
+		if !ok {
+			continue
+		}
+		m := RegressionMetric{
+			Metric:         rm.Metric,
+			HigherIsBetter: rm.HigherIsBetter,
+		}
+		var bVar, cVar float64
+		m.Baseline, bVar = meanVariance(b)
+		m.Current, cVar = meanVariance(c)
+		if m.Baseline != 0 {
+			m.ChangePercent = (m.Current - m.Baseline) / m.Baseline * 100
+		}
+		worse := m.ChangePercent
+		if rm.HigherIsBetter {
+			worse = -worse
+		}
+		m.Regressed = worse > thresholdPercent
+
+		if len(b) >= 2 && len(c) >= 2 {
+			// Test whether current is worse than baseline, which is the
+			// difference in the direction of worse being positive
+			diff := m.Current - m.Baseline
+			if rm.HigherIsBetter {
+				diff = -diff
+			}
+			m.PValue = welchPValue(diff, bVar, len(b), cVar, len(c))
+			m.Tested = true
+			m.Significant = m.PValue < significance
+		}
+
+		if m.Regressed {
+			if m.Tested && m.Significant {
+				status = RegressionDetected
+			} else if status != RegressionDetected {
+				status = RegressionSuspected
+			}
+		}
+		metrics = append(metrics, m)
+	}
+	return status, metrics
+}
+
+// resultSamples returns the value of the metric for each of the results, and
+// false if any of them does not have it
+func resultSamples(metric string, results []*TestResult) ([]float64, bool) {
+	p := ResultPredicate{Metric: metric}
+	ret := make([]float64, len(results))
+	for i, res := range results {
+		v, err := p.MetricValue(res)
+		if err != nil {
+			return nil, false
+		}
+		ret[i] = v
+	}
+	return ret, true
+}
+
+// meanVariance returns the mean and the unbiased sample variance of x
+func meanVariance(x []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	if len(x) < 2 {
+		return mean, 0
+	}
+	variance := 0.0
+	for _, v := range x {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(x)-1)
+}
+
+// welchPValue returns the one-sided p-value of Welch's t-test for the
+// hypothesis that the difference between the means (diff) is greater than
+// zero, given the sample variances and sizes of both samples
+func welchPValue(diff, var1 float64, n1 int, var2 float64, n2 int) float64 {
+	s1 := var1 / float64(n1)
+	s2 := var2 / float64(n2)
+	se := math.Sqrt(s1 + s2)
+	if se == 0 {
+		// Without any variance, a difference is either certain or absent
+		if diff > 0 {
+			return 0
+		}
+		return 1
+	}
+	t := diff / se
+	df := (s1 + s2) * (s1 + s2) /
+		(s1*s1/float64(n1-1) + s2*s2/float64(n2-1))
+	return 1 - studentTCDF(t, df)
+}
+
+// studentTCDF returns the cumulative distribution function of Student's t
+// distribution with df degrees of freedom at t
+func studentTCDF(t, df float64) float64 {
+	tail := 0.5 * regIncBeta(df/(df+t*t), df/2, 0.5)
+	if t > 0 {
+		return 1 - tail
+	}
+	return tail
+}
+
+// regIncBeta returns the regularized incomplete beta function I_x(a, b)
+func regIncBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// The continued fraction converges fastest for x < (a+1)/(a+b+2), use
+	// the symmetry I_x(a, b) = 1 - I_(1-x)(b, a) otherwise
+	if x < (a+1)/(a+b+2) {
+		return front * betaContinuedFraction(x, a, b) / a
+	}
+	return 1 - front*betaContinuedFraction(1-x, b, a)/b
+}
+
+// betaContinuedFraction evaluates the continued fraction for the incomplete
+// beta function using the modified Lentz's method
+func betaContinuedFraction(x, a, b float64) float64 {
+	const (
+		maxIterations = 200
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIterations; m++ {
+		fm := float64(m)
+		for _, num := range []float64{
+			fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm)),
+			-(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1)),
+		} {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -337,6 +337,9 @@ func ExpandSweepRun(originalTr *TestRun, sweepID string) []*TestRun {
 							Role:                r.Role,
 							Index:               c,
 							AwsLaunchTemplateID: r.AwsLaunchTemplateID,
+							RequiredLabels:      r.RequiredLabels,
+							AntiAffinity:        r.AntiAffinity,
+							Affinity:            r.Affinity,
 							AgentID:             -1,
 						})
 						roleCounts[r.Role] = c + 1
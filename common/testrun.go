@@ -1,16 +1,20 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+var testRunLogger = logging.NewLogger("testruns")
+
 // TestRun describes the main type for administering and scheduling tests. The
 // frontend posts a struct like this to the server to schedule a new job. Use
 // the feFieldName and feFieldType decorators on the type (custom) to show the
@@ -87,6 +91,14 @@ type TestRun struct {
 	AuditInterval             int                `json:"auditInterval"             feFieldTitle:"Audit Interval (blocks)"         feFieldType:"int"`
 	RecordNetworkTraffic      bool               `json:"recordNetworkTraffic"      feFieldTitle:"Record network traffic"          feFieldType:"bool"`
 	AgentShutdownDelay        int                `json:"agentShutdownDelay"        feFieldTitle:"Agent Shutdown Delay (seconds)"  feFieldType:"int"`
+	GuardWarmup               int                `json:"guardWarmup"               feFieldTitle:"Guard: warm-up (sec)"            feFieldType:"int"`
+	GuardMinThroughput        float64            `json:"guardMinThroughput"        feFieldTitle:"Guard: min throughput (TX/s)"    feFieldType:"float"`
+	GuardThroughputWindow     int                `json:"guardThroughputWindow"     feFieldTitle:"Guard: throughput window (sec)"  feFieldType:"int"`
+	GuardMaxLatency           float64            `json:"guardMaxLatency"           feFieldTitle:"Guard: max latency (sec)"        feFieldType:"float"`
+	GuardLatencyPercentile    float64            `json:"guardLatencyPercentile"    feFieldTitle:"Guard: latency percentile"       feFieldType:"float"`
+	GuardLatencyWindow        int                `json:"guardLatencyWindow"        feFieldTitle:"Guard: latency window (sec)"     feFieldType:"int"`
+	GuardNoProgress           int                `json:"guardNoProgress"           feFieldTitle:"Guard: no progress (sec)"        feFieldType:"int"`
+	GuardReason               string             `json:"guardReason"`
 	ObservedPeak              float64            `json:"observedPeak"`
 	DontRunBefore             time.Time          `json:"notBefore"`
 	Sweep                     string             `json:"sweep"`
@@ -101,6 +113,16 @@ type TestRun struct {
 	SweepOneAtATime           bool               `json:"sweepOneAtATime"`
 	SweepRoles                []*TestRunRole     `json:"sweepRoles"`
 	Priority                  int                `json:"priority"`
+	ScheduleReason            string             `json:"scheduleReason"`
+	BudgetOverride            bool               `json:"budgetOverride"`
+	Dependencies              []RunDependency    `json:"dependencies"`
+	TemplateID                string             `json:"templateID"`
+	TemplateVersion           int                `json:"templateVersion"`
+	ParentID                  string             `json:"parentID"`
+	ScheduleID                string             `json:"scheduleID"`
+	BisectID                  string             `json:"bisectID"`
+	ParentDiff                TestRunDiff        `json:"parentDiff"`
+	Regression                *RegressionVerdict `json:"regression"`
 	Roles                     []*TestRunRole     `json:"roles"`
 	Details                   string             `json:"details"`
 	ExecutedCommands          []*ExecutedCommand `json:"executedCommands"`
@@ -151,7 +173,44 @@ func (tr *TestRun) LogFilePath() string {
 	testRunDir := filepath.Join(DataDir(), fmt.Sprintf("testruns/%s", tr.ID))
 	return filepath.Join(testRunDir, "log.txt")
 }
+
+// StructuredLogFilePath returns the path of the file in which the test run's
+// log lines are stored as JSON entries (one per line) including their fields,
+// such that they can be queried
+func (tr *TestRun) StructuredLogFilePath() string {
+	testRunDir := filepath.Join(DataDir(), fmt.Sprintf("testruns/%s", tr.ID))
+	return filepath.Join(testRunDir, "log.jsonl")
+}
+
+// Phase returns the phase the test run is currently in, which is its status
+// details without the progress percentage
+func (tr *TestRun) Phase() string {
+	phase := tr.Details
+	if idx := strings.Index(phase, "("); idx > -1 &&
+		strings.Contains(phase[idx:], "%") {
+		phase = strings.TrimRight(phase[:idx], " ")
+	}
+	return phase
+}
+
 func (tr *TestRun) WriteLog(line string) {
+	tr.WriteLogFields(line, nil)
+}
+
+// WriteLogFields appends a line to the test run's log. The line is written as
+// is to the human-readable log, and together with the given fields, the test
+// run ID and its current phase to the structured log
+func (tr *TestRun) WriteLogFields(line string, fields logging.Fields) {
+	tr.WriteLogLevel(logging.LogLevelInfo, line, fields)
+}
+
+// WriteLogLevel appends a line to the test run's log like WriteLogFields, and
+// records it at the given level in the structured and container logs
+func (tr *TestRun) WriteLogLevel(
+	level logging.LogLevel,
+	line string,
+	fields logging.Fields,
+) {
 	tr.logLock.Lock()
 
 	// Append to (truncated) in-memory buffer
@@ -176,10 +235,68 @@ func (tr *TestRun) WriteLog(line string) {
 			logging.Warnf("[Testrun %s] Unable to append to log: %v", tr.ID, err)
 		}
 	}
+
+	l := testRunLogger.WithFields(fields).WithFields(logging.Fields{
+		"testrun": tr.ID,
+		"phase":   tr.Phase(),
+	})
+	tr.appendStructuredLog(l, level, line)
 	tr.logLock.Unlock()
 
 	// Write to container log
-	logging.Infof("[Testrun %s] %s", tr.ID, line)
+	l.Logf(level, "%s", line)
+}
+
+// appendStructuredLog writes the line with the fields of the passed logger to
+// the structured log file at the given level. Must be called with logLock held
+func (tr *TestRun) appendStructuredLog(
+	l *logging.Logger,
+	level logging.LogLevel,
+	line string,
+) {
+	f, err := os.OpenFile(
+		tr.StructuredLogFilePath(),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0644,
+	)
+	if err != nil {
+		logging.Warnf("[Testrun %s] Unable to append to structured log: %v", tr.ID, err)
+		return
+	}
+	defer f.Close()
+	err = json.NewEncoder(f).Encode(l.Entry(level, line))
+	if err != nil {
+		logging.Warnf("[Testrun %s] Unable to append to structured log: %v", tr.ID, err)
+	}
+}
+
+// QueryLog returns the entries from the test run's structured log that match
+// all of the given filters. See logging.Entry.Matches for the filter semantics
+func (tr *TestRun) QueryLog(filter map[string]string) ([]logging.Entry, error) {
+	ret := []logging.Entry{}
+	f, err := os.Open(tr.StructuredLogFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for {
+		var e logging.Entry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Matches(filter) {
+			ret = append(ret, e)
+		}
+	}
+	return ret, nil
 }
 
 func (tr *TestRun) LogTail() string {
@@ -201,6 +318,9 @@ type TestRunRole struct {
 	AgentID             int32               `json:"agentID"`
 	AwsLaunchTemplateID string              `json:"awsLaunchTemplateID"`
 	AwsAgentInstanceId  string              `json:"awsInstanceId"`
+	RequiredLabels      map[string]string   `json:"requiredLabels"`
+	AntiAffinity        *RoleAntiAffinity   `json:"antiAffinity"`
+	Affinity            *RoleAffinity       `json:"affinity"`
 	Fail                bool                `json:"fail"`
 	Failure             *TestRunRoleFailure `json:"failure"`
 }
@@ -221,6 +341,16 @@ const TestRunStatusAborted TestRunStatus = "Aborted"
 const TestRunStatusInterrupted TestRunStatus = "Interrupted"
 const TestRunStatusCanceled TestRunStatus = "Canceled"
 
+// TestRunStatusGuardTripped is the status of a test run that was terminated
+// early because it violated one of its guards, see GuardReason
+const TestRunStatusGuardTripped TestRunStatus = "GuardTripped"
+
+// HasGuards returns true if any of the guards of the test run is enabled
+func (tr *TestRun) HasGuards() bool {
+	return tr.GuardMinThroughput > 0 || tr.GuardMaxLatency > 0 ||
+		tr.GuardNoProgress > 0
+}
+
 type TestResultPercentile struct {
 	Bucket float64 `json:"bucket"`
 	Value  float64 `json:"value"`
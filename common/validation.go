@@ -0,0 +1,90 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The codes of validation errors, such that clients can handle them without
+// parsing the message
+const (
+	// A field has a value outside of its valid range
+	ValidationCodeOutOfRange = "outOfRange"
+	// A field that is needed is not set
+	ValidationCodeRequired = "required"
+	// The architecture is not known
+	ValidationCodeUnknownArchitecture = "unknownArchitecture"
+	// The architecture needs more roles of a kind
+	ValidationCodeMinRoles = "minRoles"
+	// The number of roles of a kind is not a multiple of the replication
+	// factor
+	ValidationCodeNotMultiple = "notMultiple"
+	// Two roles have the same kind and index
+	ValidationCodeDuplicate = "duplicate"
+	// A role refers to a machine template or role that does not exist
+	ValidationCodeUnknownReference = "unknownReference"
+	// The placement constraints of roles cannot be met
+	ValidationCodePlacement = "placement"
+	// The dependencies on other test runs are not valid
+	ValidationCodeDependency = "dependency"
+	// An error that has no specific code
+	ValidationCodeInvalid = "invalid"
+)
+
+// ValidationError is an error in the configuration of a test run. Field is
+// the path of the field it applies to, using the JSON names of the fields and
+// indexes for arrays, for instance roles[2].affinity.label. It is empty for
+// errors that apply to the test run as a whole
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (v *ValidationError) Error() string {
+	return v.Message
+}
+
+// NewValidationError returns a validation error for the field with the given
+// path
+func NewValidationError(
+	field, code, format string,
+	args ...interface{},
+) *ValidationError {
+	return &ValidationError{
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// ValidationErrors converts errors into validation errors, errors that are
+// not validation errors get the invalid code and no field. Duplicate errors,
+// for instance from the runs of a sweep, are reported once
+func ValidationErrors(errs []error) []*ValidationError {
+	ret := []*ValidationError{}
+	seen := map[ValidationError]bool{}
+	for _, err := range errs {
+		var v *ValidationError
+		if !errors.As(err, &v) {
+			v = &ValidationError{
+				Code:    ValidationCodeInvalid,
+				Message: err.Error(),
+			}
+		}
+		if seen[*v] {
+			continue
+		}
+		seen[*v] = true
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+// RoleField returns the path of a field of the i-th role
+func RoleField(i int, field string) string {
+	if field == "" {
+		return fmt.Sprintf("roles[%d]", i)
+	}
+	return fmt.Sprintf("roles[%d].%s", i, field)
+}
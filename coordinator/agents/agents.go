@@ -5,16 +5,21 @@ import (
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
+	"github.com/mit-dci/opencbdc-tctl/logging"
 	"github.com/mit-dci/opencbdc-tctl/wire"
 )
 
+var logger = logging.NewLogger("agents")
+
 // AgentsManager contains easy functions to interact with a connected agent
 type AgentsManager struct {
 	coord          *coordinator.Coordinator
 	src            *sources.SourcesManager
 	ev             chan coordinator.Event
 	commandDetails sync.Map
+	cfg            *config.Config
 }
 
 // NewAgentsManager creates a new AgentsManager
@@ -22,12 +27,14 @@ func NewAgentsManager(
 	c *coordinator.Coordinator,
 	src *sources.SourcesManager,
 	ev chan coordinator.Event,
+	cfg *config.Config,
 ) (*AgentsManager, error) {
 	return &AgentsManager{
 		coord:          c,
 		src:            src,
 		commandDetails: sync.Map{},
 		ev:             ev,
+		cfg:            cfg,
 	}, nil
 }
 
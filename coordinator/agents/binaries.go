@@ -2,7 +2,6 @@ package agents
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/wire"
@@ -31,8 +30,8 @@ func (am *AgentsManager) PrepareAgentWithBinariesForCommit(
 		agentID,
 		&wire.DeployFileFromS3RequestMsg{
 			EnvironmentID: rep.EnvironmentID,
-			SourceRegion:  os.Getenv("AWS_DEFAULT_REGION"),
-			SourceBucket:  os.Getenv("BINARIES_S3_BUCKET"),
+			SourceRegion:  am.cfg.AWS.DefaultRegion,
+			SourceBucket:  am.cfg.AWS.BinariesBucket,
 			SourcePath:    binariesInS3,
 			TargetPath:    "sources/build.tar.gz",
 			Unpack:        true,
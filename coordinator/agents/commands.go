@@ -3,12 +3,10 @@ package agents
 import (
 	"errors"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 	"github.com/mit-dci/opencbdc-tctl/wire"
 )
 
@@ -41,7 +39,9 @@ func (am *AgentsManager) RunningCommandsForAgent(
 // process and `perfSampleRate` the samples per second that we have `perf`
 // gather. `debug` determines if we run the command in gdb for debugging.
 // `commandResults` is a channel where we are supposed to report the command's
-// results once the agent has completed it.
+// results once the agent has completed it. The agent tails the files in
+// `liveSampleFiles` while the command runs and the samples it reports are sent
+// to `liveSamples`.
 func (am *AgentsManager) ExecuteCommand(
 	agentID int32,
 	command string,
@@ -56,6 +56,8 @@ func (am *AgentsManager) ExecuteCommand(
 	perfSampleRate int,
 	debug bool,
 	recordNetwork bool,
+	liveSampleFiles []string,
+	liveSamples chan *wire.LiveSamplesMsg,
 ) ([]byte, error) {
 
 	// Send the ExecuteCommandRequestMsg to the agent and get its
@@ -70,9 +72,10 @@ func (am *AgentsManager) ExecuteCommand(
 		PerfProfile:          perf,
 		PerfSampleRate:       perfSampleRate,
 		Debug:                debug,
-		S3OutputRegion:       os.Getenv("AWS_REGION"),
-		S3OutputBucket:       os.Getenv("OUTPUTS_S3_BUCKET"),
+		S3OutputRegion:       am.cfg.AWS.Region,
+		S3OutputBucket:       am.cfg.AWS.OutputsBucket,
 		RecordNetworkTraffic: recordNetwork,
+		LiveSampleFiles:      liveSampleFiles,
 	})
 	if err != nil {
 		return nil, err
@@ -114,6 +117,7 @@ func (am *AgentsManager) ExecuteCommand(
 			rep.CommandID,
 			timeout,
 			commandResults,
+			liveSamples,
 		)
 	}
 
@@ -126,9 +130,10 @@ func (am *AgentsManager) ExecuteCommand(
 			rep.CommandID,
 			timeout,
 			commandResults,
+			liveSamples,
 		)
 		if err != nil {
-			logging.Warnf(
+			logger.Warnf(
 				"waitForCommandFinish failed for command %x on agent %d: %v",
 				rep.CommandID,
 				agentID,
@@ -144,11 +149,14 @@ func (am *AgentsManager) ExecuteCommand(
 // then listen for updates on that channel and error out if the command takes
 // too long, or no updates have been received for more than 30 seconds. Once
 // the agent reports the command finished, we will inform the ``
+// Live samples the agent reports for the command are passed on to
+// liveSamples.
 func (am *AgentsManager) waitForCommandFinish(
 	agentID int32,
 	commandID []byte,
 	timeout int,
 	commandResults chan *common.ExecutedCommand,
+	liveSamples chan *wire.LiveSamplesMsg,
 ) error {
 	// Make a channel for updates on the command
 	rc := make(chan wire.Msg, 100)
@@ -173,6 +181,24 @@ func (am *AgentsManager) waitForCommandFinish(
 		if err != nil {
 			return fmt.Errorf("did not receive status update: %s", err.Error())
 		}
+		if samples, ok := msg.(*wire.LiveSamplesMsg); ok {
+			// Nobody aggregates the samples if there is no channel
+			if liveSamples == nil {
+				continue
+			}
+			// Don't block on a full channel, the live results are only
+			// indicative
+			select {
+			case liveSamples <- samples:
+			default:
+				logger.Warnf(
+					"Dropping live samples of command %x on agent %d",
+					commandID,
+					agentID,
+				)
+			}
+			continue
+		}
 		rep, ok := msg.(*wire.ExecuteCommandStatusMsg)
 		if !ok {
 			return fmt.Errorf(
@@ -7,9 +7,12 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/mit-dci/opencbdc-tctl/common"
+	ctlconfig "github.com/mit-dci/opencbdc-tctl/coordinator/config"
 	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+var logger = logging.NewLogger("awsmgr")
+
 // AwsManager is the main type for managing AWS resources from the coordinator
 type AwsManager struct {
 	Enabled               bool
@@ -25,11 +28,14 @@ type AwsManager struct {
 	seeds                 []*ShardSeed
 	forceRefreshSeeds     chan bool
 	seedLock              sync.Mutex
+	cfg                   *ctlconfig.Config
+	usage                 *usageStore
 }
 
 // NewAwsManager creates a new AwsManager instance
-func NewAwsManager() *AwsManager {
+func NewAwsManager(cfg *ctlconfig.Config) *AwsManager {
 	am := &AwsManager{
+		cfg:                   cfg,
 		s3ClientsLock:         sync.Mutex{},
 		s3Clients:             map[string]*s3.Client{},
 		vcpuLimit:             map[string]int32{},
@@ -41,6 +47,7 @@ func NewAwsManager() *AwsManager {
 		forceRefreshSubnets:   make(chan bool, 1),
 		seeds:                 make([]*ShardSeed, 0),
 		forceRefreshSeeds:     make(chan bool, 1),
+		usage:                 newUsageStore(),
 	}
 	// Run initialization in a separate goroutine
 	go func() {
@@ -54,7 +61,7 @@ func NewAwsManager() *AwsManager {
 			defaultRetrier(),
 		)
 		if err != nil {
-			logging.Warnf("Could not initialize AWS: %v", err)
+			logger.Warnf("Could not initialize AWS: %v", err)
 			am.Enabled = false
 			return
 		}
@@ -62,7 +69,7 @@ func NewAwsManager() *AwsManager {
 		// Refresh the running instances from EC2
 		i, err := am.refreshRunningInstances()
 		if err != nil {
-			logging.Warnf("Could not initialize AWS: %v", err)
+			logger.Warnf("Could not initialize AWS: %v", err)
 			am.Enabled = false
 			return
 		}
@@ -76,12 +83,12 @@ func NewAwsManager() *AwsManager {
 		// instances now since we cannot use them and we don't want them to
 		// incurr further running costs
 		if len(am.runningInstances) > 0 {
-			logging.Warnf(
+			logger.Warnf(
 				"There were instances running when the controller started up - probably left overs from an active run while the controller rebooted or crashed. Killing all instances.",
 			)
 			err = am.KillAllInstances()
 			if err != nil {
-				logging.Warnf("Could not kill instances: %v", err)
+				logger.Warnf("Could not kill instances: %v", err)
 			}
 		}
 
@@ -113,5 +120,9 @@ func NewAwsManager() *AwsManager {
 	// Start a loop that refreshes the quotas/limits periodically
 	go am.refreshLimitsLoop()
 
+	// Start a loop that closes the usage records of instances that were
+	// terminated outside of the coordinator
+	go am.refreshInstanceUsageLoop()
+
 	return am
 }
@@ -3,24 +3,15 @@ package awsmgr
 import (
 	"context"
 	"errors"
-	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/batch"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // getBatchDefault returns a Batch client for use in the default region.
 func (am *AwsManager) getBatchDefault() (*batch.Client, error) {
-	batchRegion := os.Getenv("AWS_DEFAULT_REGION")
-	if batchRegion == "" {
-		logging.Warnf(
-			"Environment AWS_DEFAULT_REGION is not set, falling back to us-east-1",
-		)
-		batchRegion = "us-east-1"
-	}
-	return am.getBatch(batchRegion)
+	return am.getBatch(am.cfg.AWS.DefaultRegion)
 }
 
 // getBatch returns a Batch client for use in region `region`. This default
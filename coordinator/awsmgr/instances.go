@@ -5,10 +5,10 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // RunningInstances returns the currently running instances in EC2
@@ -23,13 +23,15 @@ func (am *AwsManager) RunningInstances() []*AwsInstance {
 // normally only done on start-up, since the controller is the only entity
 // launching new instances. As such, the instances array will be maintained by
 // the logic that starts and stops new instances in stead of polling the data
-// from EC2
+// from EC2. The usage records of instances that are no longer running are
+// closed on every refresh
 func (am *AwsManager) refreshRunningInstances() ([]*AwsInstance, error) {
 	if !am.Enabled {
 		return []*AwsInstance{}, nil
 	}
 
 	// Load all instances from EC2
+	listed := time.Now()
 	allInstances := make([]*AwsInstance, 0)
 	instancesLock := sync.Mutex{}
 	err := am.RunEC2ForAllRegions(func(e *ec2.Client, region string) error {
@@ -68,7 +70,10 @@ func (am *AwsManager) refreshRunningInstances() ([]*AwsInstance, error) {
 		return nil, err
 	}
 
-	logging.Infof(
+	// Close the usage records of instances that are gone
+	am.reconcileUsage(listed, allInstances)
+
+	logger.Infof(
 		"[AWS Manager] Found %d instances, filtering by state 'Running' and name tag 'test-agent-*' or 'test-controller-agent'",
 		len(allInstances),
 	)
@@ -92,7 +97,7 @@ func (am *AwsManager) refreshRunningInstances() ([]*AwsInstance, error) {
 		}
 	}
 
-	logging.Infof(
+	logger.Infof(
 		"[AWS Manager] Found %d instances running and with name tag 'test-agent-*' or 'test-controller-agent'",
 		len(result),
 	)
@@ -133,7 +138,7 @@ func (am *AwsManager) StopAgentsByInstanceIds(ids []string) error {
 
 // StopAgents will terminate the EC2 instances by the instance objects passed
 func (am *AwsManager) StopAgents(a []*AwsInstance) error {
-	logging.Infof("Stopping %d instances...", len(a))
+	logger.Infof("Stopping %d instances...", len(a))
 	// Run this logic separately for all regions
 	err := am.RunEC2ForAllRegions(func(e *ec2.Client, region string) error {
 		// Build an array of instances in this region to terminate
@@ -155,7 +160,7 @@ func (am *AwsManager) StopAgents(a []*AwsInstance) error {
 				killIds = append(killIds, *j.Instance.InstanceId)
 			}
 
-			logging.Infof(
+			logger.Infof(
 				"Stopping %d instances in region %s",
 				len(killIds),
 				region,
@@ -171,6 +176,7 @@ func (am *AwsManager) StopAgents(a []*AwsInstance) error {
 				return err
 			}
 
+			am.recordTermination(killInstances[i:end])
 			for _, j := range killInstances[i:end] {
 				j.Instance.State = &types.InstanceState{
 					Name: types.InstanceStateNameTerminated,
@@ -195,7 +201,7 @@ func (am *AwsManager) StopAgents(a []*AwsInstance) error {
 			newArr = append(newArr, i)
 		}
 	}
-	logging.Infof(
+	logger.Infof(
 		"Running instances array went from %d to %d",
 		len(am.runningInstances),
 		len(newArr),
@@ -12,7 +12,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // StartNewAgents is the main logic that spawns our new instances. We pass it an
@@ -133,9 +132,10 @@ func (am *AwsManager) StartNewAgents(
 						// If this is not our first attempt, wait a bit before
 						// retrying to increase our odds that the capacity has
 						// changed
-						logging.Infof(
+						logger.Infof(
 							"[Region %s] Still need %d agents, waiting for 15 seconds to retry",
 							region,
+							ag.Count,
 						)
 						time.Sleep(
 							time.Second * 15,
@@ -166,7 +166,7 @@ func (am *AwsManager) StartNewAgents(
 									max = 50
 								}
 
-								logging.Infof(
+								logger.Infof(
 									"[Region %s] Launching maximum %d instances of template [%s] with subnet [%s], AZ [%s] and spot [%t]",
 									region,
 									ag.Count,
@@ -240,7 +240,7 @@ func (am *AwsManager) StartNewAgents(
 									// Log the error, but continue the loop - we
 									// might succceed in other regions/spot
 									// settings
-									logging.Warnf(
+									logger.Warnf(
 										"There was a RunInstances failure in region %s for testrun %s: %v",
 										region,
 										testRunID,
@@ -261,7 +261,7 @@ func (am *AwsManager) StartNewAgents(
 											spotRequestTag,
 										)
 										if err != nil {
-											logging.Errorf(
+											logger.Errorf(
 												"Error canceling spot requests: %v",
 												err,
 											)
@@ -270,7 +270,7 @@ func (am *AwsManager) StartNewAgents(
 								} else {
 									// The lauches were succesful!
 									launched = int32(len(result.Instances))
-									logging.Infof("[Region %s] Launched %d instances of template [%s] with subnet [%s] and spot [%b]", region, len(result.Instances), ag.TemplateID, sn.SubnetID, marketOptions != nil)
+									logger.Infof("[Region %s] Launched %d instances of template [%s] with subnet [%s] and spot [%t]", region, len(result.Instances), ag.TemplateID, sn.SubnetID, marketOptions != nil)
 									if len(result.Instances) > 0 {
 										am.runningInstancesLock.Lock()
 										for i := range result.Instances {
@@ -295,7 +295,7 @@ func (am *AwsManager) StartNewAgents(
 											// return array.
 											for j := range templateIDs {
 												if templateIDs[j] == ag.TemplateID && returnVal[j] == nil {
-													logging.Debugf("Assigning instance index %d, id %s to result %d", i, *result.Instances[i].InstanceId, j)
+													logger.Debugf("Assigning instance index %d, id %s to result %d", i, *result.Instances[i].InstanceId, j)
 													// Since there is only one
 													// goroutine per region, and
 													// instance templates exist
@@ -314,6 +314,11 @@ func (am *AwsManager) StartNewAgents(
 											}
 										}
 										am.runningInstancesLock.Unlock()
+										am.recordLaunch(
+											region,
+											ag.TemplateID,
+											result.Instances,
+										)
 
 										// Deduct the number of instances we
 										// launched from the number we still
@@ -367,8 +372,8 @@ func (am *AwsManager) StartNewAgents(
 					failed := 0
 					for len(retryTagging) > 0 {
 						// Keep looping until we have finished all tags
-						logging.Debugf(
-							"[Region %s] Tagging %s instances",
+						logger.Debugf(
+							"[Region %s] Tagging %d instances",
 							region,
 							len(retryTagging),
 						)
@@ -381,7 +386,7 @@ func (am *AwsManager) StartNewAgents(
 						if len(retryTagging) == previousLen {
 							failed++
 							if failed > 3 {
-								logging.Errorf(
+								logger.Errorf(
 									"Continuous failures tagging resources. %d left untagged.",
 									len(retryTagging),
 								)
@@ -417,7 +422,7 @@ func (am *AwsManager) StartNewAgents(
 							} else {
 								// Tagging failed, keep the element in the array
 								// such that we retry it in the next loop cycle
-								logging.Errorf("Failed tagging resource: %v", err)
+								logger.Errorf("Failed tagging resource: %v", err)
 							}
 							// Sleep to prevent excessive API calling and
 							// hitting limits
@@ -0,0 +1,118 @@
+package awsmgr
+
+import (
+	"sort"
+	"time"
+)
+
+// InstanceCost is the cost of a single instance's usage
+type InstanceCost struct {
+	InstanceUsage
+	Hours float64 `json:"hours"`
+	// The price per hour in USD, only valid if Priced is true
+	HourlyPrice float64 `json:"hourlyPrice"`
+	Cost        float64 `json:"cost"`
+	// False if the price table has no price for the instance type
+	Priced bool `json:"priced"`
+}
+
+// CostSummary is the total cost of a set of instances
+type CostSummary struct {
+	Instances []InstanceCost `json:"instances,omitempty"`
+	// The total instance-hours per instance type
+	InstanceHours map[string]float64 `json:"instanceHours"`
+	// The total cost in USD of the instances that have a price
+	Cost float64 `json:"cost"`
+	// The instance types without a price in the price table, which are not
+	// included in Cost
+	UnpricedTypes []string `json:"unpricedTypes"`
+}
+
+// NewCostSummary returns an empty cost summary
+func NewCostSummary() CostSummary {
+	return CostSummary{
+		Instances:     []InstanceCost{},
+		InstanceHours: map[string]float64{},
+		UnpricedTypes: []string{},
+	}
+}
+
+// Add adds the totals of another cost summary to this one, without its
+// instances
+func (c *CostSummary) Add(o CostSummary) {
+	c.Cost += o.Cost
+	for t, h := range o.InstanceHours {
+		c.InstanceHours[t] += h
+	}
+	c.UnpricedTypes = mergeTypes(c.UnpricedTypes, o.UnpricedTypes)
+}
+
+func mergeTypes(a, b []string) []string {
+	for _, t := range b {
+		found := false
+		for _, e := range a {
+			if e == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, t)
+		}
+	}
+	sort.Strings(a)
+	return a
+}
+
+// HourlyPrice looks up the price per hour in USD of an instance type in a
+// region from the configured price table. Region-specific prices take
+// precedence over the ones for all regions ("*"). Spot instances use the
+// on-demand price if there is no spot price
+func (am *AwsManager) HourlyPrice(
+	region, instanceType string,
+	spot bool,
+) (float64, bool) {
+	tables := []map[string]map[string]float64{am.cfg.Pricing.OnDemand}
+	if spot {
+		tables = append(
+			[]map[string]map[string]float64{am.cfg.Pricing.Spot},
+			tables...,
+		)
+	}
+	for _, table := range tables {
+		for _, r := range []string{region, "*"} {
+			if p, ok := table[r][instanceType]; ok {
+				return p, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Cost calculates the cost of the instance usage up to now
+func (am *AwsManager) Cost(usage []InstanceUsage) CostSummary {
+	now := time.Now()
+	c := NewCostSummary()
+	for _, u := range usage {
+		ic := InstanceCost{InstanceUsage: u, Hours: u.Hours(now)}
+		ic.HourlyPrice, ic.Priced = am.HourlyPrice(
+			u.Region,
+			u.InstanceType,
+			u.Spot,
+		)
+		ic.Cost = ic.Hours * ic.HourlyPrice
+		c.Instances = append(c.Instances, ic)
+		c.InstanceHours[u.InstanceType] += ic.Hours
+		c.Cost += ic.Cost
+		if !ic.Priced {
+			c.UnpricedTypes = mergeTypes(
+				c.UnpricedTypes,
+				[]string{u.InstanceType},
+			)
+		}
+	}
+	sort.Slice(c.Instances, func(i, j int) bool {
+		return c.Instances[i].Launched.Before(c.Instances[j].Launched)
+	})
+	return c
+}
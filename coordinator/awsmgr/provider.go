@@ -0,0 +1,114 @@
+package awsmgr
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
+)
+
+// This file implements provider.Provider on top of EC2: the launch templates
+// are the machine templates, and the on-demand vCPU quotas the limits
+
+func templateFromLaunchTemplate(lt AwsLaunchTemplate) provider.MachineTemplate {
+	return provider.MachineTemplate{
+		ID:           lt.TemplateID,
+		Region:       lt.Region,
+		Description:  lt.Description,
+		InstanceType: lt.InstanceType,
+		VCPUCount:    lt.VCPUCount,
+		VCPU:         lt.VCPU,
+		RAM:          lt.RAM,
+		Bandwidth:    lt.Bandwidth,
+	}
+}
+
+func machineFromInstance(i *AwsInstance) *provider.Machine {
+	m := &provider.Machine{ID: *i.Instance.InstanceId, Region: i.Region}
+	for _, t := range i.Instance.Tags {
+		if t.Key == nil || t.Value == nil {
+			continue
+		}
+		switch *t.Key {
+		case "TestRunID":
+			m.TestRunID = *t.Value
+		case "aws:ec2launchtemplate:id":
+			m.TemplateID = *t.Value
+		}
+	}
+	return m
+}
+
+// Templates returns the available launch templates
+func (am *AwsManager) Templates() []provider.MachineTemplate {
+	ret := []provider.MachineTemplate{}
+	for _, lt := range am.LaunchTemplates() {
+		ret = append(ret, templateFromLaunchTemplate(lt))
+	}
+	return ret
+}
+
+// Template returns a single launch template
+func (am *AwsManager) Template(id string) (provider.MachineTemplate, error) {
+	lt, err := am.GetLaunchTemplate(id)
+	if err != nil {
+		return provider.MachineTemplate{}, err
+	}
+	return templateFromLaunchTemplate(lt), nil
+}
+
+// VCPULimit returns the vCPU quota for on-demand instances in a region
+func (am *AwsManager) VCPULimit(region string) int32 {
+	return am.GetVCPULimit(region + "-ondem")
+}
+
+// Available always returns nil, the vCPU quota is the only limit EC2 has
+// that the scheduler knows of
+func (am *AwsManager) Available(requests []provider.MachineRequest) error {
+	return nil
+}
+
+// Launch starts an instance from each of the requests' launch templates
+func (am *AwsManager) Launch(
+	requests []provider.MachineRequest,
+	testRunID string,
+) ([]*provider.Machine, []error) {
+	templateIDs := make([]string, len(requests))
+	for i, r := range requests {
+		templateIDs[i] = r.TemplateID
+	}
+	instances, errs := am.StartNewAgents(templateIDs, testRunID)
+	ret := make([]*provider.Machine, len(instances))
+	for i, inst := range instances {
+		if inst != nil {
+			ret[i] = machineFromInstance(inst)
+			ret[i].TemplateID = templateIDs[i]
+		}
+	}
+	return ret, errs
+}
+
+// Terminate terminates the instances with the given IDs
+func (am *AwsManager) Terminate(ids []string) error {
+	return am.StopAgentsByInstanceIds(ids)
+}
+
+// Running returns the running test agent instances
+func (am *AwsManager) Running() []*provider.Machine {
+	ret := []*provider.Machine{}
+	for _, i := range am.RunningInstances() {
+		if i.Instance.State != nil &&
+			i.Instance.State.Name == types.InstanceStateNameTerminated {
+			continue
+		}
+		ret = append(ret, machineFromInstance(i))
+	}
+	return ret
+}
+
+// MachineID returns the EC2 instance ID an agent reported
+func (am *AwsManager) MachineID(info common.AgentSystemInfo) string {
+	if !info.AWS {
+		return ""
+	}
+	return info.EC2InstanceID
+}
@@ -18,19 +18,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // getS3Default returns an S3 client for use in the default region.
 func (am *AwsManager) getS3Default() (*s3.Client, error) {
-	defaultRegion := os.Getenv("AWS_DEFAULT_REGION")
-	if defaultRegion == "" {
-		logging.Warnf(
-			"Environment AWS_DEFAULT_REGION is not set, falling back to us-east-1",
-		)
-		defaultRegion = "us-east-1"
-	}
-	return am.getS3(defaultRegion)
+	return am.getS3(am.cfg.AWS.DefaultRegion)
 }
 
 // getS3 returns an S3 client for the given region. Since s3 clients are thread
@@ -137,11 +129,12 @@ func (am *AwsManager) downloadFromS3UsingClient(
 			Key:    key,
 		})
 	if err != nil {
-		logging.Warnf(
+		logger.Warnf(
 			"Error downloading %s/%s to %s: %v",
 			d.SourceBucket,
 			d.SourcePath,
 			d.TargetPath,
+			err,
 		)
 	}
 	return err
@@ -219,7 +212,7 @@ func (am *AwsManager) UploadToS3(d common.S3Upload) error {
 	if err != nil {
 		return err
 	}
-	logging.Infof(
+	logger.Infof(
 		"Uploaded %s to %s/%s",
 		d.SourcePath,
 		d.TargetBucket,
@@ -246,7 +239,7 @@ func (am *AwsManager) DownloadMultipleFromS3(
 				err := am.DownloadFromS3(dl)
 				if err != nil {
 					if dl.Retries == 0 {
-						logging.Errorf(
+						logger.Errorf(
 							"Failed to download from S3, no more retries: %v %v",
 							dl,
 							err,
@@ -254,7 +247,7 @@ func (am *AwsManager) DownloadMultipleFromS3(
 						errChan <- err
 					} else {
 						dl.Retries--
-						logging.Warnf("Failed to download from S3 - retrying: %v %v", dl, err)
+						logger.Warnf("Failed to download from S3 - retrying: %v %v", dl, err)
 						dlChan <- dl
 						continue // Prevent wg.Done() which leads to negative waitgroup counter
 					}
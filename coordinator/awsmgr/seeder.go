@@ -14,7 +14,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/batch"
 	"github.com/aws/aws-sdk-go-v2/service/batch/types"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // seed_witcomm is the witness commitment that allows the seed_privkey to spend
@@ -66,8 +65,8 @@ func (am *AwsManager) GenerateSeed(seed ShardSeed, cfg []byte) error {
 		}
 		f.Close()
 		err = am.UploadToS3(common.S3Upload{
-			TargetRegion: os.Getenv("AWS_REGION"),
-			TargetBucket: os.Getenv("BINARIES_S3_BUCKET"),
+			TargetRegion: am.cfg.AWS.Region,
+			TargetBucket: am.cfg.AWS.BinariesBucket,
 			TargetPath: fmt.Sprintf(
 				"shard-preseeds/configs/%s.cfg",
 				seed.TestRunID,
@@ -79,8 +78,8 @@ func (am *AwsManager) GenerateSeed(seed ShardSeed, cfg []byte) error {
 		}
 		input := &batch.SubmitJobInput{
 			JobName:       aws.String(fmt.Sprintf("SEED_%s", seed.TestRunID)),
-			JobQueue:      aws.String(os.Getenv("UHS_SEEDER_BATCH_JOB")),
-			JobDefinition: aws.String(os.Getenv("UHS_SEEDER_BATCH_JOB")),
+			JobQueue:      aws.String(am.cfg.AWS.SeederBatchJob),
+			JobDefinition: aws.String(am.cfg.AWS.SeederBatchJob),
 			ContainerOverrides: &types.ContainerOverrides{
 				Environment: []types.KeyValuePair{
 					{
@@ -120,7 +119,7 @@ func (am *AwsManager) GenerateSeed(seed ShardSeed, cfg []byte) error {
 						Value: aws.String(
 							fmt.Sprintf(
 								"s3://%s/shard-preseeds/configs/%s.cfg",
-								os.Getenv("BINARIES_S3_BUCKET"),
+								am.cfg.AWS.BinariesBucket,
 								seed.TestRunID,
 							),
 						),
@@ -167,7 +166,7 @@ func (am *AwsManager) refreshSeedsLoop() {
 		if refresh {
 			err := am.refreshSeeds()
 			if err != nil {
-				logging.Errorf("Error refreshing shard seeds: %v", err)
+				logger.Errorf("Error refreshing shard seeds: %v", err)
 			}
 		}
 	}
@@ -179,7 +178,7 @@ func (am *AwsManager) refreshSeeds() error {
 	// Build a new array
 	seeds := make([]*ShardSeed, 0)
 
-	logging.Info("Refreshing seeds")
+	logger.Info("Refreshing seeds")
 
 	// Check all entries with a batch job ID if the job completed
 	input := &batch.DescribeJobsInput{
@@ -203,7 +202,7 @@ func (am *AwsManager) refreshSeeds() error {
 		}
 		for _, job := range out.Jobs {
 			if job.Status == types.JobStatusSucceeded {
-				logging.Infof(
+				logger.Infof(
 					"Batch job %s for shard seeding succeeded",
 					*job.JobId,
 				)
@@ -211,7 +210,7 @@ func (am *AwsManager) refreshSeeds() error {
 				continue
 			}
 			if job.Status == types.JobStatusFailed {
-				logging.Warnf(
+				logger.Warnf(
 					"Batch job %s (%s) for shard seeding failed",
 					*job.JobId,
 					*job.JobName,
@@ -227,22 +226,16 @@ func (am *AwsManager) refreshSeeds() error {
 			}
 		}
 	} else {
-		logging.Info("No pending seed jobs")
+		logger.Info("No pending seed jobs")
 	}
 	if len(am.seeds) == 0 || jobCompleted {
-		logging.Info("Loading seeds from S3")
+		logger.Info("Loading seeds from S3")
 		// Now, find all seeds in the S3 container
-		region := os.Getenv("AWS_DEFAULT_REGION")
-		if region == "" {
-			logging.Warnf(
-				"Environment AWS_DEFAULT_REGION is not set, falling back to us-east-1",
-			)
-			region = "us-east-1"
-		}
-		bucket := os.Getenv("BINARIES_S3_BUCKET")
+		region := am.cfg.AWS.DefaultRegion
+		bucket := am.cfg.AWS.BinariesBucket
 		allSeeds, err := am.ListObjectsInS3(region, bucket, "shard-preseeds/")
 		if err != nil {
-			logging.Errorf("Error listing seeds in S3: %v", err)
+			logger.Errorf("Error listing seeds in S3: %v", err)
 			return err
 		}
 
@@ -10,7 +10,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // getSQ creates a servicequotas client for the given region and returns it. If
@@ -61,7 +60,7 @@ func (am *AwsManager) refreshLimits() {
 				output, err := sq.ListServiceQuotas(context.Background(), input)
 
 				if err != nil {
-					logging.Errorf("Error fetching service quota: %v", err)
+					logger.Errorf("Error fetching service quota: %v", err)
 					return err
 				} else {
 					nextToken = output.NextToken
@@ -89,9 +88,9 @@ func (am *AwsManager) refreshLimits() {
 	if err == nil {
 		am.vcpuLimit = newLimits
 	}
-	logging.Infof("vCPU Limits [%d]:", len(newLimits))
+	logger.Infof("vCPU Limits [%d]:", len(newLimits))
 	for k, v := range newLimits {
-		logging.Infof("%s %d", k, v)
+		logger.Infof("%s %d", k, v)
 	}
 }
 
@@ -6,7 +6,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // TerminateSpotRequests will search for spot request(s) with the given tag and
@@ -15,7 +14,7 @@ func (awsm *AwsManager) CancelSpotRequests(
 	clt *ec2.Client,
 	spotRequestTag string,
 ) error {
-	logging.Infof(
+	logger.Infof(
 		"Checking for remaining spot requests with tag %s",
 		spotRequestTag,
 	)
@@ -42,7 +41,7 @@ func (awsm *AwsManager) CancelSpotRequests(
 			sirReq,
 		)
 		if err == nil {
-			logging.Infof(
+			logger.Infof(
 				"Found %d spot requests with tag %s",
 				len(
 					sirResp.SpotInstanceRequests,
@@ -60,7 +59,7 @@ func (awsm *AwsManager) CancelSpotRequests(
 				}
 			}
 		} else {
-			logging.Errorf("Failed to fetch open spot instance requests: %v", err)
+			logger.Errorf("Failed to fetch open spot instance requests: %v", err)
 		}
 		if nextToken == nil {
 			break
@@ -68,7 +67,7 @@ func (awsm *AwsManager) CancelSpotRequests(
 	}
 
 	if len(cancelSpotRequests) > 0 {
-		logging.Infof(
+		logger.Infof(
 			"Cancelling %d open spot instance requests",
 			len(cancelSpotRequests),
 		)
@@ -80,18 +79,18 @@ func (awsm *AwsManager) CancelSpotRequests(
 			cancelReq,
 		)
 		if err == nil {
-			logging.Infof(
+			logger.Infof(
 				"Succesfully canceled %d open spot instance requests",
 				len(
 					cancelResp.CancelledSpotInstanceRequests,
 				),
 			)
 		} else {
-			logging.Errorf("Failed to cancel open spot instance requests: %v", err)
+			logger.Errorf("Failed to cancel open spot instance requests: %v", err)
 		}
 
 	} else {
-		logging.Infof("No open spot instance requests to cancel")
+		logger.Infof("No open spot instance requests to cancel")
 	}
 	return nil
 }
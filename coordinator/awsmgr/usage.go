@@ -0,0 +1,220 @@
+package awsmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// minimumBilledDuration is the minimum duration EC2 bills a Linux instance
+// for, even if it was terminated sooner
+const minimumBilledDuration = time.Minute
+
+// usageReconcileMargin is how long an instance must have been launched before
+// its usage record is closed because EC2 did not list it
+const usageReconcileMargin = 5 * time.Minute
+
+// InstanceUsage records when an instance that was launched for a test run
+// was running
+type InstanceUsage struct {
+	InstanceID       string    `json:"instanceID"`
+	TestRunID        string    `json:"testRunID"`
+	Region           string    `json:"region"`
+	InstanceType     string    `json:"instanceType"`
+	LaunchTemplateID string    `json:"launchTemplateID"`
+	Spot             bool      `json:"spot"`
+	Launched         time.Time `json:"launched"`
+	// Zero while the instance is still running
+	Terminated time.Time `json:"terminated"`
+}
+
+// Hours returns the billed duration of the instance in hours. For instances
+// that are still running, the duration up to now is returned
+func (u InstanceUsage) Hours(now time.Time) float64 {
+	end := u.Terminated
+	if end.IsZero() {
+		end = now
+	}
+	d := end.Sub(u.Launched)
+	if d < minimumBilledDuration {
+		d = minimumBilledDuration
+	}
+	return d.Hours()
+}
+
+// usageStore holds the usage of all instances the coordinator launched, and
+// persists it in the data directory
+type usageStore struct {
+	path    string
+	records map[string]*InstanceUsage
+	lock    sync.Mutex
+}
+
+func newUsageStore() *usageStore {
+	u := &usageStore{
+		path:    filepath.Join(common.DataDir(), "instanceusage.json"),
+		records: map[string]*InstanceUsage{},
+	}
+	b, err := ioutil.ReadFile(u.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("Unable to read instance usage: %v", err)
+		}
+		return u
+	}
+	err = json.Unmarshal(b, &u.records)
+	if err != nil {
+		logger.Warnf("Unable to parse instance usage: %v", err)
+	}
+	return u
+}
+
+// persist writes the usage records to disk. Must be called with the lock held
+func (u *usageStore) persist() {
+	b, err := json.Marshal(u.records)
+	if err == nil {
+		err = ioutil.WriteFile(u.path, b, 0644)
+	}
+	if err != nil {
+		logger.Warnf("Unable to persist instance usage: %v", err)
+	}
+}
+
+// usageFromInstance builds the usage record of a running instance from the
+// data EC2 returned for it
+func usageFromInstance(region string, i *types.Instance) *InstanceUsage {
+	u := &InstanceUsage{
+		InstanceID:   *i.InstanceId,
+		Region:       region,
+		InstanceType: string(i.InstanceType),
+		Spot:         i.InstanceLifecycle == types.InstanceLifecycleTypeSpot,
+		Launched:     time.Now(),
+	}
+	if i.LaunchTime != nil {
+		u.Launched = *i.LaunchTime
+	}
+	for _, t := range i.Tags {
+		if t.Key != nil && t.Value != nil && *t.Key == "TestRunID" {
+			u.TestRunID = *t.Value
+		}
+	}
+	return u
+}
+
+// recordLaunch records the launch of instances from a launch template
+func (am *AwsManager) recordLaunch(
+	region, templateID string,
+	instances []types.Instance,
+) {
+	am.usage.lock.Lock()
+	defer am.usage.lock.Unlock()
+	for i := range instances {
+		u := usageFromInstance(region, &instances[i])
+		u.LaunchTemplateID = templateID
+		am.usage.records[u.InstanceID] = u
+	}
+	am.usage.persist()
+}
+
+// recordTermination records the termination of instances. Instances that
+// were not launched by this coordinator (or before it recorded usage) are
+// added with the launch time EC2 reported
+func (am *AwsManager) recordTermination(instances []*AwsInstance) {
+	now := time.Now()
+	am.usage.lock.Lock()
+	defer am.usage.lock.Unlock()
+	for _, i := range instances {
+		u, ok := am.usage.records[*i.Instance.InstanceId]
+		if !ok {
+			u = usageFromInstance(i.Region, i.Instance)
+			am.usage.records[u.InstanceID] = u
+		}
+		if u.Terminated.IsZero() {
+			u.Terminated = now
+		}
+	}
+	am.usage.persist()
+}
+
+// reconcileUsage closes the open usage records of instances that EC2 no
+// longer reports, or reports as terminated. These were terminated outside of
+// StopAgents, for instance by a spot interruption or while the coordinator
+// was not running. The termination time is not known, so it is recorded as
+// the time the termination was noticed. Instances launched shortly before
+// the given listing time may be missing from it, their records are left open
+func (am *AwsManager) reconcileUsage(
+	listed time.Time,
+	instances []*AwsInstance,
+) {
+	live := map[string]bool{}
+	for _, i := range instances {
+		if i.Instance.State != nil &&
+			i.Instance.State.Name == types.InstanceStateNameTerminated {
+			continue
+		}
+		live[*i.Instance.InstanceId] = true
+	}
+
+	now := time.Now()
+	am.usage.lock.Lock()
+	defer am.usage.lock.Unlock()
+	closed := 0
+	cutoff := listed.Add(-usageReconcileMargin)
+	for _, u := range am.usage.records {
+		if u.Terminated.IsZero() && !live[u.InstanceID] &&
+			u.Launched.Before(cutoff) {
+			u.Terminated = now
+			closed++
+		}
+	}
+	if closed > 0 {
+		logger.Infof(
+			"Closed the usage records of %d instances that are no longer running",
+			closed,
+		)
+		am.usage.persist()
+	}
+}
+
+// refreshInstanceUsageLoop periodically closes the usage records of
+// instances that were terminated outside of the coordinator
+func (am *AwsManager) refreshInstanceUsageLoop() {
+	for {
+		time.Sleep(time.Minute * 15)
+		_, err := am.refreshRunningInstances()
+		if err != nil {
+			logger.Warnf("Could not refresh instance usage: %v", err)
+		}
+	}
+}
+
+// InstanceUsage returns the usage of the instances launched for the given
+// test run
+func (am *AwsManager) InstanceUsage(testRunID string) []InstanceUsage {
+	am.usage.lock.Lock()
+	defer am.usage.lock.Unlock()
+	ret := []InstanceUsage{}
+	for _, u := range am.usage.records {
+		if u.TestRunID == testRunID {
+			ret = append(ret, *u)
+		}
+	}
+	return ret
+}
+
+// AllInstanceUsage returns the usage of all instances, keyed by test run ID
+func (am *AwsManager) AllInstanceUsage() map[string][]InstanceUsage {
+	am.usage.lock.Lock()
+	defer am.usage.lock.Unlock()
+	ret := map[string][]InstanceUsage{}
+	for _, u := range am.usage.records {
+		ret[u.TestRunID] = append(ret[u.TestRunID], *u)
+	}
+	return ret
+}
@@ -0,0 +1,448 @@
+package bisect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+)
+
+var logger = logging.NewLogger("bisect")
+
+// ErrNotFound is returned when a bisection does not exist
+var ErrNotFound = errors.New("bisection not found")
+
+// checkInterval is how often the bisect manager checks the test runs of the
+// running bisections
+const checkInterval = 15 * time.Second
+
+// defaultRepeat is the number of test runs per commit if none is given
+const defaultRepeat = 3
+
+// Status is the state of a bisection
+type Status string
+
+const (
+	// StatusRunning means the bisection is testing commits
+	StatusRunning Status = "running"
+	// StatusCompleted means the first bad commit was found
+	StatusCompleted Status = "completed"
+	// StatusFailed means the bisection could not continue, see its details
+	StatusFailed Status = "failed"
+	// StatusCanceled means the bisection was canceled by a user
+	StatusCanceled Status = "canceled"
+)
+
+// BisectConfig holds the user-defined settings of a bisection
+type BisectConfig struct {
+	Name string `json:"name"`
+	// A commit that meets the criterion, and a later commit that does not
+	GoodCommit string `json:"goodCommit"`
+	BadCommit  string `json:"badCommit"`
+	// The test run that is run on each commit. Its commit hash is replaced
+	Spec *common.TestRun `json:"spec"`
+	// The number of test runs per commit
+	Repeat int `json:"repeat"`
+	// The predicate a good commit meets, evaluated on the mean of the metric
+	// over the commit's test runs. For instance throughputAvg >= 50000 or
+	// latencyP99 < 1
+	Criterion common.ResultPredicate `json:"criterion"`
+}
+
+// Step is the test of a single commit during a bisection
+type Step struct {
+	Commit     string    `json:"commit"`
+	TestRunIDs []string  `json:"testRunIDs"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	// The mean of the criterion's metric over the test runs that completed
+	Value float64 `json:"value"`
+	// Whether the commit meets the criterion, only valid once finished
+	Good bool `json:"good"`
+}
+
+// Bisection searches the commits between a good and a bad commit for the
+// first commit that no longer meets a criterion, by testing the commit
+// halfway the remaining range until the range is narrowed down to a single
+// commit
+type Bisection struct {
+	ID string `json:"id"`
+	BisectConfig
+	OwnerThumbprint string    `json:"ownerThumbprint"`
+	Created         time.Time `json:"created"`
+	Completed       time.Time `json:"completed"`
+	Status          Status    `json:"status"`
+	Details         string    `json:"details"`
+	// The commits from the good commit (first) to the bad commit (last), in
+	// the order of the main branch's history
+	Commits []string `json:"commits"`
+	// The indices in Commits of the last known good and the first known bad
+	// commit
+	GoodIndex int     `json:"goodIndex"`
+	BadIndex  int     `json:"badIndex"`
+	Steps     []*Step `json:"steps"`
+	// The first commit that does not meet the criterion, once completed
+	FirstBadCommit string `json:"firstBadCommit"`
+}
+
+// copy returns a copy of the bisection that can be handed out without the
+// lock held
+func (b *Bisection) copy() *Bisection {
+	cp := *b
+	cp.Steps = make([]*Step, len(b.Steps))
+	for i, s := range b.Steps {
+		sc := *s
+		cp.Steps[i] = &sc
+	}
+	return &cp
+}
+
+// BisectManager holds the bisections, persists them in the data directory
+// and schedules the test runs for each step
+type BisectManager struct {
+	path       string
+	bisections map[string]*Bisection
+	lock       sync.Mutex
+	tr         *testruns.TestRunManager
+	src        *sources.SourcesManager
+}
+
+// NewBisectManager loads the persisted bisections. Running bisections
+// continue once Run is called
+func NewBisectManager(
+	tr *testruns.TestRunManager,
+	src *sources.SourcesManager,
+) (*BisectManager, error) {
+	bm := &BisectManager{
+		path:       filepath.Join(common.DataDir(), "bisections.json"),
+		bisections: map[string]*Bisection{},
+		tr:         tr,
+		src:        src,
+	}
+	b, err := ioutil.ReadFile(bm.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &bm.bisections)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logger.Infof("Loaded %d bisections", len(bm.bisections))
+	return bm, nil
+}
+
+// persist writes the bisections to disk. Must be called with the lock held
+func (bm *BisectManager) persist() error {
+	b, err := json.MarshalIndent(bm.bisections, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(bm.path, b, 0644)
+}
+
+// commitRange returns the commits from good to bad in the history of the
+// main branch, oldest first
+func (bm *BisectManager) commitRange(good, bad string) ([]string, error) {
+	log, err := bm.src.GetGitLog(0, math.MaxInt32, false)
+	if err != nil {
+		return nil, err
+	}
+	goodIdx, badIdx := -1, -1
+	for i, c := range log {
+		if c.CommitHash == good {
+			goodIdx = i
+		}
+		if c.CommitHash == bad {
+			badIdx = i
+		}
+	}
+	if goodIdx < 0 {
+		return nil, fmt.Errorf("good commit %s is not on the main branch", good)
+	}
+	if badIdx < 0 {
+		return nil, fmt.Errorf("bad commit %s is not on the main branch", bad)
+	}
+	// The git log is ordered newest first
+	if badIdx >= goodIdx {
+		return nil, errors.New("the bad commit should be later than the good one")
+	}
+	commits := make([]string, 0, goodIdx-badIdx+1)
+	for i := goodIdx; i >= badIdx; i-- {
+		commits = append(commits, log[i].CommitHash)
+	}
+	return commits, nil
+}
+
+// List returns all bisections, newest first
+func (bm *BisectManager) List() []*Bisection {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+	ret := make([]*Bisection, 0, len(bm.bisections))
+	for _, b := range bm.bisections {
+		ret = append(ret, b.copy())
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Created.After(ret[j].Created)
+	})
+	return ret
+}
+
+// Get returns the bisection with the given ID
+func (bm *BisectManager) Get(id string) (*Bisection, error) {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+	b, ok := bm.bisections[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b.copy(), nil
+}
+
+// Create starts a new bisection on behalf of the user with the given
+// thumbprint, and schedules the test runs for its first step
+func (bm *BisectManager) Create(
+	owner string,
+	cfg BisectConfig,
+) (*Bisection, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if cfg.Spec == nil {
+		return nil, errors.New("spec is required")
+	}
+	spec, err := common.TestRunSpec(cfg.Spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Sweep != "" {
+		return nil, errors.New("the spec of a bisection cannot be a sweep")
+	}
+	cfg.Spec = spec
+	if cfg.Repeat == 0 {
+		cfg.Repeat = defaultRepeat
+	}
+	if cfg.Repeat < 1 {
+		return nil, errors.New("repeat should be at least 1")
+	}
+	err = cfg.Criterion.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("criterion: %v", err)
+	}
+	commits, err := bm.commitRange(cfg.GoodCommit, cfg.BadCommit)
+	if err != nil {
+		return nil, err
+	}
+	id, err := common.RandomID(12)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bisection{
+		ID:              id,
+		BisectConfig:    cfg,
+		OwnerThumbprint: owner,
+		Created:         time.Now(),
+		Status:          StatusRunning,
+		Commits:         commits,
+		GoodIndex:       0,
+		BadIndex:        len(commits) - 1,
+		Steps:           []*Step{},
+	}
+
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+	bm.nextStep(b)
+	if b.Status == StatusFailed {
+		return nil, errors.New(b.Details)
+	}
+	bm.bisections[id] = b
+	err = bm.persist()
+	if err != nil {
+		logger.Warnf("Unable to persist bisections: %v", err)
+	}
+	return b.copy(), nil
+}
+
+// Cancel stops the bisection with the given ID and cancels its queued test
+// runs. Test runs that already started are not interrupted
+func (bm *BisectManager) Cancel(id string) (*Bisection, error) {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+	b, ok := bm.bisections[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if b.Status != StatusRunning {
+		return nil, fmt.Errorf("bisection is %s", b.Status)
+	}
+	for _, tr := range bm.tr.GetTestRuns() {
+		if tr.BisectID == id && tr.Status == common.TestRunStatusQueued {
+			bm.tr.UpdateStatus(
+				tr,
+				common.TestRunStatusCanceled,
+				"Bisection canceled by user",
+			)
+		}
+	}
+	bm.finish(b, StatusCanceled, "Canceled by user")
+	err := bm.persist()
+	if err != nil {
+		return nil, err
+	}
+	return b.copy(), nil
+}
+
+// Run is the main loop that advances the running bisections once the test
+// runs of their current step have finished. Nothing happens until the test
+// run manager has loaded the existing test runs
+func (bm *BisectManager) Run() {
+	for {
+		if !bm.tr.TestRunsLoaded() {
+			time.Sleep(checkInterval)
+			continue
+		}
+		bm.lock.Lock()
+		changed := false
+		for _, b := range bm.bisections {
+			if b.Status == StatusRunning && bm.checkStep(b) {
+				changed = true
+			}
+		}
+		if changed {
+			err := bm.persist()
+			if err != nil {
+				logger.Warnf("Unable to persist bisections: %v", err)
+			}
+		}
+		bm.lock.Unlock()
+		time.Sleep(checkInterval)
+	}
+}
+
+// finish ends the bisection. Must be called with the lock held
+func (bm *BisectManager) finish(b *Bisection, status Status, details string) {
+	b.Status = status
+	b.Details = details
+	b.Completed = time.Now()
+	logger.Infof("Bisection %s (%s) %s: %s", b.ID, b.Name, status, details)
+}
+
+// nextStep narrows down the range once the bisection has a good and a bad
+// commit that are adjacent, or schedules the test runs for the commit halfway
+// the remaining range otherwise. Must be called with the lock held
+func (bm *BisectManager) nextStep(b *Bisection) {
+	if b.BadIndex-b.GoodIndex <= 1 {
+		b.FirstBadCommit = b.Commits[b.BadIndex]
+		bm.finish(
+			b,
+			StatusCompleted,
+			fmt.Sprintf("First bad commit is %s", b.FirstBadCommit),
+		)
+		return
+	}
+
+	commit := b.Commits[(b.GoodIndex+b.BadIndex)/2]
+	spec, err := common.TestRunSpec(b.Spec)
+	if err != nil {
+		bm.finish(b, StatusFailed, err.Error())
+		return
+	}
+	spec.CommitHash = commit
+	spec.Repeat = b.Repeat
+	spec.BisectID = b.ID
+	_, ids, err := bm.tr.ScheduleTestRunSpec(spec, b.OwnerThumbprint)
+	if err != nil {
+		bm.finish(
+			b,
+			StatusFailed,
+			fmt.Sprintf("Unable to schedule test runs for %s: %v", commit, err),
+		)
+		return
+	}
+	b.Steps = append(b.Steps, &Step{
+		Commit:     commit,
+		TestRunIDs: ids,
+		Started:    time.Now(),
+	})
+	b.Details = fmt.Sprintf(
+		"Testing commit %s, %d commits left",
+		commit,
+		b.BadIndex-b.GoodIndex-1,
+	)
+	logger.Infof("Bisection %s (%s): %s", b.ID, b.Name, b.Details)
+}
+
+// checkStep evaluates the current step of a running bisection if all of its
+// test runs have finished, and continues with the next step. Returns true if
+// the bisection changed. Must be called with the lock held
+func (bm *BisectManager) checkStep(b *Bisection) bool {
+	if len(b.Steps) == 0 {
+		return false
+	}
+	step := b.Steps[len(b.Steps)-1]
+	results := []*common.TestResult{}
+	for _, id := range step.TestRunIDs {
+		tr, ok := bm.tr.GetTestRun(id)
+		if !ok {
+			continue
+		}
+		switch tr.Status {
+		case common.TestRunStatusQueued, common.TestRunStatusRunning:
+			return false
+		case common.TestRunStatusCompleted:
+			if tr.Result != nil {
+				results = append(results, tr.Result)
+			}
+		}
+	}
+	step.Finished = time.Now()
+	if len(results) == 0 {
+		bm.finish(
+			b,
+			StatusFailed,
+			fmt.Sprintf(
+				"No test run on commit %s completed with a result",
+				step.Commit,
+			),
+		)
+		return true
+	}
+
+	sum := 0.0
+	for _, res := range results {
+		v, err := b.Criterion.MetricValue(res)
+		if err != nil {
+			bm.finish(b, StatusFailed, err.Error())
+			return true
+		}
+		sum += v
+	}
+	step.Value = sum / float64(len(results))
+	good, err := b.Criterion.Holds(step.Value)
+	if err != nil {
+		bm.finish(b, StatusFailed, err.Error())
+		return true
+	}
+	step.Good = good
+
+	idx := (b.GoodIndex + b.BadIndex) / 2
+	if good {
+		b.GoodIndex = idx
+	} else {
+		b.BadIndex = idx
+	}
+	bm.nextStep(b)
+	return true
+}
@@ -0,0 +1,479 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+)
+
+// redacted is the value secrets are replaced with when exposing the
+// configuration through the API
+const redacted = "[redacted]"
+
+// Config is the configuration of the coordinator. It is loaded from a JSON
+// file, after which the environment variables named in the `env` tags
+// override individual settings. Fields tagged with `secret` are redacted when
+// the configuration is exposed through the API.
+type Config struct {
+	CoordinatorPort            int              `json:"coordinatorPort"            env:"PORT"`
+	HTTPSPort                  int              `json:"httpsPort"                  env:"HTTPS_PORT"`
+	HTTPSWithoutClientCertPort int              `json:"httpsWithoutClientCertPort" env:"HTTPS_WITHOUT_CLIENT_CERT_PORT"`
+	AWS                        AWSConfig        `json:"aws"`
+	Sources                    SourcesConfig    `json:"sources"`
+	Logging                    LoggingConfig    `json:"logging"`
+	Regression                 RegressionConfig `json:"regression"`
+	Pricing                    PricingConfig    `json:"pricing"`
+	Provider                   ProviderConfig   `json:"provider"`
+	Results                    ResultsConfig    `json:"results"`
+}
+
+// AWSConfig holds the AWS resources the coordinator uses
+type AWSConfig struct {
+	// The region in which the outputs and binaries buckets live
+	Region string `json:"region"        env:"AWS_REGION"`
+	// The region used for S3 and Batch clients that are not tied to a
+	// specific region. Defaults to Region
+	DefaultRegion string `json:"defaultRegion" env:"AWS_DEFAULT_REGION"`
+	// The bucket agents upload test run outputs and profiles to
+	OutputsBucket string `json:"outputsBucket" env:"OUTPUTS_S3_BUCKET"`
+	// The bucket compiled binaries and shard preseeds are stored in
+	BinariesBucket string `json:"binariesBucket" env:"BINARIES_S3_BUCKET"`
+	// The AWS Batch job queue and definition for generating shard preseeds
+	SeederBatchJob string `json:"seederBatchJob" env:"UHS_SEEDER_BATCH_JOB"`
+}
+
+// SourcesConfig holds the location of the transaction processor sources
+type SourcesConfig struct {
+	RepoURL     string `json:"repoURL"     env:"TRANSACTION_PROCESSOR_REPO_URL"`
+	MainBranch  string `json:"mainBranch"  env:"TRANSACTION_PROCESSOR_MAIN_BRANCH"`
+	AccessToken string `json:"accessToken" env:"TRANSACTION_PROCESSOR_ACCESS_TOKEN" secret:"true"`
+}
+
+// LoggingConfig holds the initial log settings. The levels can be changed at
+// runtime through the API
+type LoggingConfig struct {
+	// Either "text" or "json"
+	Format string `json:"format" env:"LOG_FORMAT"`
+	// The default log level (error, warn, info or debug)
+	Level string `json:"level"  env:"LOG_LEVEL"`
+	// Log level overrides per component
+	Components map[string]string `json:"components"`
+}
+
+// RegressionConfig controls the comparison of test run results with those of
+// the same configuration on earlier commits
+type RegressionConfig struct {
+	// Disables regression detection altogether
+	Disabled bool `json:"disabled"         env:"REGRESSION_DETECTION_DISABLED"`
+	// A metric that gets worse by more than this percentage is flagged
+	ThresholdPercent float64 `json:"thresholdPercent" env:"REGRESSION_THRESHOLD_PERCENT"`
+	// The p-value below which a difference is considered significant
+	Significance float64 `json:"significance"     env:"REGRESSION_SIGNIFICANCE"`
+	// The maximum number of test runs per commit that are compared
+	MaxRuns int `json:"maxRuns"          env:"REGRESSION_MAX_RUNS"`
+}
+
+// PricingConfig holds the prices of the EC2 instance types in USD per hour,
+// keyed by region (or "*" for all regions) and instance type. They are used
+// to calculate the cost of test runs
+type PricingConfig struct {
+	OnDemand map[string]map[string]float64 `json:"onDemand"`
+	// Spot instances use the on-demand price if their type is not listed here
+	Spot map[string]map[string]float64 `json:"spot"`
+}
+
+// ProviderConfig selects the infrastructure the test agents run on
+type ProviderConfig struct {
+	// Either "aws" to launch EC2 instances, "pool" to lease the hosts of a
+	// static pool, or "docker" to run agent containers on a local Docker
+	// daemon
+	Type string `json:"type" env:"PROVIDER"`
+	// The hosts of the static pool
+	Pool []PoolHostConfig `json:"pool"`
+	// The settings of the Docker provider
+	Docker DockerConfig `json:"docker"`
+}
+
+// PoolHostConfig describes a host of the static pool, on which the agent runs
+// as a service
+type PoolHostConfig struct {
+	// The host name the agent on the host reports
+	HostName string `json:"hostName"`
+	// Labels roles can require, such as CPU count, RAM, NIC speed and
+	// location
+	Labels map[string]string `json:"labels"`
+}
+
+// DockerConfig holds the settings of the provider that runs the agents in
+// containers on a local Docker daemon
+type DockerConfig struct {
+	// The image of the agent containers, built from Dockerfile.agent
+	Image string `json:"image"           env:"DOCKER_AGENT_IMAGE"`
+	// If set, the image is built from the Dockerfile.agent in this directory
+	// before the first containers are started
+	BuildContext string `json:"buildContext"    env:"DOCKER_BUILD_CONTEXT"`
+	// The user-defined bridge network the containers are attached to. It is
+	// created if it does not exist
+	Network string `json:"network"         env:"DOCKER_NETWORK"`
+	// The host the agents connect to the coordinator at. Defaults to the
+	// gateway of the network, which is the Docker host
+	CoordinatorHost string `json:"coordinatorHost" env:"DOCKER_COORDINATOR_HOST"`
+	// The maximum number of CPUs of the containers running at the same time,
+	// 0 for no limit
+	MaxCPUs int `json:"maxCPUs"         env:"DOCKER_MAX_CPUS"`
+	// Additional environment variables for the agents, for instance for
+	// accessing S3. Their values are redacted since they hold credentials
+	Environment map[string]string `json:"environment"     secret:"true"`
+	// The sizes of the containers roles can use
+	Templates []DockerTemplateConfig `json:"templates"`
+}
+
+// DockerTemplateConfig describes the CPU and memory limits of an agent
+// container. Using the ID of a launch template allows test runs to be
+// scheduled unchanged with either provider
+type DockerTemplateConfig struct {
+	ID          string  `json:"id"`
+	Description string  `json:"description"`
+	CPUs        float64 `json:"cpus"`
+	// 0 for no limit
+	MemoryMB int `json:"memoryMB"`
+}
+
+// ResultsConfig controls how the results of test runs are calculated
+type ResultsConfig struct {
+	// Either "python" to run calculate_results.py, which also plots the
+	// results, "go" to calculate them natively without plots, or "parity" to
+	// run both, keep the results of the script and report where the native
+	// results differ
+	Engine string `json:"engine" env:"RESULTS_ENGINE"`
+}
+
+// Default returns the configuration that applies when neither the file nor
+// the environment specifies a setting
+func Default() *Config {
+	return &Config{
+		CoordinatorPort:            8000,
+		HTTPSPort:                  443,
+		HTTPSWithoutClientCertPort: 444,
+		Sources: SourcesConfig{
+			MainBranch: "trunk",
+		},
+		Logging: LoggingConfig{
+			Format:     "text",
+			Level:      "info",
+			Components: map[string]string{},
+		},
+		Regression: RegressionConfig{
+			ThresholdPercent: 5,
+			Significance:     0.05,
+			MaxRuns:          10,
+		},
+		Pricing: PricingConfig{
+			OnDemand: map[string]map[string]float64{},
+			Spot:     map[string]map[string]float64{},
+		},
+		Provider: ProviderConfig{
+			Type: "aws",
+			Pool: []PoolHostConfig{},
+			Docker: DockerConfig{
+				Image:       "opencbdc-tctl-agent",
+				Network:     "opencbdc-tctl",
+				Environment: map[string]string{},
+				Templates:   []DockerTemplateConfig{},
+			},
+		},
+		Results: ResultsConfig{
+			Engine: "python",
+		},
+	}
+}
+
+// DefaultPath returns the location of the configuration file that is used
+// when no path is given explicitly
+func DefaultPath() string {
+	return filepath.Join(common.DataDir(), "coordinator.config.json")
+}
+
+// Load reads the configuration from the file at path on top of the defaults,
+// applies the environment overrides and validates the result. If path is
+// empty, the file at DefaultPath is used if it exists.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+	}
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse config file %s: %v", path, err)
+		}
+	} else if explicit || !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to open config file %s: %v", path, err)
+	}
+
+	err = applyEnv(reflect.ValueOf(cfg).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.AWS.DefaultRegion == "" {
+		cfg.AWS.DefaultRegion = cfg.AWS.Region
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv walks the (nested) struct v and overrides each field that has an
+// `env` tag with the value of that environment variable, if it is set
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			err := applyEnv(fv)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		val, ok := os.LookupEnv(name)
+		if !ok || val == "" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String:
+			fv.SetString(val)
+		case reflect.Int:
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("environment %s: %q is not a number", name, val)
+			}
+			fv.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return fmt.Errorf("environment %s: %q is not a number", name, val)
+			}
+			fv.SetFloat(f)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("environment %s: %q is not a boolean", name, val)
+			}
+			fv.SetBool(b)
+		}
+	}
+	return nil
+}
+
+// Validate checks the configuration for errors and returns all of them at
+// once, such that misconfiguration is discovered at startup in stead of in the
+// middle of a test run
+func (c *Config) Validate() error {
+	errs := []string{}
+	ports := map[int]string{}
+	for name, port := range map[string]int{
+		"coordinatorPort":            c.CoordinatorPort,
+		"httpsPort":                  c.HTTPSPort,
+		"httpsWithoutClientCertPort": c.HTTPSWithoutClientCertPort,
+	} {
+		if port <= 0 || port > 65535 {
+			errs = append(errs, fmt.Sprintf("%s: %d is not a valid port", name, port))
+			continue
+		}
+		if other, ok := ports[port]; ok {
+			errs = append(errs, fmt.Sprintf("%s and %s both use port %d", other, name, port))
+		}
+		ports[port] = name
+	}
+
+	for name, val := range map[string]string{
+		"aws.region":         c.AWS.Region,
+		"aws.outputsBucket":  c.AWS.OutputsBucket,
+		"aws.binariesBucket": c.AWS.BinariesBucket,
+		"aws.seederBatchJob": c.AWS.SeederBatchJob,
+		"sources.repoURL":    c.Sources.RepoURL,
+		"sources.mainBranch": c.Sources.MainBranch,
+	} {
+		if val == "" {
+			errs = append(errs, fmt.Sprintf("%s is required", name))
+		}
+	}
+
+	if c.Logging.Format != "text" && c.Logging.Format != "json" {
+		errs = append(errs, fmt.Sprintf("logging.format: %q should be text or json", c.Logging.Format))
+	}
+	if _, err := logging.ParseLogLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Sprintf("logging.level: %v", err))
+	}
+	for comp, lvl := range c.Logging.Components {
+		if _, err := logging.ParseLogLevel(lvl); err != nil {
+			errs = append(errs, fmt.Sprintf("logging.components.%s: %v", comp, err))
+		}
+	}
+
+	if c.Regression.ThresholdPercent < 0 {
+		errs = append(errs, "regression.thresholdPercent cannot be negative")
+	}
+	if c.Regression.Significance <= 0 || c.Regression.Significance >= 1 {
+		errs = append(errs, fmt.Sprintf("regression.significance: %g should be between 0 and 1", c.Regression.Significance))
+	}
+	if c.Regression.MaxRuns < 1 {
+		errs = append(errs, "regression.maxRuns should be at least 1")
+	}
+
+	for name, table := range map[string]map[string]map[string]float64{
+		"onDemand": c.Pricing.OnDemand,
+		"spot":     c.Pricing.Spot,
+	} {
+		for region, prices := range table {
+			for instanceType, price := range prices {
+				if price < 0 {
+					errs = append(errs, fmt.Sprintf("pricing.%s.%s.%s cannot be negative", name, region, instanceType))
+				}
+			}
+		}
+	}
+
+	switch c.Provider.Type {
+	case "aws":
+	case "pool":
+		if len(c.Provider.Pool) == 0 {
+			errs = append(errs, "provider.pool needs at least one host")
+		}
+	case "docker":
+		if len(c.Provider.Docker.Templates) == 0 {
+			errs = append(errs, "provider.docker.templates needs at least one template")
+		}
+		for name, val := range map[string]string{
+			"provider.docker.image":   c.Provider.Docker.Image,
+			"provider.docker.network": c.Provider.Docker.Network,
+		} {
+			if val == "" {
+				errs = append(errs, fmt.Sprintf("%s is required", name))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("provider.type: %q should be aws, pool or docker", c.Provider.Type))
+	}
+	if c.Provider.Docker.MaxCPUs < 0 {
+		errs = append(errs, "provider.docker.maxCPUs cannot be negative")
+	}
+	templates := map[string]bool{}
+	for i, t := range c.Provider.Docker.Templates {
+		if t.ID == "" {
+			errs = append(errs, fmt.Sprintf("provider.docker.templates[%d].id is required", i))
+		} else if templates[t.ID] {
+			errs = append(errs, fmt.Sprintf("provider.docker.templates: template %s is listed twice", t.ID))
+		}
+		templates[t.ID] = true
+		if t.CPUs <= 0 {
+			errs = append(errs, fmt.Sprintf("provider.docker.templates[%d].cpus should be positive", i))
+		}
+		if t.MemoryMB < 0 {
+			errs = append(errs, fmt.Sprintf("provider.docker.templates[%d].memoryMB cannot be negative", i))
+		}
+	}
+	hosts := map[string]bool{}
+	for i, h := range c.Provider.Pool {
+		if h.HostName == "" {
+			errs = append(errs, fmt.Sprintf("provider.pool[%d].hostName is required", i))
+		} else if hosts[h.HostName] {
+			errs = append(errs, fmt.Sprintf("provider.pool: host %s is listed twice", h.HostName))
+		}
+		hosts[h.HostName] = true
+	}
+
+	switch c.Results.Engine {
+	case "python", "go", "parity":
+	default:
+		errs = append(errs, fmt.Sprintf("results.engine: %q should be python, go or parity", c.Results.Engine))
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// ApplyLogging configures the logging package according to the logging
+// section of the configuration. Assumes the configuration was validated.
+func (c *Config) ApplyLogging() {
+	logging.SetJSONOutput(c.Logging.Format == "json")
+	lvl, _ := logging.ParseLogLevel(c.Logging.Level)
+	logging.SetLogLevel(int(lvl))
+	for comp, l := range c.Logging.Components {
+		lvl, _ := logging.ParseLogLevel(l)
+		logging.SetComponentLogLevel(comp, lvl)
+	}
+}
+
+// Redacted returns a copy of the configuration in which all secrets are
+// replaced, such that it can be exposed through the API
+func (c *Config) Redacted() *Config {
+	cp := *c
+	redact(reflect.ValueOf(&cp).Elem())
+	return &cp
+}
+
+// redact walks the (nested) struct v and replaces the value of each non-empty
+// string field tagged as secret. The values of string maps tagged as secret
+// are replaced in a copy of the map, since the copy of the configuration
+// shares its maps with the original
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			redact(fv)
+			continue
+		}
+		if field.Tag.Get("secret") != "true" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String:
+			if fv.String() != "" {
+				fv.SetString(redacted)
+			}
+		case reflect.Map:
+			if fv.IsNil() ||
+				field.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+			cp := reflect.MakeMapWithSize(field.Type, fv.Len())
+			iter := fv.MapRange()
+			for iter.Next() {
+				cp.SetMapIndex(
+					iter.Key(),
+					reflect.ValueOf(redacted).Convert(field.Type.Elem()),
+				)
+			}
+			fv.Set(cp)
+		}
+	}
+}
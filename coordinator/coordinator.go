@@ -13,6 +13,8 @@ import (
 	"github.com/mit-dci/opencbdc-tctl/wire"
 )
 
+var logger = logging.NewLogger("coordinator")
+
 var ErrAgentNotFound = errors.New("agent not found")
 
 // Coordinator is the main type that manages the connections
@@ -40,6 +42,8 @@ type ConnectedAgent struct {
 	SystemInfo common.AgentSystemInfo `json:"systemInfo"`
 	// The binary version of the agent binary that connected
 	AgentVersion string `json:"agentVersion"`
+	// The labels the agent advertised, such as region, rack or custom tags
+	Labels map[string]string `json:"labels"`
 	// The current ping roundtrip time as measured from the coordinator
 	PingRTT float64 `json:"pingRTT"`
 	// The array of registered listeners that are expecting reply or update
@@ -207,7 +211,7 @@ func (c *Coordinator) SendToAgent(
 			ourID:     wire.GetMessageHeaderID(msg, "ID"),
 			replyChan: replyChan,
 		}
-		logging.Debugf(
+		logger.Debugf(
 			"Registered message callback for agent %d, message %d with channel %v",
 			agentID,
 			l.ourID,
@@ -233,7 +237,7 @@ func (c *Coordinator) RegisterCommandStatusCallback(
 	if err != nil {
 		return err
 	}
-	logging.Debugf(
+	logger.Debugf(
 		"Registered command callback for agent %d, command %x with channel %v",
 		agentID,
 		commandID,
@@ -263,9 +267,9 @@ func (c *Coordinator) handleConn(agent *ConnectedAgent) {
 			// (which indicates the remote site terminated the
 			// connection), log whatever went wrong as well
 			if err.Error() != "EOF" {
-				logging.Warnf("Agent %d: Error reading message: %v", agent.ID, err.Error())
+				logger.Warnf("Agent %d: Error reading message: %v", agent.ID, err.Error())
 			} else {
-				logging.Infof("Agent %d disconnected", agent.ID)
+				logger.Infof("Agent %d disconnected", agent.ID)
 			}
 			agent.conn.Close()
 			c.removeAgent(agent)
@@ -279,7 +283,7 @@ func (c *Coordinator) handleConn(agent *ConnectedAgent) {
 		// ErrorMsg in stead
 		if err != nil {
 			returnMsg = &wire.ErrorMsg{Error: err.Error()}
-			logging.Warnf(
+			logger.Warnf(
 				"Error handling message [%T] from agent %d: %v",
 				msg,
 				agent.ID,
@@ -323,7 +327,16 @@ func (c *Coordinator) handleMsg(
 		repliedToID := wire.GetMessageHeaderID(t, "YourID")
 		newListeners := make([]*agentReplyListener, 0)
 		sentReply := false
+		// Command status updates and live samples go to the listener of the
+		// command
+		var commandID []byte
 		cmdStatus, isCmdStatus := msg.(*wire.ExecuteCommandStatusMsg)
+		liveSamples, isLiveSamples := msg.(*wire.LiveSamplesMsg)
+		if isCmdStatus {
+			commandID = cmdStatus.CommandID
+		} else if isLiveSamples {
+			commandID = liveSamples.CommandID
+		}
 		agent.listenersLock.Lock()
 		defer agent.listenersLock.Unlock()
 		for _, rl := range agent.listeners {
@@ -338,26 +351,26 @@ func (c *Coordinator) handleMsg(
 				case rl.replyChan <- msg:
 					break
 				case <-time.After(time.Second * 1):
-					logging.Warnf("Timeout delivering message to channel %v for reply on %d", rl.replyChan, rl.ourID)
+					logger.Warnf("Timeout delivering message to channel %v for reply on %d", rl.replyChan, rl.ourID)
 				}
 				sentReply = true
 				// We're not adding this listener back to the newListeners array
 				// because we only want a single reply to a command
-			} else if isCmdStatus && bytes.Equal(rl.commandID, cmdStatus.CommandID) {
-				// This message is a command status update for a command that
-				// this listener is registered to listen to. Send it to the
-				// channel corresponding to the listener (non-blocking, time out
-				// after a second). This timeout is to prevent a full channel
-				// from causing this logic to hang, which blocks the connection
-				// to this agent entirely
+			} else if commandID != nil && bytes.Equal(rl.commandID, commandID) {
+				// This message is a command status update or live samples for
+				// a command that this listener is registered to listen to. Send
+				// it to the channel corresponding to the listener (non-blocking,
+				// time out after a second). This timeout is to prevent a full
+				// channel from causing this logic to hang, which blocks the
+				// connection to this agent entirely
 				select {
 				case rl.replyChan <- msg:
 					break
 				case <-time.After(time.Second * 1):
-					logging.Warnf("Timeout delivering message to channel %v for command %x", rl.replyChan, rl.commandID)
+					logger.Warnf("Timeout delivering message to channel %v for command %x", rl.replyChan, rl.commandID)
 				}
 				sentReply = true
-				if cmdStatus.Status != wire.CommandStatusFinished {
+				if !isCmdStatus || cmdStatus.Status != wire.CommandStatusFinished {
 					// If the command's status is not finished, we expect
 					// further updates and thus re-register the listener for
 					// the next update message
@@ -389,6 +402,12 @@ func (c *Coordinator) handleMsg(
 			return nil, nil
 		}
 
+		// Live samples that arrive after the command finished have nobody
+		// listening for them anymore, and can be ignored as well
+		if isLiveSamples {
+			return nil, nil
+		}
+
 		// If we received a message that is no reply, no hello or systemInfo
 		// update, no command status update and no ack, then we don't know how
 		// to process it - but it cannot just be ignored. We should return an
@@ -413,6 +432,10 @@ func (c *Coordinator) handleHello(
 ) (wire.Msg, error) {
 	agent.SystemInfo = msg.SystemInfo
 	agent.AgentVersion = msg.AgentVersion
+	agent.Labels = msg.Labels
+	if agent.Labels == nil {
+		agent.Labels = map[string]string{}
+	}
 	agent.handshakeComplete = true
 	return &wire.HelloResponseMsg{YourAgentID: agent.ID}, nil
 }
@@ -443,7 +466,7 @@ func (c *Coordinator) pingLoop(a *ConnectedAgent) {
 				// Already gone
 				return
 			}
-			logging.Errorf("Error sending ping to agent: %v", err)
+			logger.Errorf("Error sending ping to agent: %v", err)
 			a.close()
 			c.removeAgent(a)
 			return
@@ -503,7 +526,7 @@ func (a *ConnectedAgent) sendLoop() {
 		case msg := <-a.outgoing:
 			err := a.conn.Send(msg)
 			if err != nil {
-				logging.Infof(
+				logger.Infof(
 					"Could not send message to agent %d: %v",
 					a.ID,
 					err,
@@ -512,7 +535,7 @@ func (a *ConnectedAgent) sendLoop() {
 				return
 			}
 		case <-time.After(20 * time.Minute):
-			logging.Infof(
+			logger.Infof(
 				"Did not receive message from agent %d for 20 minutes, closing",
 				a.ID,
 			)
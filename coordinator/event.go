@@ -44,6 +44,28 @@ type TestRunStatusChangePayload struct {
 	Debounced bool
 }
 
+// EventTypeTestRunScheduleReasonChanged is fired when the scheduler's
+// decision about a queued test run (and the reason for it) changes
+const EventTypeTestRunScheduleReasonChanged EventType = "testRunScheduleReasonChanged"
+
+type TestRunScheduleReasonChangedPayload struct {
+	TestRunID string `json:"testRunID"`
+	Reason    string `json:"reason"`
+}
+
+// EventTypeBudgetWarning is fired when the spending in the current month
+// crosses one of the configured warning thresholds of a budget
+const EventTypeBudgetWarning EventType = "budgetWarning"
+
+type BudgetWarningPayload struct {
+	// "monthly" for the total budget, "user" for a user's budget
+	Scope            string  `json:"scope"`
+	User             string  `json:"user"`
+	ThresholdPercent float64 `json:"thresholdPercent"`
+	Spent            float64 `json:"spent"`
+	Limit            float64 `json:"limit"`
+}
+
 // EventTypeConnectedUsersChanged is fired when a new user connects to the
 // frontend or disconnects from it, and updated the number of active connected
 // users
@@ -71,6 +93,17 @@ type TestRunLogAppendedPayload struct {
 	Log       string `json:"log"`
 }
 
+// EventTypeTestRunLiveResults is fired when the throughput and latency of a
+// running test run have been updated from the samples its load generators
+// reported. Like the log, it is only sent to the users that are looking at the
+// details of the given test
+const EventTypeTestRunLiveResults EventType = "testRunLiveResults"
+
+type TestRunLiveResultsPayload struct {
+	TestRunID string                   `json:"testRunID"`
+	Points    []common.LiveResultPoint `json:"points"`
+}
+
 // EventTypeTestRunResultAvailable is fired when the result calculation for a
 // test run completes
 const EventTypeTestRunResultAvailable EventType = "testRunResultAvailable"
@@ -80,6 +113,16 @@ type TestRunResultAvailablePayload struct {
 	Result    *common.TestResult `json:"result"`
 }
 
+// EventTypeTestRunRegressionVerdict is fired when a test run's result has
+// been compared with the results of the same configuration on an earlier
+// commit
+const EventTypeTestRunRegressionVerdict EventType = "testRunRegressionVerdict"
+
+type TestRunRegressionVerdictPayload struct {
+	TestRunID string                    `json:"testRunID"`
+	Verdict   *common.RegressionVerdict `json:"verdict"`
+}
+
 // EventTypeSystemStateChange is fired when the system state changes. When the
 // systems starts up, it is loading test runs from disk and signals this event
 // when its done loading. The client is supposed to show a "System is starting
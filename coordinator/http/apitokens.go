@@ -0,0 +1,306 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// TokenScope limits what an API token can be used for
+type TokenScope string
+
+const (
+	// TokenScopeReadOnly allows viewing test runs, results and outputs
+	TokenScopeReadOnly TokenScope = "read-only"
+	// TokenScopeSchedule additionally allows scheduling and managing test
+	// runs
+	TokenScopeSchedule TokenScope = "schedule"
+	// TokenScopeAdmin allows everything the owner of the token can do
+	TokenScopeAdmin TokenScope = "admin"
+)
+
+// scopeRoles maps the token scopes to the role they grant. The effective role
+// of a token is never more than the role of its owner
+var scopeRoles = map[TokenScope]Role{
+	TokenScopeReadOnly: RoleViewer,
+	TokenScopeSchedule: RoleOperator,
+	TokenScopeAdmin:    RoleAdmin,
+}
+
+// maxTokenLifetime is the longest a token can remain valid
+const maxTokenLifetime = 365 * 24 * time.Hour
+
+// tokenUsagePersistInterval is how often the usage of the tokens is written to
+// disk, such that authenticating a request does not have to
+const tokenUsagePersistInterval = time.Minute
+
+// APIToken is a named bearer token a user minted for headless access to the
+// API. Only a hash of the secret is stored
+type APIToken struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	OwnerThumbprint string     `json:"ownerThumbprint"`
+	Scope           TokenScope `json:"scope"`
+	Created         time.Time  `json:"created"`
+	Expires         time.Time  `json:"expires"`
+	Revoked         time.Time  `json:"revoked"`
+	LastUsed        time.Time  `json:"lastUsed"`
+	LastUsedBy      string     `json:"lastUsedBy"`
+	LastUsedFor     string     `json:"lastUsedFor"`
+	UseCount        int        `json:"useCount"`
+	SecretHash      string     `json:"secretHash,omitempty"`
+}
+
+// Valid returns true if the token has not expired and is not revoked
+func (t *APIToken) Valid() bool {
+	return t.Revoked.IsZero() && time.Now().Before(t.Expires)
+}
+
+// TokenStore holds the API tokens and persists them in the data directory
+type TokenStore struct {
+	path   string
+	tokens map[string]*APIToken
+	lock   sync.Mutex
+	// The usage of a token changed since the tokens were last persisted
+	usageChanged bool
+}
+
+// NewTokenStore loads the API tokens from the data directory
+func NewTokenStore() (*TokenStore, error) {
+	ts := &TokenStore{
+		path:   filepath.Join(common.DataDir(), "tokens.json"),
+		tokens: map[string]*APIToken{},
+	}
+	b, err := ioutil.ReadFile(ts.path)
+	if err == nil {
+		err = json.Unmarshal(b, &ts.tokens)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	go ts.persistUsageLoop()
+	return ts, nil
+}
+
+// persistUsageLoop periodically writes the tokens to disk if their usage
+// changed
+func (ts *TokenStore) persistUsageLoop() {
+	for {
+		time.Sleep(tokenUsagePersistInterval)
+		ts.lock.Lock()
+		if ts.usageChanged {
+			err := ts.persist()
+			if err != nil {
+				logger.Warnf("Unable to persist token usage: %v", err)
+			}
+		}
+		ts.lock.Unlock()
+	}
+}
+
+// persist writes the tokens to disk. Must be called with the lock held
+func (ts *TokenStore) persist() error {
+	b, err := json.MarshalIndent(ts.tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(ts.path, b, 0600)
+	if err != nil {
+		return err
+	}
+	ts.usageChanged = false
+	return nil
+}
+
+// Create mints a new token for the given owner. It returns the token and the
+// bearer value that has to be presented to use it, which is not stored and
+// can therefore not be retrieved again
+func (ts *TokenStore) Create(
+	owner, name string,
+	scope TokenScope,
+	lifetime time.Duration,
+) (*APIToken, string, error) {
+	if _, ok := scopeRoles[scope]; !ok {
+		return nil, "", fmt.Errorf("unknown scope %s", scope)
+	}
+	if name == "" {
+		return nil, "", errors.New("name is required")
+	}
+	if lifetime <= 0 || lifetime > maxTokenLifetime {
+		return nil, "", fmt.Errorf(
+			"lifetime should be between 0 and %s",
+			maxTokenLifetime,
+		)
+	}
+
+	id, err := common.RandomID(8)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return nil, "", err
+	}
+	secretHex := hex.EncodeToString(secret)
+
+	tok := &APIToken{
+		ID:              id,
+		Name:            name,
+		OwnerThumbprint: owner,
+		Scope:           scope,
+		Created:         time.Now(),
+		Expires:         time.Now().Add(lifetime),
+		SecretHash:      hashTokenSecret(secretHex),
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	ts.tokens[id] = tok
+	err = ts.persist()
+	if err != nil {
+		delete(ts.tokens, id)
+		return nil, "", err
+	}
+	cp := *tok
+	cp.SecretHash = ""
+	return &cp, fmt.Sprintf("%s.%s", id, secretHex), nil
+}
+
+// Authenticate looks up the token for the given bearer value, and records
+// its usage for the given request. The usage is persisted periodically
+func (ts *TokenStore) Authenticate(
+	bearer string,
+	r *http.Request,
+) (*APIToken, error) {
+	parts := strings.SplitN(bearer, ".", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("malformed token")
+	}
+
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	tok, ok := ts.tokens[parts[0]]
+	if !ok || subtle.ConstantTimeCompare(
+		[]byte(tok.SecretHash),
+		[]byte(hashTokenSecret(parts[1])),
+	) != 1 {
+		return nil, errors.New("unknown token")
+	}
+	if !tok.Valid() {
+		return nil, fmt.Errorf("token %s is expired or revoked", tok.ID)
+	}
+
+	tok.LastUsed = time.Now()
+	tok.LastUsedBy = r.RemoteAddr
+	tok.LastUsedFor = fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+	tok.UseCount++
+	ts.usageChanged = true
+	cp := *tok
+	cp.SecretHash = ""
+	return &cp, nil
+}
+
+// Revoke revokes the token with the given ID
+func (ts *TokenStore) Revoke(id string) error {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	tok, ok := ts.tokens[id]
+	if !ok {
+		return fmt.Errorf("token %s not found", id)
+	}
+	if tok.Revoked.IsZero() {
+		tok.Revoked = time.Now()
+	}
+	return ts.persist()
+}
+
+// Get returns a copy of the token with the given ID
+func (ts *TokenStore) Get(id string) (*APIToken, bool) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	tok, ok := ts.tokens[id]
+	if !ok {
+		return nil, false
+	}
+	cp := *tok
+	cp.SecretHash = ""
+	return &cp, true
+}
+
+// List returns copies of the tokens owned by the given user, or all tokens if
+// owner is empty
+func (ts *TokenStore) List(owner string) []*APIToken {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	ret := []*APIToken{}
+	for _, tok := range ts.tokens {
+		if owner != "" && tok.OwnerThumbprint != owner {
+			continue
+		}
+		cp := *tok
+		cp.SecretHash = ""
+		ret = append(ret, &cp)
+	}
+	return ret
+}
+
+func hashTokenSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+type tokenContextKey struct{}
+
+// TokenFromRequest returns the API token the request was authenticated with,
+// or nil if it was authenticated with a client certificate
+func TokenFromRequest(r *http.Request) *APIToken {
+	tok, _ := r.Context().Value(tokenContextKey{}).(*APIToken)
+	return tok
+}
+
+// bearerToken returns the value of the request's bearer authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), true
+}
+
+// authenticateToken validates the bearer token on the request, if any, and
+// returns the request with the token attached to its context
+func (srv *HttpServer) authenticateToken(
+	r *http.Request,
+) (*http.Request, error) {
+	bearer, ok := bearerToken(r)
+	if !ok {
+		return r, nil
+	}
+	tok, err := srv.tokens.Authenticate(bearer, r)
+	if err != nil {
+		return nil, err
+	}
+	if srv.UserFromThumbprint(tok.OwnerThumbprint) == nil {
+		return nil, fmt.Errorf(
+			"owner of token %s is no longer authorized",
+			tok.ID,
+		)
+	}
+	ctx := context.WithValue(r.Context(), tokenContextKey{}, tok)
+	return r.WithContext(ctx), nil
+}
@@ -0,0 +1,188 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// The actions that are recorded in the audit log
+const (
+	AuditActionUserAdd              = "users.add"
+	AuditActionUserDelete           = "users.delete"
+	AuditActionUserRole             = "users.role"
+	AuditActionTokenCreate          = "tokens.create"
+	AuditActionTokenRevoke          = "tokens.revoke"
+	AuditActionMaintenance          = "system.maintenance"
+	AuditActionMaxAgents            = "system.maxagents"
+	AuditActionFairShare            = "system.fairshare"
+	AuditActionBudget               = "system.budget"
+	AuditActionSetLogLevel          = "system.loglevel.set"
+	AuditActionResetLogLevel        = "system.loglevel.reset"
+	AuditActionSourcesUpdate        = "sources.update"
+	AuditActionSchedule             = "testruns.schedule"
+	AuditActionClone                = "testruns.clone"
+	AuditActionScheduleDAG          = "testruns.scheduledag"
+	AuditActionPrioritize           = "testruns.prioritize"
+	AuditActionBudgetOverride       = "testruns.budgetoverride"
+	AuditActionTerminate            = "testruns.terminate"
+	AuditActionRetrySpawn           = "testruns.retryspawn"
+	AuditActionRecalcResults        = "testruns.recalcresults"
+	AuditActionConfirmPeak          = "testruns.confirmpeak"
+	AuditActionRedownloadOutputs    = "testruns.redownloadoutputs"
+	AuditActionSweepCancel          = "sweeps.cancel"
+	AuditActionSweepContinue        = "sweeps.continue"
+	AuditActionSweepScheduleMissing = "sweeps.schedulemissing"
+	AuditActionSweepPlotSave        = "sweepplots.save"
+	AuditActionSweepPlotDelete      = "sweepplots.delete"
+	AuditActionTemplateCreate       = "templates.create"
+	AuditActionTemplateUpdate       = "templates.update"
+	AuditActionTemplateDelete       = "templates.delete"
+	AuditActionTemplateSchedule     = "templates.schedule"
+	AuditActionScheduleCreate       = "schedules.create"
+	AuditActionScheduleUpdate       = "schedules.update"
+	AuditActionScheduleDelete       = "schedules.delete"
+	AuditActionScheduleFire         = "schedules.fire"
+	AuditActionBisectCreate         = "bisections.create"
+	AuditActionBisectCancel         = "bisections.cancel"
+)
+
+// AuditEntry records a single mutating action performed through the API
+type AuditEntry struct {
+	Time time.Time `json:"time"`
+	// The thumbprint and name of the user that performed the action. Empty
+	// for actions that do not require authentication, such as adding the
+	// first user
+	Thumbprint string `json:"thumbprint"`
+	User       string `json:"user"`
+	Action     string `json:"action"`
+	// The object the action was performed on, for instance a test run ID,
+	// sweep ID or user thumbprint
+	Target  string      `json:"target"`
+	Payload interface{} `json:"payload,omitempty"`
+	// The ID of the API token the action was performed with, if any
+	TokenID string `json:"tokenID,omitempty"`
+}
+
+// AuditFilter selects entries from the audit log. Empty fields match all
+// entries
+type AuditFilter struct {
+	// Matches either the user's thumbprint or name
+	User   string
+	Action string
+	From   time.Time
+	To     time.Time
+}
+
+// Matches returns true if the entry matches all criteria of the filter
+func (f AuditFilter) Matches(e AuditEntry) bool {
+	if f.User != "" && e.Thumbprint != f.User && e.User != f.User {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && e.Time.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// AuditLog is an append-only log of the actions users performed. Every entry
+// is written as a JSON line to a file in the data directory
+type AuditLog struct {
+	path string
+	lock sync.Mutex
+}
+
+// NewAuditLog returns the audit log stored in the data directory
+func NewAuditLog() *AuditLog {
+	return &AuditLog{path: filepath.Join(common.DataDir(), "audit.jsonl")}
+}
+
+// Append writes an entry to the end of the audit log
+func (a *AuditLog) Append(e AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// Query returns all entries in the audit log that match the filter, in the
+// order in which they were recorded
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	ret := []AuditEntry{}
+	f, err := os.Open(a.path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse audit log entry: %v", err)
+		}
+		if filter.Matches(e) {
+			ret = append(ret, e)
+		}
+	}
+	return ret, scanner.Err()
+}
+
+// audit records that the user that made request r performed action on target.
+// Failures to write the audit log are logged but do not fail the request,
+// since the action itself has already been performed
+func (h *HttpServer) audit(
+	r *http.Request,
+	action, target string,
+	payload interface{},
+) {
+	e := AuditEntry{
+		Time:    time.Now(),
+		Action:  action,
+		Target:  target,
+		Payload: payload,
+	}
+	usr, err := h.UserFromRequest(r)
+	if err == nil {
+		e.Thumbprint = usr.Thumbprint
+		e.User = usr.CN
+	}
+	if tok := TokenFromRequest(r); tok != nil {
+		e.TokenID = tok.ID
+	}
+
+	err = h.auditLog.Append(e)
+	if err != nil {
+		logger.Errorf("Unable to write audit log entry for %s: %v", action, err)
+	}
+}
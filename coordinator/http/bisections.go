@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/bisect"
+)
+
+// canEditBisection returns true if the user that made request r can cancel
+// the bisection, which is limited to its owner and admins
+func (h *HttpServer) canEditBisection(
+	r *http.Request,
+	b *bisect.Bisection,
+) bool {
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		return false
+	}
+	if usr.Thumbprint == b.OwnerThumbprint {
+		return true
+	}
+	role, err := h.RoleFromRequest(r)
+	return err == nil && role.Includes(RoleAdmin)
+}
+
+// bisectionFromRequest looks up the bisection in the request's path and
+// writes an error response if it does not exist, or if the user is not
+// allowed to change it and edit is true
+func (h *HttpServer) bisectionFromRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+	edit bool,
+) (*bisect.Bisection, bool) {
+	b, err := h.bm.Get(id)
+	if err != nil {
+		http.Error(w, "Not found", 404)
+		return nil, false
+	}
+	if edit && !h.canEditBisection(r, b) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return b, true
+}
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 var frontendRunCache = sync.Map{}
@@ -56,11 +55,11 @@ func (h *HttpServer) makeFrontendRun(
 	}
 	b, err := json.Marshal(tr)
 	if err != nil {
-		logging.Warnf("Could not marshal testruns: %v", err)
+		logger.Warnf("Could not marshal testruns: %v", err)
 	}
 	err = json.Unmarshal(b, &res)
 	if err != nil {
-		logging.Warnf("Could not unmarshal testruns: %v", err)
+		logger.Warnf("Could not unmarshal testruns: %v", err)
 	}
 	res.RoleCounts = runRoleCounts
 	if tr.Result != nil {
@@ -97,8 +96,12 @@ type FrontendTestRunListEntry struct {
 	Architecture             string                     `json:"architectureID"`
 	SweepID                  string                     `json:"sweepID"`
 	SweepOneAtATime          bool                       `json:"sweepOneAtATime"`
+	ParentID                 string                     `json:"parentID"`
+	ScheduleID               string                     `json:"scheduleID"`
+	BisectID                 string                     `json:"bisectID"`
 	RoleCounts               []FrontendTestRunRoleCount `json:"roleCounts"`
 	Details                  string                     `json:"details"`
+	ScheduleReason           string                     `json:"scheduleReason"`
 	AvgThroughput            float64                    `json:"avgThroughput"`
 	TailLatency              float64                    `json:"tailLatency"`
 	PerformanceDataAvailable bool                       `json:"performanceDataAvailable"`
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// auditFilterFromRequest reads the audit log filter from the query parameters
+// user, action, from and to. The time range is given in RFC3339 format
+func auditFilterFromRequest(r *http.Request) (AuditFilter, error) {
+	q := r.URL.Query()
+	filter := AuditFilter{
+		User:   q.Get("user"),
+		Action: q.Get("action"),
+	}
+	var err error
+	if from := q.Get("from"); from != "" {
+		filter.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from time: %v", err)
+		}
+	}
+	if to := q.Get("to"); to != "" {
+		filter.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to time: %v", err)
+		}
+	}
+	return filter, nil
+}
+
+func (h *HttpServer) auditLogHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := h.auditLog.Query(filter)
+	if err != nil {
+		logger.Errorf("Error querying audit log: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	writeJson(w, entries)
+}
+
+func (h *HttpServer) auditLogCsvHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	filter, err := auditFilterFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := h.auditLog.Query(filter)
+	if err != nil {
+		logger.Errorf("Error querying audit log: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/csv")
+	w.Header().
+		Add("Content-Disposition", "attachment; filename=\"audit-log.csv\"")
+	w.WriteHeader(200)
+	cw := csv.NewWriter(w)
+	err = cw.Write([]string{
+		"time",
+		"thumbprint",
+		"user",
+		"tokenID",
+		"action",
+		"target",
+		"payload",
+	})
+	if err != nil {
+		logger.Errorf("Error writing output: %v", err)
+		return
+	}
+	for _, e := range entries {
+		payload := ""
+		if e.Payload != nil {
+			b, err := json.Marshal(e.Payload)
+			if err == nil {
+				payload = string(b)
+			}
+		}
+		err = cw.Write([]string{
+			e.Time.Format(time.RFC3339),
+			e.Thumbprint,
+			e.User,
+			e.TokenID,
+			e.Action,
+			e.Target,
+			payload,
+		})
+		if err != nil {
+			logger.Errorf("Error writing output: %v", err)
+			return
+		}
+	}
+	cw.Flush()
+}
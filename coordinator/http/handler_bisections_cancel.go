@@ -0,0 +1,28 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// cancelBisectionHandler stops a running bisection and cancels its queued
+// test runs
+func (h *HttpServer) cancelBisectionHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	b, ok := h.bisectionFromRequest(w, r, params["bisectID"], true)
+	if !ok {
+		return
+	}
+
+	b, err := h.bm.Cancel(b.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionBisectCancel, b.ID, nil)
+	writeJson(w, b)
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/bisect"
+)
+
+// createBisectionHandler starts a bisection between a good and a bad commit
+// and schedules the test runs for its first step
+func (h *HttpServer) createBisectionHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	cfg := bisect.BisectConfig{}
+	err := json.NewDecoder(r.Body).Decode(&cfg)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	b, err := h.bm.Create(usr.Thumbprint, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionBisectCreate, b.ID, cfg)
+	writeJson(w, b)
+}
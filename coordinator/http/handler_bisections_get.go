@@ -0,0 +1,19 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *HttpServer) getBisectionHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	b, ok := h.bisectionFromRequest(w, r, params["bisectID"], false)
+	if !ok {
+		return
+	}
+	writeJson(w, b)
+}
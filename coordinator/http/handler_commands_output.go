@@ -3,11 +3,9 @@ package http
 import (
 	"fmt"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) commandOutputHandler(
@@ -30,8 +28,8 @@ func (h *HttpServer) commandOutputHandler(
 		tail = -1
 	}
 	b, err := h.awsm.ReadFromS3(common.S3Download{
-		SourceRegion: os.Getenv("AWS_REGION"),
-		SourceBucket: os.Getenv("OUTPUTS_S3_BUCKET"),
+		SourceRegion: h.cfg.AWS.Region,
+		SourceBucket: h.cfg.AWS.OutputsBucket,
 		SourcePath: fmt.Sprintf(
 			"command-outputs/%s/cmd_%s_std%s.txt",
 			cmdID[:8],
@@ -42,7 +40,7 @@ func (h *HttpServer) commandOutputHandler(
 	res := ""
 	if err != nil {
 		res = fmt.Sprintf("Error reading command stream: %v", err)
-		logging.Errorf("Error reading output: %v", err)
+		logger.Errorf("Error reading output: %v", err)
 	} else {
 		res = string(b)
 	}
@@ -50,6 +48,6 @@ func (h *HttpServer) commandOutputHandler(
 	w.WriteHeader(http.StatusOK)
 	_, err = w.Write([]byte(res))
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 }
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+)
+
+// userCost is the total actual cost of the test runs created by a user
+type userCost struct {
+	Thumbprint string `json:"thumbprint"`
+	Name       string `json:"name"`
+	TestRuns   int    `json:"testRuns"`
+	awsmgr.CostSummary
+}
+
+// userCostsHandler returns the total actual cost per user of the test runs
+// created between the query parameters since and until (RFC3339, both
+// optional), most expensive first
+func (h *HttpServer) userCostsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var since, until time.Time
+	for name, t := range map[string]*time.Time{
+		"since": &since,
+		"until": &until,
+	} {
+		s := q.Get(name)
+		if s == "" {
+			continue
+		}
+		var err error
+		*t, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid "+name+" time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	costs := map[string]*userCost{}
+	for _, tr := range h.tr.GetTestRuns() {
+		if !since.IsZero() && tr.Created.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !tr.Created.Before(until) {
+			continue
+		}
+		uc, ok := costs[tr.CreatedByThumbprint]
+		if !ok {
+			uc = &userCost{
+				Thumbprint:  tr.CreatedByThumbprint,
+				CostSummary: awsmgr.NewCostSummary(),
+			}
+			if usr := h.UserFromThumbprint(tr.CreatedByThumbprint); usr != nil {
+				uc.Name = usr.CN
+			}
+			costs[tr.CreatedByThumbprint] = uc
+		}
+		uc.TestRuns++
+		uc.Add(h.tr.TestRunCost(tr))
+	}
+
+	res := make([]*userCost, 0, len(costs))
+	for _, uc := range costs {
+		res = append(res, uc)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Cost > res[j].Cost
+	})
+	writeJson(w, res)
+}
@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
-
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) generateReportHandler(
@@ -19,7 +17,7 @@ func (h *HttpServer) generateReportHandler(
 	defer r.Body.Close()
 	err := json.NewDecoder(r.Body).Decode(&def)
 	if err != nil {
-		logging.Warnf("Unable to create report: %v", err)
+		logger.Warnf("Unable to create report: %v", err)
 		http.Error(w, "Internal server error", 500)
 		return
 	}
@@ -35,7 +33,7 @@ func (h *HttpServer) generateReportHandler(
 		Add("Content-Disposition", "attachment; filename=\"report.html\"")
 	_, err = w.Write([]byte(result))
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 
 }
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/schedules"
+)
+
+func (h *HttpServer) createScheduleHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	cfg := schedules.ScheduleConfig{}
+	err := json.NewDecoder(r.Body).Decode(&cfg)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+	if !h.canUseTemplate(r, cfg.TemplateID) {
+		http.Error(w, "Template not found", http.StatusBadRequest)
+		return
+	}
+
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	s, err := h.sm.Create(usr.Thumbprint, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionScheduleCreate, s.ID, cfg)
+	writeJson(w, s)
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *HttpServer) deleteScheduleHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	s, ok := h.scheduleFromRequest(w, r, params["scheduleID"], true)
+	if !ok {
+		return
+	}
+
+	err := h.sm.Delete(s.ID)
+	if err != nil {
+		logger.Errorf("Error deleting schedule %s: %v", s.ID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	h.audit(r, AuditActionScheduleDelete, s.ID, nil)
+	writeJsonOK(w)
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// fireScheduleHandler creates the test runs for a schedule right away,
+// without waiting for its next firing
+func (h *HttpServer) fireScheduleHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	s, ok := h.scheduleFromRequest(w, r, params["scheduleID"], true)
+	if !ok {
+		return
+	}
+
+	s, err := h.sm.Fire(s.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionScheduleFire, s.ID, map[string]interface{}{
+		"commit":    s.LastCommit,
+		"scheduled": s.LastTestRunIDs,
+	})
+	writeJson(w, s)
+}
@@ -0,0 +1,19 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *HttpServer) getScheduleHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	s, ok := h.scheduleFromRequest(w, r, params["scheduleID"], false)
+	if !ok {
+		return
+	}
+	writeJson(w, s)
+}
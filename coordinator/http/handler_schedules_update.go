@@ -0,0 +1,41 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/schedules"
+)
+
+// updateScheduleHandler replaces the settings of a schedule
+func (h *HttpServer) updateScheduleHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	params := mux.Vars(r)
+	s, ok := h.scheduleFromRequest(w, r, params["scheduleID"], true)
+	if !ok {
+		return
+	}
+
+	cfg := schedules.ScheduleConfig{}
+	err := json.NewDecoder(r.Body).Decode(&cfg)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+	if !h.canUseTemplate(r, cfg.TemplateID) {
+		http.Error(w, "Template not found", http.StatusBadRequest)
+		return
+	}
+
+	s, err = h.sm.Update(s.ID, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionScheduleUpdate, s.ID, cfg)
+	writeJson(w, s)
+}
@@ -11,5 +11,6 @@ func (h *HttpServer) sourcesUpdateHandler(
 		http.Error(w, err.Error(), 500)
 		return
 	}
+	h.audit(r, AuditActionSourcesUpdate, "", nil)
 	writeJsonOK(w)
 }
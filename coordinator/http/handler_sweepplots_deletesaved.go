@@ -37,5 +37,6 @@ func (h *HttpServer) deleteSavedSweepPlotHandler(
 		),
 	)
 
+	h.audit(r, AuditActionSweepPlotDelete, sweepID+"/"+plotID, nil)
 	writeJsonOK(w)
 }
@@ -9,7 +9,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) listSavedSweepPlotsHandler(
@@ -34,19 +33,19 @@ func (h *HttpServer) listSavedSweepPlotsHandler(
 				raw := map[string]interface{}{}
 				f, err := os.OpenFile(path, os.O_RDONLY, 0644)
 				if err != nil {
-					logging.Warnf("Unable to load saved plot %s: %v", path, err)
+					logger.Warnf("Unable to load saved plot %s: %v", path, err)
 					return nil
 				}
 				defer f.Close()
 				s, err := os.Stat(path)
 				if err != nil {
-					logging.Warnf("Unable to stat saved plot %s: %v", path, err)
+					logger.Warnf("Unable to stat saved plot %s: %v", path, err)
 					return nil
 				}
 
 				err = json.NewDecoder(f).Decode(&raw)
 				if err != nil {
-					logging.Warnf("Unable to read saved plot %s: %v", path, err)
+					logger.Warnf("Unable to read saved plot %s: %v", path, err)
 					return nil
 				}
 				title := ""
@@ -72,7 +71,7 @@ func (h *HttpServer) listSavedSweepPlotsHandler(
 		},
 	)
 	if err != nil {
-		logging.Errorf("Error reading saved sweepplots: %v", err)
+		logger.Errorf("Error reading saved sweepplots: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
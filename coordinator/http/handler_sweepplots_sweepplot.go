@@ -1,24 +1,46 @@
 package http
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) sweepPlotHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
-	plotOutput, err := h.generateSweepPlot(r.Body)
+	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		logging.Warnf("Unable to create plot: %v", err)
+		logger.Warnf("Unable to create plot: %v", err)
 		http.Error(w, "Internal server error", 500)
 		return
 	}
+	plotOutput, err := h.generateSweepPlot(bytes.NewReader(reqBody))
+	if err != nil {
+		logger.Warnf("Unable to create plot: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	// Only plots that are saved alter the state of the system
+	var saveReq struct {
+		Save    bool   `json:"save"`
+		SweepID string `json:"sweepID"`
+	}
+	if json.Unmarshal(reqBody, &saveReq) == nil && saveReq.Save {
+		h.audit(
+			r,
+			AuditActionSweepPlotSave,
+			saveReq.SweepID,
+			json.RawMessage(reqBody),
+		)
+	}
 	randomID, err := common.RandomID(12)
 	if err != nil {
-		logging.Warnf("Unable to create plot: %v", err)
+		logger.Warnf("Unable to create plot: %v", err)
 		http.Error(w, "Internal server error", 500)
 		return
 	}
@@ -29,6 +51,6 @@ func (h *HttpServer) sweepPlotHandler(w http.ResponseWriter, r *http.Request) {
 		Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.png\"", randName))
 	_, err = w.Write(plotOutput)
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 }
@@ -25,5 +25,11 @@ func (h *HttpServer) cancelSweepRuns(w http.ResponseWriter, r *http.Request) {
 			"Sweep canceled by user",
 		)
 	}
+	h.audit(
+		r,
+		AuditActionSweepCancel,
+		sweepID,
+		map[string]interface{}{"canceledRuns": len(sweepRuns)},
+	)
 	writeJsonOK(w)
 }
@@ -17,5 +17,6 @@ func (h *HttpServer) continueSweep(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
+	h.audit(r, AuditActionSweepContinue, sweepID, nil)
 	writeJsonOK(w)
 }
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+)
+
+// sweepCostHandler returns the total actual cost of the test runs in a sweep,
+// along with the cost per test run
+func (h *HttpServer) sweepCostHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	sweepID := params["sweepID"]
+
+	total := awsmgr.NewCostSummary()
+	runs := map[string]float64{}
+	for _, tr := range h.tr.GetTestRuns() {
+		if tr.SweepID != sweepID {
+			continue
+		}
+		c := h.tr.TestRunCost(tr)
+		total.Add(c)
+		runs[tr.ID] = c.Cost
+	}
+	if len(runs) == 0 {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	writeJson(w, map[string]interface{}{
+		"sweepID":       sweepID,
+		"cost":          total.Cost,
+		"instanceHours": total.InstanceHours,
+		"unpricedTypes": total.UnpricedTypes,
+		"testRuns":      runs,
+	})
+}
@@ -5,7 +5,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) scheduleMissingSweepRuns(
@@ -18,7 +17,7 @@ func (h *HttpServer) scheduleMissingSweepRuns(
 
 	expectedRuns := common.FindMissingSweepRuns(trs, sweepID)
 
-	logging.Infof(
+	logger.Infof(
 		"Sweep ID is missing %d runs, scheduling them...",
 		len(expectedRuns),
 	)
@@ -29,5 +28,11 @@ func (h *HttpServer) scheduleMissingSweepRuns(
 		expectedRuns[i].AWSInstancesStopped = false
 		h.tr.ScheduleTestRun(expectedRuns[i])
 	}
+	h.audit(
+		r,
+		AuditActionSweepScheduleMissing,
+		sweepID,
+		map[string]interface{}{"scheduledRuns": len(expectedRuns)},
+	)
 	writeJsonOK(w)
 }
@@ -0,0 +1,11 @@
+package http
+
+import (
+	"net/http"
+)
+
+// configHandler returns the coordinator's configuration with its secrets
+// redacted
+func (h *HttpServer) configHandler(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, h.cfg.Redacted())
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+)
+
+// defaultLogComponent is the component name used in the API to refer to the
+// default log level, which applies to components without an override
+const defaultLogComponent = "default"
+
+func (h *HttpServer) loggingHandler(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, map[string]interface{}{
+		"json":       logging.JSONOutput(),
+		"default":    logging.GetLogLevel(),
+		"components": logging.ComponentLogLevels(),
+	})
+}
+
+func (h *HttpServer) setLogLevelHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	lvl, err := logging.ParseLogLevel(params["level"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	component := params["component"]
+	if component == defaultLogComponent {
+		logging.SetLogLevel(int(lvl))
+	} else {
+		logging.SetComponentLogLevel(component, lvl)
+	}
+	logger.Infof("Log level for %s changed to %s", component, lvl)
+	h.audit(
+		r,
+		AuditActionSetLogLevel,
+		component,
+		map[string]interface{}{"level": lvl},
+	)
+	writeJsonOK(w)
+}
+
+func (h *HttpServer) resetLogLevelHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	logging.ResetComponentLogLevel(params["component"])
+	h.audit(r, AuditActionResetLogLevel, params["component"], nil)
+	writeJsonOK(w)
+}
@@ -16,7 +16,14 @@ func (h *HttpServer) systemMaintenanceHandler(
 		return
 	}
 	if r.Method == "PUT" {
-		h.coord.SetMaintenance(!h.coord.GetMaintenance())
+		maintenance := !h.coord.GetMaintenance()
+		h.coord.SetMaintenance(maintenance)
+		h.audit(
+			r,
+			AuditActionMaintenance,
+			"",
+			map[string]interface{}{"maintenance": maintenance},
+		)
 		writeJsonOK(w)
 		return
 	}
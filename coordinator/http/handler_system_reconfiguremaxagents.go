@@ -25,5 +25,6 @@ func (h *HttpServer) reconfigureMaxAgentsHandler(
 		return
 	}
 
+	h.audit(r, AuditActionMaxAgents, "", map[string]interface{}{"max": max})
 	writeJsonOK(w)
 }
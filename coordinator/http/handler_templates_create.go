@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+type createTemplateBody struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Shared      bool            `json:"shared"`
+	Spec        *common.TestRun `json:"spec"`
+}
+
+func (h *HttpServer) createTemplateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	body := createTemplateBody{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Spec == nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	t, err := h.tm.Create(
+		body.Name,
+		body.Description,
+		usr.Thumbprint,
+		body.Shared,
+		body.Spec,
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionTemplateCreate, t.ID, body)
+	writeJson(w, t)
+}
@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *HttpServer) deleteTemplateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	t, ok := h.templateFromRequest(w, r, params["templateID"])
+	if !ok {
+		return
+	}
+	if !h.canEditTemplate(r, t) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	err := h.tm.Delete(t.ID)
+	if err != nil {
+		logger.Errorf("Error deleting template %s: %v", t.ID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	h.audit(r, AuditActionTemplateDelete, t.ID, nil)
+	writeJsonOK(w)
+}
@@ -0,0 +1,16 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *HttpServer) getTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	t, ok := h.templateFromRequest(w, r, params["templateID"])
+	if !ok {
+		return
+	}
+	writeJson(w, t)
+}
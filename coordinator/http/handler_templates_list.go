@@ -0,0 +1,20 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/templates"
+)
+
+func (h *HttpServer) listTemplatesHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	res := []*templates.Template{}
+	for _, t := range h.tm.List() {
+		if h.canViewTemplate(r, t) {
+			res = append(res, t)
+		}
+	}
+	writeJson(w, res)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+type scheduleTemplateBody struct {
+	// The version of the template to schedule, 0 (or omitted) for the latest
+	Version int `json:"version"`
+	// Test run fields, keyed by their JSON name, that replace the ones in
+	// the template
+	Overrides map[string]json.RawMessage `json:"overrides"`
+}
+
+// scheduleTemplateHandler schedules a test run (or sweep) from a template,
+// after applying the overrides from the request
+func (h *HttpServer) scheduleTemplateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	params := mux.Vars(r)
+	t, ok := h.templateFromRequest(w, r, params["templateID"])
+	if !ok {
+		return
+	}
+
+	body := scheduleTemplateBody{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil && err != io.EOF {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	spec, version, err := h.tm.Spec(t.ID, body.Version)
+	if err != nil {
+		http.Error(w, "Not found", 404)
+		return
+	}
+	tr, err := common.ApplyTestRunOverrides(spec, body.Overrides)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tr.TemplateID = t.ID
+	tr.TemplateVersion = version
+
+	res, err := h.scheduleTestRun(r, tr)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	h.audit(r, AuditActionTemplateSchedule, t.ID, map[string]interface{}{
+		"version":   version,
+		"overrides": body.Overrides,
+		"scheduled": res.TestRunIDs,
+	})
+	writeJson(w, map[string]interface{}{
+		"ok":         true,
+		"sweepID":    res.SweepID,
+		"testRunIDs": res.TestRunIDs,
+	})
+}
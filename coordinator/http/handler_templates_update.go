@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/templates"
+)
+
+// updateTemplateHandler changes a template's name, description or
+// visibility, and stores a new version if a spec is given
+func (h *HttpServer) updateTemplateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	params := mux.Vars(r)
+	t, ok := h.templateFromRequest(w, r, params["templateID"])
+	if !ok {
+		return
+	}
+	if !h.canEditTemplate(r, t) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	upd := templates.TemplateUpdate{}
+	err := json.NewDecoder(r.Body).Decode(&upd)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	t, err = h.tm.Update(t.ID, usr.Thumbprint, upd)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.audit(r, AuditActionTemplateUpdate, t.ID, upd)
+	writeJson(w, t)
+}
@@ -14,7 +14,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 type packetBucket struct {
@@ -65,8 +64,8 @@ func (h *HttpServer) testRunBandwidthData(
 			if _, err := os.Stat(packetFiles[i]); os.IsNotExist(err) {
 
 				downloads = append(downloads, common.S3Download{
-					SourceRegion: os.Getenv("AWS_REGION"),
-					SourceBucket: os.Getenv("OUTPUTS_S3_BUCKET"),
+					SourceRegion: h.cfg.AWS.Region,
+					SourceBucket: h.cfg.AWS.OutputsBucket,
 					SourcePath: fmt.Sprintf(
 						"command-outputs/%s/cmd_%s_packets.bin",
 						cmd.CommandID[:8],
@@ -79,7 +78,7 @@ func (h *HttpServer) testRunBandwidthData(
 		}
 
 		if len(downloads) > 0 {
-			logging.Infof("Downloading %d files from S3")
+			logger.Infof("Downloading %d files from S3", len(downloads))
 			err = h.awsm.DownloadMultipleFromS3(downloads)
 			if err != nil && !os.IsExist(err) {
 				http.Error(w, "Internal Server Error", 500)
@@ -94,7 +93,7 @@ func (h *HttpServer) testRunBandwidthData(
 			wg.Add(1)
 			go func() {
 				for path := range packetFileChan {
-					logging.Infof("Processing packet file %s", path)
+					logger.Infof("Processing packet file %s", path)
 					f, err := os.Open(path)
 					if err != nil && !os.IsExist(err) {
 						http.Error(w, "Internal Server Error", 500)
@@ -152,22 +151,22 @@ func (h *HttpServer) testRunBandwidthData(
 
 		json, err := json.Marshal(result)
 		if err != nil {
-			logging.Errorf("Error: %v", err)
+			logger.Errorf("Error: %v", err)
 		}
 		err = os.WriteFile(bandWidthFile, json, 0644)
 		if err != nil {
-			logging.Errorf("Error: %v", err)
+			logger.Errorf("Error: %v", err)
 		}
 	} else {
 		b, err := os.ReadFile(bandWidthFile)
 		if err != nil {
-			logging.Errorf("Error: %v", err)
+			logger.Errorf("Error: %v", err)
 			http.Error(w, "Internal Server Error", 500)
 			return
 		}
 		err = json.Unmarshal(b, &result)
 		if err != nil {
-			logging.Errorf("Error: %v", err)
+			logger.Errorf("Error: %v", err)
 			http.Error(w, "Internal Server Error", 500)
 			return
 		}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
+)
+
+// budgetHandler returns the budget configuration of the scheduler and the
+// spending in the current month
+func (h *HttpServer) budgetHandler(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, h.tr.BudgetStatus())
+}
+
+// updateBudgetHandler replaces the budget configuration of the scheduler: the
+// monthly and per-user limits and the warning thresholds
+func (h *HttpServer) updateBudgetHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	var b testruns.BudgetConfig
+	err := json.NewDecoder(r.Body).Decode(&b)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+	err = b.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.tr.SetBudget(b)
+	if err != nil {
+		logger.Errorf("Error updating budget config: %v", err)
+		http.Error(w, "Internal Server Error", 500)
+		return
+	}
+	h.audit(r, AuditActionBudget, "", b)
+	writeJsonOK(w)
+}
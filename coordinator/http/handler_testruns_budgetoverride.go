@@ -0,0 +1,31 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// budgetOverrideHandler allows a test run to start even if its estimated
+// cost exceeds the remaining budget (PUT), or withdraws that again (DELETE)
+func (h *HttpServer) budgetOverrideHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	runID := params["runID"]
+	tr, ok := h.tr.GetTestRun(runID)
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+	override := r.Method == http.MethodPut
+	h.tr.SetBudgetOverride(tr, override)
+	h.audit(
+		r,
+		AuditActionBudgetOverride,
+		runID,
+		map[string]interface{}{"override": override},
+	)
+	writeJsonOK(w)
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// testRunBundleHandler returns an archive with everything needed to run the
+// test run outside of the controller, see TestRunManager.ManualRunBundle
+func (h *HttpServer) testRunBundleHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	runID := params["runID"]
+
+	run, ok := h.tr.GetTestRun(runID)
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	bundle, err := h.tr.ManualRunBundle(run)
+	if err != nil {
+		logger.Errorf("Error creating bundle for test run %s: %v", runID, err)
+		http.Error(
+			w,
+			fmt.Sprintf("Unable to create bundle: %v", err),
+			http.StatusBadRequest,
+		)
+		return
+	}
+	w.Header().Add("Content-Type", "application/tar+gzip")
+	w.Header().
+		Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"testrun-bundle-%s.tar.gz\"", runID))
+	_, err = w.Write(bundle)
+	if err != nil {
+		logger.Errorf("Error writing output: %v", err)
+	}
+}
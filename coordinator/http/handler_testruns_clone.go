@@ -0,0 +1,78 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// cloneTestRunHandler schedules a new run with the configuration of an
+// existing test run, in which the fields from the request body (keyed by
+// their JSON name) are replaced. The new run links back to its parent and
+// records which fields differ from it
+func (h *HttpServer) cloneTestRunHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	params := mux.Vars(r)
+	runID := params["runID"]
+	parent, ok := h.tr.GetTestRun(runID)
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	overrides := map[string]json.RawMessage{}
+	err := json.NewDecoder(r.Body).Decode(&overrides)
+	if err != nil && err != io.EOF {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	base, err := common.TestRunSpec(parent)
+	if err != nil {
+		logger.Errorf("Error copying test run %s: %v", runID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	// The parent may have been expanded from a sweep or repeated run, its
+	// clone is a single run unless the overrides say otherwise. The diff is
+	// taken against this reset copy so only the overrides show up in it
+	base.Sweep = ""
+	base.SweepID = ""
+	base.Repeat = 1
+
+	tr, err := common.ApplyTestRunOverrides(base, overrides)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	diff, err := common.DiffTestRunSpecs(base, tr)
+	if err != nil {
+		logger.Errorf("Error comparing test runs: %v", err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	tr.ParentID = parent.ID
+	tr.ParentDiff = diff
+
+	res, err := h.scheduleTestRun(r, tr)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	h.audit(r, AuditActionClone, runID, map[string]interface{}{
+		"overrides": overrides,
+		"scheduled": res.TestRunIDs,
+	})
+	writeJson(w, map[string]interface{}{
+		"ok":         true,
+		"sweepID":    res.SweepID,
+		"testRunIDs": res.TestRunIDs,
+		"diff":       diff,
+	})
+}
@@ -6,7 +6,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 type confirmPeakBody struct {
@@ -22,7 +21,7 @@ func (h *HttpServer) testRunConfirmPeakHandler(
 	body := confirmPeakBody{}
 	err := json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		logging.Errorf("Error parsing request: %s", err.Error())
+		logger.Errorf("Error parsing request: %s", err.Error())
 		http.Error(w, "Request format incorrect", 500)
 		return
 	}
@@ -42,7 +41,7 @@ func (h *HttpServer) testRunConfirmPeakHandler(
 	if body.ForceRerunConfirmation {
 		trs, err := common.GetConfirmationPeakFindingRuns([]*common.TestRun{run})
 		if err != nil {
-			logging.Errorf("Error getting confirmation runs to rerun: %v", err)
+			logger.Errorf("Error getting confirmation runs to rerun: %v", err)
 			http.Error(w, "Internal Server Error", 500)
 			return
 		}
@@ -50,6 +49,7 @@ func (h *HttpServer) testRunConfirmPeakHandler(
 	} else {
 		h.tr.ContinueSweep(run, run.SweepID)
 	}
+	h.audit(r, AuditActionConfirmPeak, runID, body)
 
 	writeJsonOK(w)
 }
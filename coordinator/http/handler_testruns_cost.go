@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// testRunCostHandler returns the actual cost of the instances that were
+// launched for a test run, based on their recorded runtime and the price
+// table in the configuration
+func (h *HttpServer) testRunCostHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	runID := params["runID"]
+
+	run, ok := h.tr.GetTestRun(runID)
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	writeJson(w, h.tr.TestRunCost(run))
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// dagNode is a test run (or sweep) in a DAG scheduling request
+type dagNode struct {
+	// The name by which other nodes in the request refer to this one
+	Name string          `json:"name"`
+	Spec *common.TestRun `json:"spec"`
+	// The dependencies of this node. The testRunID of a dependency is either
+	// the name of another node in the request, or the ID of an existing test
+	// run
+	DependsOn []common.RunDependency `json:"dependsOn"`
+}
+
+type scheduleDAGBody struct {
+	Runs []dagNode `json:"runs"`
+}
+
+// sortDAG validates the nodes and their dependencies, and returns the nodes
+// in an order in which every node comes after the nodes it depends on. Nodes
+// cannot depend on sweeps that schedule their runs one at a time, since only
+// the first run exists when the dependent node is scheduled
+func (h *HttpServer) sortDAG(nodes []dagNode) ([]dagNode, error) {
+	byName := map[string]int{}
+	for i, n := range nodes {
+		if n.Name == "" {
+			return nil, fmt.Errorf("run %d has no name", i)
+		}
+		if _, ok := byName[n.Name]; ok {
+			return nil, fmt.Errorf("duplicate run name %s", n.Name)
+		}
+		if n.Spec == nil {
+			return nil, fmt.Errorf("run %s has no spec", n.Name)
+		}
+		byName[n.Name] = i
+	}
+
+	// Count the dependencies on other nodes, and check that the others refer
+	// to existing test runs
+	pending := make([]int, len(nodes))
+	dependents := make([][]int, len(nodes))
+	for i, n := range nodes {
+		for _, d := range n.DependsOn {
+			err := d.Validate()
+			if err != nil {
+				return nil, fmt.Errorf("run %s: %v", n.Name, err)
+			}
+			if j, ok := byName[d.TestRunID]; ok {
+				if spec := nodes[j].Spec; spec.SweepOneAtATime ||
+					spec.Sweep == "peak" {
+					return nil, fmt.Errorf(
+						"run %s cannot depend on run %s, which is a sweep "+
+							"that schedules its runs one at a time",
+						n.Name,
+						d.TestRunID,
+					)
+				}
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+				continue
+			}
+			if _, ok := h.tr.GetTestRun(d.TestRunID); !ok {
+				return nil, fmt.Errorf(
+					"run %s depends on unknown run %s",
+					n.Name,
+					d.TestRunID,
+				)
+			}
+		}
+	}
+
+	sorted := make([]dagNode, 0, len(nodes))
+	ready := []int{}
+	for i := range nodes {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, nodes[i])
+		for _, j := range dependents[i] {
+			pending[j]--
+			if pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+	if len(sorted) != len(nodes) {
+		return nil, fmt.Errorf("the dependencies between the runs form a cycle")
+	}
+	return sorted, nil
+}
+
+// scheduleDAGHandler schedules a set of test runs that depend on each other
+// in one request. A node that depends on another node in the request depends
+// on all test runs that were scheduled for it
+func (h *HttpServer) scheduleDAGHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	body := scheduleDAGBody{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+	if len(body.Runs) == 0 {
+		http.Error(w, "No runs to schedule", http.StatusBadRequest)
+		return
+	}
+	nodes, err := h.sortDAG(body.Runs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Validate all runs before scheduling any of them, such that an invalid
+	// run does not leave the DAG partially scheduled. The dependencies on
+	// other runs of the DAG are only added when scheduling
+	for _, n := range nodes {
+		if errs := h.tr.ValidateTestRunSpec(n.Spec); len(errs) > 0 {
+			writeJsonStatus(w, http.StatusBadRequest, map[string]interface{}{
+				"ok":     false,
+				"run":    n.Name,
+				"errors": errs,
+			})
+			return
+		}
+	}
+
+	scheduled := map[string]*scheduleResult{}
+	targets := []string{}
+	for _, n := range nodes {
+		tr := n.Spec
+		for _, d := range n.DependsOn {
+			res, ok := scheduled[d.TestRunID]
+			if !ok {
+				tr.Dependencies = append(tr.Dependencies, d)
+				continue
+			}
+			for _, id := range res.TestRunIDs {
+				resolved := d
+				resolved.TestRunID = id
+				tr.Dependencies = append(tr.Dependencies, resolved)
+			}
+		}
+
+		res, err := h.scheduleTestRun(r, tr)
+		if err != nil {
+			logger.Errorf("Error scheduling run %s of DAG: %v", n.Name, err)
+			writeScheduleError(w, err)
+			return
+		}
+		scheduled[n.Name] = res
+		targets = append(targets, res.target())
+	}
+
+	// The target is the sweep or test run ID of each of the runs
+	h.audit(
+		r,
+		AuditActionScheduleDAG,
+		strings.Join(targets, ","),
+		map[string]interface{}{
+			"runs":      body.Runs,
+			"scheduled": scheduled,
+		},
+	)
+	writeJson(w, map[string]interface{}{
+		"ok":   true,
+		"runs": scheduled,
+	})
+}
@@ -5,9 +5,12 @@ import (
 	"net/http"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+// estimateChargeForTestRunHandler estimates the instance-hours and cost of
+// scheduling a test run (or sweep). The runtime of the instances is
+// calibrated from the actual runtime of similar test runs that completed
+// before
 func (h *HttpServer) estimateChargeForTestRunHandler(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -17,38 +20,15 @@ func (h *HttpServer) estimateChargeForTestRunHandler(
 
 	err := json.NewDecoder(r.Body).Decode(&tr)
 	if err != nil {
-		logging.Errorf("Error parsing request: %s", err.Error())
+		logger.Errorf("Error parsing request: %s", err.Error())
 		http.Error(w, "Request format incorrect", 500)
 		return
 	}
 
-	runs := common.ExpandSweepRun(&tr, "")
-
-	// Assume each instance will run for ~15 minutes and then calculate the
-	// total hours for each instance size
-	instanceDuration := map[string]float64{}
-	for i := range runs {
-		for j := range runs[i].Roles {
-			tmpl, err := h.awsm.GetLaunchTemplate(
-				runs[i].Roles[j].AwsLaunchTemplateID,
-			)
-			if err == nil {
-				d, ok := instanceDuration[tmpl.InstanceType]
-				if !ok {
-					instanceDuration[tmpl.InstanceType] = 0.25
-				} else {
-					instanceDuration[tmpl.InstanceType] = d + 0.25
-				}
-			} else {
-				http.Error(w, "Unknown template used", 500)
-				return
-			}
-
-		}
+	estimate, err := h.tr.EstimateCharge(&tr)
+	if err != nil {
+		http.Error(w, "Unknown template used", 500)
+		return
 	}
-
-	writeJson(w, map[string]interface{}{
-		"testruns":      len(runs),
-		"instanceHours": instanceDuration,
-	})
+	writeJson(w, estimate)
 }
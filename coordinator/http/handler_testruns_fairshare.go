@@ -0,0 +1,41 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
+)
+
+// fairShareHandler returns the fair-share configuration of the scheduler
+func (h *HttpServer) fairShareHandler(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, h.tr.Config().FairShare)
+}
+
+// updateFairShareHandler replaces the fair-share configuration of the
+// scheduler: the per-user agent quotas and weights
+func (h *HttpServer) updateFairShareHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	var f testruns.FairShareConfig
+	err := json.NewDecoder(r.Body).Decode(&f)
+	if err != nil {
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+	err = f.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = h.tr.SetFairShare(f)
+	if err != nil {
+		logger.Errorf("Error updating fair-share config: %v", err)
+		http.Error(w, "Internal Server Error", 500)
+		return
+	}
+	h.audit(r, AuditActionFairShare, "", f)
+	writeJsonOK(w)
+}
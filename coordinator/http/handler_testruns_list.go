@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// testRunListHandler returns the list entries of all test runs that match the
+// query parameters status, sweepID, scheduleID, bisectID, createdBy
+// (thumbprint) and since (RFC3339), newest first
+func (h *HttpServer) testRunListHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	q := r.URL.Query()
+	var since time.Time
+	if s := q.Get("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "Invalid since time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := []FrontendTestRunListEntry{}
+	for _, tr := range h.tr.GetTestRuns() {
+		if s := q.Get("status"); s != "" &&
+			tr.Status != common.TestRunStatus(s) {
+			continue
+		}
+		if s := q.Get("sweepID"); s != "" && tr.SweepID != s {
+			continue
+		}
+		if s := q.Get("scheduleID"); s != "" && tr.ScheduleID != s {
+			continue
+		}
+		if s := q.Get("bisectID"); s != "" && tr.BisectID != s {
+			continue
+		}
+		if s := q.Get("createdBy"); s != "" && tr.CreatedByThumbprint != s {
+			continue
+		}
+		if !since.IsZero() && tr.Created.Before(since) {
+			continue
+		}
+		res = append(res, h.getFrontendRun(tr.ID))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Created.After(res[j].Created)
+	})
+	writeJson(w, res)
+}
@@ -3,11 +3,14 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+// testRunLogHandler writes the log of a test run, or the part of it after the
+// byte offset if one is given, such that clients following the log only fetch
+// what was added
 func (h *HttpServer) testRunLogHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	runID := params["runID"]
@@ -18,11 +21,25 @@ func (h *HttpServer) testRunLogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	offset := 0
+	if o, ok := params["offset"]; ok {
+		var err error
+		offset, err = strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+	log := run.FullLog()
+	if offset > len(log) {
+		offset = len(log)
+	}
+
 	w.Header().
 		Add("Content-Disposition", fmt.Sprintf("attachment; filename=testrunlog_%s.txt", runID))
 	w.Header().Add("Content-Type", "text/plain")
-	_, err := w.Write([]byte(run.FullLog()))
+	_, err := w.Write([]byte(log[offset:]))
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 }
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// testRunLogQueryHandler returns the entries from a test run's structured log
+// that match all query parameters, for instance
+// `?agent=3&role=shard-0&level=info`
+func (h *HttpServer) testRunLogQueryHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	params := mux.Vars(r)
+	runID := params["runID"]
+
+	run, ok := h.tr.GetTestRun(runID)
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	filter := map[string]string{}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			filter[k] = v[0]
+		}
+	}
+
+	entries, err := run.QueryLog(filter)
+	if err != nil {
+		logger.Errorf("Error querying log for test run %s: %v", runID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	writeJson(w, entries)
+}
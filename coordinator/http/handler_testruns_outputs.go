@@ -8,7 +8,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) testRunOutputsHandler(
@@ -35,7 +34,7 @@ func (h *HttpServer) testRunOutputsHandler(
 	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
 		f, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE, 0644)
 		if err != nil {
-			logging.Errorf("Error creating test run archive: %v", err)
+			logger.Errorf("Error creating test run archive: %v", err)
 			http.Error(w, "Internal Server Error", 500)
 			return
 		}
@@ -43,7 +42,7 @@ func (h *HttpServer) testRunOutputsHandler(
 		err = common.CreateArchiveToStream(path, f)
 		f.Close()
 		if err != nil {
-			logging.Errorf("Error creating test run archive: %v", err)
+			logger.Errorf("Error creating test run archive: %v", err)
 			http.Error(w, "Internal Server Error", 500)
 			return
 		}
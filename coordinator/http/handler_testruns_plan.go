@@ -0,0 +1,35 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// planTestRunHandler returns what scheduling a test run (or sweep) would do
+// without launching any infrastructure: the runs it creates, the machines
+// they need, their configuration, start sequence, output files and
+// estimated cost
+func (h *HttpServer) planTestRunHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	var tr common.TestRun
+
+	err := json.NewDecoder(r.Body).Decode(&tr)
+	if err != nil {
+		logger.Errorf("Error parsing request: %s", err.Error())
+		http.Error(w, "Request format incorrect", 500)
+		return
+	}
+
+	plan, err := h.tr.PlanTestRun(&tr)
+	if err != nil {
+		logger.Errorf("Error planning test run: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	writeJson(w, plan)
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) testRunPlotHandler(
@@ -54,7 +53,7 @@ func (h *HttpServer) testRunPlotHandler(
 		) { // Performance plots can be generated on-the-fly
 			err = h.tr.CalculatePerformancePlot(tr, cmdID, plotType)
 			if err != nil {
-				logging.Errorf("Error calculating performance plot: %v", err)
+				logger.Errorf("Error calculating performance plot: %v", err)
 				// If script wrote to the file a bit already (corrupted), ensure
 				// it's gone
 				// so we retry next time
@@ -96,6 +95,6 @@ func (h *HttpServer) testRunPlotHandler(
 
 	_, err = io.Copy(w, f)
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 }
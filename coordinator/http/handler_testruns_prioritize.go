@@ -19,5 +19,11 @@ func (h *HttpServer) prioritizeTestRunHandler(
 	}
 	tr.Priority = tr.Priority + 1
 	h.tr.PersistTestRun(tr)
+	h.audit(
+		r,
+		AuditActionPrioritize,
+		runID,
+		map[string]interface{}{"priority": tr.Priority},
+	)
 	writeJsonOK(w)
 }
@@ -5,7 +5,6 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 type recalcBody struct {
@@ -22,7 +21,7 @@ func (h *HttpServer) testRunRecalcResultsHandler(
 	body := recalcBody{}
 	err := json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		logging.Errorf("Error parsing request: %s", err.Error())
+		logger.Errorf("Error parsing request: %s", err.Error())
 		http.Error(w, "Request format incorrect", 500)
 		return
 	}
@@ -40,11 +39,12 @@ func (h *HttpServer) testRunRecalcResultsHandler(
 	run.TrimZeroesAtStart = body.TrimZeroes
 	run.TrimZeroesAtEnd = body.TrimZeroesEnd
 	h.tr.PersistTestRun(run)
+	h.audit(r, AuditActionRecalcResults, runID, body)
 
 	go func() {
 		_, err := h.tr.CalculateResults(run, true)
 		if err != nil {
-			logging.Errorf("Error calculating results: %v", err)
+			logger.Errorf("Error calculating results: %v", err)
 		}
 	}()
 	writeJsonOK(w)
@@ -5,7 +5,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) redownloadOutputsHandler(
@@ -21,12 +20,13 @@ func (h *HttpServer) redownloadOutputsHandler(
 		return
 	}
 
+	h.audit(r, AuditActionRedownloadOutputs, runID, nil)
 	go func() {
 		err := h.tr.RedownloadTestOutputsFromS3(tr)
 		success := true
 		errorString := ""
 		if err != nil {
-			logging.Errorf("Failed redownloading outputs from S3: %v", err)
+			logger.Errorf("Failed redownloading outputs from S3: %v", err)
 			success = false
 			errorString = err.Error()
 		}
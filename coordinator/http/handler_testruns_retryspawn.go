@@ -11,5 +11,6 @@ func (h *HttpServer) retrySpawnHandler(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	runID := params["runID"]
 	h.tr.RetrySpawn(runID)
+	h.audit(r, AuditActionRetrySpawn, runID, nil)
 	writeJsonOK(w)
 }
@@ -2,12 +2,53 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net/http"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
 )
 
+// scheduleResult holds the IDs of the test runs scheduled for a request
+type scheduleResult struct {
+	// The ID of the sweep the runs belong to, empty for a single run
+	SweepID    string   `json:"sweepID"`
+	TestRunIDs []string `json:"testRunIDs"`
+}
+
+// target returns the ID by which the scheduling is audited: the sweep ID, or
+// the ID of the test run if it is not part of a sweep
+func (s scheduleResult) target() string {
+	if s.SweepID == "" && len(s.TestRunIDs) > 0 {
+		return s.TestRunIDs[0]
+	}
+	return s.SweepID
+}
+
+// writeValidationErrors writes a bad request response with the errors found
+// validating a test run
+func writeValidationErrors(
+	w http.ResponseWriter,
+	errs []*common.ValidationError,
+) {
+	writeJsonStatus(w, http.StatusBadRequest, map[string]interface{}{
+		"ok":     false,
+		"errors": errs,
+	})
+}
+
+// writeScheduleError writes the response for an error returned by
+// scheduleTestRun
+func writeScheduleError(w http.ResponseWriter, err error) {
+	var v *testruns.ValidationFailedError
+	if errors.As(err, &v) {
+		writeValidationErrors(w, v.Errors)
+		return
+	}
+	http.Error(w, "Internal server error", 500)
+}
+
 func (h *HttpServer) scheduleTestRunHandler(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -15,46 +56,55 @@ func (h *HttpServer) scheduleTestRunHandler(
 	defer r.Body.Close()
 	var tr common.TestRun
 
-	err := json.NewDecoder(r.Body).Decode(&tr)
+	reqBody, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		logging.Errorf("Error parsing request: %s", err.Error())
+		logger.Errorf("Error reading request: %s", err.Error())
 		http.Error(w, "Request format incorrect", 500)
 		return
 	}
-
-	usr, err := h.UserFromRequest(r)
+	err = json.Unmarshal(reqBody, &tr)
 	if err != nil {
-		logging.Errorf("Error determining user: %s", err.Error())
-		http.Error(w, "Internal server error", 500)
+		logger.Errorf("Error parsing request: %s", err.Error())
+		http.Error(w, "Request format incorrect", 500)
 		return
 	}
-	tr.CreatedByThumbprint = usr.Thumbprint
-	tr.SweepID = ""
 
-	sweepID, err := common.RandomID(12)
+	res, err := h.scheduleTestRun(r, &tr)
 	if err != nil {
-		logging.Errorf("Error getting randomness: %s", err.Error())
-		http.Error(w, "Internal server error", 500)
+		writeScheduleError(w, err)
 		return
 	}
-	if tr.Repeat == 0 {
-		tr.Repeat = 1
-	}
+	h.audit(r, AuditActionSchedule, res.target(), map[string]interface{}{
+		"request":   json.RawMessage(reqBody),
+		"scheduled": res.TestRunIDs,
+	})
 
-	if tr.WatchtowerErrorCacheSize == 0 {
-		tr.WatchtowerErrorCacheSize = 10000000
-	}
+	writeJson(w, map[string]interface{}{
+		"ok":         true,
+		"sweepID":    res.SweepID,
+		"testRunIDs": res.TestRunIDs,
+	})
+}
 
-	if tr.Sweep == "peak" {
-		tr.SweepOneAtATime = true
+// scheduleTestRun schedules tr on behalf of the user that made request r,
+// expanding it into the individual runs if it is a sweep or is repeated
+func (h *HttpServer) scheduleTestRun(
+	r *http.Request,
+	tr *common.TestRun,
+) (*scheduleResult, error) {
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		return nil, err
 	}
-
-	runs := common.ExpandSweepRun(&tr, sweepID)
-	for i := range runs {
-		h.tr.ScheduleTestRun(runs[i])
-		if tr.SweepOneAtATime {
-			break
+	sweepID, ids, err := h.tr.ScheduleTestRunSpec(tr, usr.Thumbprint)
+	if err != nil {
+		var v *testruns.ValidationFailedError
+		if !errors.As(err, &v) {
+			logger.Errorf("Error scheduling test run: %s", err.Error())
 		}
+		return nil, err
 	}
-	writeJsonOK(w)
+	res := &scheduleResult{SweepID: sweepID, TestRunIDs: ids}
+	return res, nil
 }
@@ -14,5 +14,6 @@ func (h *HttpServer) terminateTestRunHandler(
 	params := mux.Vars(r)
 	runID := params["runID"]
 	h.tr.Terminate(runID)
+	h.audit(r, AuditActionTerminate, runID, nil)
 	writeJsonOK(w)
 }
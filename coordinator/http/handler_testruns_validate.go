@@ -0,0 +1,32 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// validateTestRunHandler validates a test run (or sweep) with the same checks
+// that are applied when scheduling it, and returns the errors found such that
+// they can be shown while the test run is being edited
+func (h *HttpServer) validateTestRunHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	var tr common.TestRun
+
+	err := json.NewDecoder(r.Body).Decode(&tr)
+	if err != nil {
+		logger.Errorf("Error parsing request: %s", err.Error())
+		http.Error(w, "Request format incorrect", 500)
+		return
+	}
+
+	errs := h.tr.ValidateTestRunSpec(&tr)
+	writeJson(w, map[string]interface{}{
+		"valid":  len(errs) == 0,
+		"errors": errs,
+	})
+}
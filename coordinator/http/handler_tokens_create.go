@@ -0,0 +1,76 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type createTokenBody struct {
+	Name  string     `json:"name"`
+	Scope TokenScope `json:"scope"`
+	// The number of hours the token remains valid
+	ExpiresInHours int `json:"expiresInHours"`
+}
+
+// createTokenHandler mints a new API token for the requesting user. The
+// bearer value is only returned in this response. Tokens can only be created
+// using a client certificate, and not with a scope that exceeds the user's
+// role
+func (h *HttpServer) createTokenHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	if TokenFromRequest(r) != nil {
+		http.Error(
+			w,
+			"API tokens cannot be used to create tokens",
+			http.StatusForbidden,
+		)
+		return
+	}
+
+	body := createTokenBody{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		logger.Errorf("Error parsing request: %s", err.Error())
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	scopeRole, ok := scopeRoles[body.Scope]
+	if !ok {
+		http.Error(w, "Unknown scope", http.StatusBadRequest)
+		return
+	}
+	if !usr.Role.Includes(scopeRole) {
+		http.Error(
+			w,
+			"Scope exceeds the permissions of your role",
+			http.StatusForbidden,
+		)
+		return
+	}
+
+	tok, bearer, err := h.tokens.Create(
+		usr.Thumbprint,
+		body.Name,
+		body.Scope,
+		time.Duration(body.ExpiresInHours)*time.Hour,
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.audit(r, AuditActionTokenCreate, tok.ID, body)
+	writeJson(w, map[string]interface{}{"token": tok, "bearer": bearer})
+}
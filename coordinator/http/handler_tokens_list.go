@@ -0,0 +1,25 @@
+package http
+
+import "net/http"
+
+// listTokensHandler returns the API tokens of the requesting user. Admins can
+// list the tokens of all users by passing `?all=true`
+func (h *HttpServer) listTokensHandler(w http.ResponseWriter, r *http.Request) {
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+
+	owner := usr.Thumbprint
+	if r.URL.Query().Get("all") == "true" {
+		role, err := h.RoleFromRequest(r)
+		if err != nil || !role.Includes(RoleAdmin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		owner = ""
+	}
+	writeJson(w, h.tokens.List(owner))
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// revokeTokenHandler revokes an API token. Users can revoke their own tokens,
+// admins can revoke any token
+func (h *HttpServer) revokeTokenHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	// Like creating tokens, revoking them needs the user's certificate, such
+	// that a leaked token cannot be used to revoke the owner's other tokens
+	if TokenFromRequest(r) != nil {
+		http.Error(
+			w,
+			"API tokens cannot be used to revoke tokens",
+			http.StatusForbidden,
+		)
+		return
+	}
+
+	params := mux.Vars(r)
+	tokenID := params["tokenID"]
+
+	tok, ok := h.tokens.Get(tokenID)
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		logger.Errorf("Error determining user: %s", err.Error())
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	if tok.OwnerThumbprint != usr.Thumbprint {
+		role, err := h.RoleFromRequest(r)
+		if err != nil || !role.Includes(RoleAdmin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	err = h.tokens.Revoke(tokenID)
+	if err != nil {
+		logger.Errorf("Error revoking token %s: %v", tokenID, err)
+		http.Error(w, "Internal server error", 500)
+		return
+	}
+	h.audit(r, AuditActionTokenRevoke, tokenID, nil)
+	writeJsonOK(w)
+}
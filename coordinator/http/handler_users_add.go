@@ -2,6 +2,7 @@ package http
 
 import (
 	"crypto/rand"
+	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"io"
@@ -10,11 +11,32 @@ import (
 	"path/filepath"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+// addUserHandler authorizes the certificate in the request body. The role of
+// the new user can be given in the query parameter `role` and defaults to
+// viewer
 func (srv *HttpServer) addUserHandler(w http.ResponseWriter, r *http.Request) {
-	err := srv.addUser(w, r, r.Body)
+	role := RoleViewer
+	if roleParam := r.URL.Query().Get("role"); roleParam != "" {
+		role = Role(roleParam)
+		if !role.Valid() {
+			http.Error(w, "Unknown role", http.StatusBadRequest)
+			return
+		}
+	}
+	thumbprint, err := srv.addUser(w, r, r.Body)
+	if err == nil {
+		if err := srv.storeRole(thumbprint, role); err != nil {
+			logger.Errorf("Could not store role for new user: %v", err)
+		}
+		srv.audit(
+			r,
+			AuditActionUserAdd,
+			thumbprint,
+			map[string]interface{}{"role": role},
+		)
+	}
 	writeJson(w, map[string]interface{}{"ok": err == nil})
 	srv.ReloadCerts()
 }
@@ -23,28 +45,35 @@ func (srv *HttpServer) addUser(
 	w http.ResponseWriter,
 	r *http.Request,
 	fileReader io.ReadCloser,
-) error {
+) (string, error) {
 	// Read body
 	reqBody, err := ioutil.ReadAll(fileReader)
 	if err != nil {
-		logging.Warnf("Could not read body: %v", err)
+		logger.Warnf("Could not read body: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return err
+		return "", err
 	}
 	block, _ := pem.Decode([]byte(reqBody))
 
 	if block == nil {
-		logging.Warnf("Could not parse certificate from request: %v", err)
+		logger.Warnf("Could not parse certificate from request: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return err
+		return "", err
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		logger.Warnf("Could not parse certificate from request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", err
 	}
 
 	newCert := make([]byte, 8)
 	_, err = rand.Read(newCert)
 	if err != nil {
-		logging.Warnf("Could not read randomness: %v", err)
+		logger.Warnf("Could not read randomness: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return err
+		return "", err
 	}
 
 	err = ioutil.WriteFile(
@@ -56,9 +85,9 @@ func (srv *HttpServer) addUser(
 		0600,
 	)
 	if err != nil {
-		logging.Warnf("Could not write user certificate file: %v", err)
+		logger.Warnf("Could not write user certificate file: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return err
+		return "", err
 	}
-	return fileReader.Close()
+	return srv.UserFromCert(cert).Thumbprint, fileReader.Close()
 }
@@ -13,15 +13,25 @@ func (srv *HttpServer) deleteUserHandler(
 ) {
 	vars := mux.Vars(r)
 	fileToDelete := ""
-	for _, u := range srv.users {
+	admins := 0
+	isAdmin := false
+	for _, u := range srv.Users() {
+		if u.Role == RoleAdmin {
+			admins++
+		}
 		if u.Thumbprint == vars["thumb"] {
 			fileToDelete = u.certFile
+			isAdmin = u.Role == RoleAdmin
 		}
 	}
 	if fileToDelete == "" {
 		http.Error(w, "Not found", 404)
 		return
 	}
+	if isAdmin && admins == 1 {
+		http.Error(w, "Cannot delete the last admin", http.StatusBadRequest)
+		return
+	}
 
 	err := os.Remove(fileToDelete)
 	if err != nil {
@@ -30,6 +40,7 @@ func (srv *HttpServer) deleteUserHandler(
 	}
 
 	srv.ReloadCerts()
+	srv.audit(r, AuditActionUserDelete, vars["thumb"], nil)
 
 	writeJson(w, map[string]interface{}{"ok": true})
 }
@@ -8,7 +8,7 @@ func (srv *HttpServer) firstTimeAddUserHandler(
 	w http.ResponseWriter,
 	r *http.Request,
 ) {
-	if len(srv.users) > 0 {
+	if len(srv.Users()) > 0 {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
@@ -21,11 +21,22 @@ func (srv *HttpServer) firstTimeAddUserHandler(
 		http.Error(w, "File missing or invalid", http.StatusBadRequest)
 		return
 	}
-	err = srv.addUser(w, r, file)
+	thumbprint, err := srv.addUser(w, r, file)
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	// The first user needs to be able to authorize the others
+	err = srv.storeRole(thumbprint, RoleAdmin)
+	if err != nil {
+		logger.Errorf("Could not store role for first user: %v", err)
+	}
+	srv.audit(
+		r,
+		AuditActionUserAdd,
+		thumbprint,
+		map[string]interface{}{"role": RoleAdmin},
+	)
 	srv.ReloadCerts()
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(200)
@@ -3,5 +3,5 @@ package http
 import "net/http"
 
 func (srv *HttpServer) usersHandler(w http.ResponseWriter, r *http.Request) {
-	writeJson(w, srv.users)
+	writeJson(w, srv.Users())
 }
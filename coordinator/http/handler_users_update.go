@@ -0,0 +1,40 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type updateUserBody struct {
+	Role Role `json:"role"`
+}
+
+func (srv *HttpServer) updateUserHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	body := updateUserBody{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		logger.Errorf("Error parsing request: %s", err.Error())
+		http.Error(w, "Request format incorrect", http.StatusBadRequest)
+		return
+	}
+
+	if srv.UserFromThumbprint(vars["thumb"]) == nil {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	err = srv.SetUserRole(vars["thumb"], body.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	srv.audit(r, AuditActionUserRole, vars["thumb"], body)
+	writeJsonOK(w)
+}
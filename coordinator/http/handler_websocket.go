@@ -9,7 +9,6 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (srv *HttpServer) wsWithTokenHandler(
@@ -20,7 +19,7 @@ func (srv *HttpServer) wsWithTokenHandler(
 
 	token, ok := srv.wsTokens.LoadAndDelete(vars["token"])
 	if !ok {
-		logging.Warnf(
+		logger.Warnf(
 			"Websocket connection tried with non-existent token %s",
 			vars["token"],
 		)
@@ -28,7 +27,7 @@ func (srv *HttpServer) wsWithTokenHandler(
 		return
 	}
 	if token.(time.Time).Before(time.Now()) {
-		logging.Warnf(
+		logger.Warnf(
 			"Websocket connection tried with expired token %s",
 			vars["token"],
 		)
@@ -36,7 +35,7 @@ func (srv *HttpServer) wsWithTokenHandler(
 		return
 	}
 
-	logging.Warnf("Websocket connection initiated with token %s", vars["token"])
+	logger.Warnf("Websocket connection initiated with token %s", vars["token"])
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("upgrade: %v", err)
@@ -73,14 +72,14 @@ func (srv *HttpServer) wsWithTokenHandler(
 	if err == nil {
 		conn.outgoing <- msg
 	} else {
-		logging.Errorf("Error marshalling system state: %v", err)
+		logger.Errorf("Error marshalling system state: %v", err)
 	}
 
 	defer c.Close()
 	for {
 		mt, msg, err := c.ReadMessage()
 		if err != nil {
-			logging.Warnf("read error: %v", err)
+			logger.Warnf("read error: %v", err)
 			break
 		}
 
@@ -88,7 +87,7 @@ func (srv *HttpServer) wsWithTokenHandler(
 			var m websocketMessage
 			err = json.Unmarshal(msg, &m)
 			if err != nil {
-				logging.Warnf("unmarhal error: %v", err)
+				logger.Warnf("unmarhal error: %v", err)
 				break
 			}
 
@@ -113,6 +112,22 @@ func (srv *HttpServer) wsWithTokenHandler(
 					if err == nil {
 						conn.outgoing <- b
 					}
+
+					// Send the live results so far if the test is running
+					points := srv.tr.LiveResults(tr.ID)
+					if len(points) > 0 {
+						ev = coordinator.Event{
+							Type: coordinator.EventTypeTestRunLiveResults,
+							Payload: coordinator.TestRunLiveResultsPayload{
+								TestRunID: tr.ID,
+								Points:    points,
+							},
+						}
+						b, err = json.Marshal(ev)
+						if err == nil {
+							conn.outgoing <- b
+						}
+					}
 				}
 			}
 		}
@@ -11,7 +11,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,27 +21,40 @@ import (
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/agents"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/bisect"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/schedules"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/templates"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
 	"github.com/mit-dci/opencbdc-tctl/logging"
 	"github.com/rs/cors"
 )
 
+var logger = logging.NewLogger("http")
+
 type HttpServer struct {
 	srv                        *http.Server
 	src                        *sources.SourcesManager
 	am                         *agents.AgentsManager
 	awsm                       *awsmgr.AwsManager
 	tr                         *testruns.TestRunManager
+	tm                         *templates.TemplateManager
+	sm                         *schedules.ScheduleManager
+	bm                         *bisect.BisectManager
 	coord                      *coordinator.Coordinator
+	cfg                        *config.Config
 	events                     chan coordinator.Event
 	httpsWithoutClientCertPort int
 	httpsPort                  int
 	users                      []*SystemUser
+	usersLock                  sync.RWMutex
 	roots                      *x509.CertPool
 	certificate                tls.Certificate
 	wsTokens                   sync.Map
 	version                    string
+	auditLog                   *AuditLog
+	tokens                     *TokenStore
 }
 
 type SystemUser struct {
@@ -50,6 +62,7 @@ type SystemUser struct {
 	Email        string `json:"email"`
 	Organization string `json:"org"`
 	Thumbprint   string `json:"thumbPrint"`
+	Role         Role   `json:"role"`
 	certFile     string
 }
 
@@ -58,9 +71,12 @@ func NewHttpServer(
 	s *sources.SourcesManager,
 	a *agents.AgentsManager,
 	t *testruns.TestRunManager,
+	tm *templates.TemplateManager,
+	sm *schedules.ScheduleManager,
+	bm *bisect.BisectManager,
 	ev chan coordinator.Event,
 	awsm *awsmgr.AwsManager,
-	port int,
+	cfg *config.Config,
 	version string,
 ) (*HttpServer, error) {
 	httpSrv := HttpServer{
@@ -68,23 +84,26 @@ func NewHttpServer(
 		src:      s,
 		am:       a,
 		tr:       t,
+		tm:       tm,
+		sm:       sm,
+		bm:       bm,
 		events:   ev,
 		users:    []*SystemUser{},
 		wsTokens: sync.Map{},
 		awsm:     awsm,
+		cfg:      cfg,
 		version:  version,
-	}
-	httpSrv.httpsWithoutClientCertPort, _ = strconv.Atoi(
-		os.Getenv("HTTPS_WITHOUT_CLIENT_CERT_PORT"),
-	)
-	if httpSrv.httpsWithoutClientCertPort == 0 {
-		httpSrv.httpsWithoutClientCertPort = 444
+		auditLog: NewAuditLog(),
+
+		httpsWithoutClientCertPort: cfg.HTTPSWithoutClientCertPort,
+		httpsPort:                  cfg.HTTPSPort,
 	}
 
-	httpSrv.httpsPort, _ = strconv.Atoi(os.Getenv("HTTPS_PORT"))
-	if httpSrv.httpsPort == 0 {
-		httpSrv.httpsPort = 443
+	tokens, err := NewTokenStore()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load API tokens: %v", err)
 	}
+	httpSrv.tokens = tokens
 
 	r := mux.NewRouter()
 
@@ -98,17 +117,45 @@ func NewHttpServer(
 	// Users
 	r.HandleFunc("/api/users", httpSrv.usersHandler).Methods("GET")
 	r.HandleFunc("/api/users", httpSrv.addUserHandler).Methods("POST")
+	r.HandleFunc("/api/users/{thumb}", httpSrv.updateUserHandler).
+		Methods("PUT")
 	r.HandleFunc("/api/users/{thumb}", httpSrv.deleteUserHandler).
 		Methods("DELETE")
 
+	// API tokens
+	r.HandleFunc("/api/tokens", NoCache(httpSrv.listTokensHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/tokens", httpSrv.createTokenHandler).Methods("POST")
+	r.HandleFunc("/api/tokens/{tokenID}", httpSrv.revokeTokenHandler).
+		Methods("DELETE")
+
 	// Maintenance mode
 	r.HandleFunc("/api/maintenance", NoCache(httpSrv.systemMaintenanceHandler)).
 		Methods("GET", "PUT")
 
+	// Logging
+	r.HandleFunc("/api/logging", NoCache(httpSrv.loggingHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/logging/{component}/{level}", httpSrv.setLogLevelHandler).
+		Methods("PUT")
+	r.HandleFunc("/api/logging/{component}", httpSrv.resetLogLevelHandler).
+		Methods("DELETE")
+
 	// Version
 	r.HandleFunc("/api/version", httpSrv.versionHandler).Methods("GET")
 
+	// Audit log
+	r.HandleFunc("/api/audit", NoCache(httpSrv.auditLogHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/audit/csv", NoCache(httpSrv.auditLogCsvHandler)).
+		Methods("GET")
+
+	// Configuration
+	r.HandleFunc("/api/config", httpSrv.configHandler).Methods("GET")
+
 	// Test runs
+	r.HandleFunc("/api/testruns", NoCache(httpSrv.testRunListHandler)).
+		Methods("GET")
 	r.HandleFunc("/api/testruns/sweeps", NoCache(httpSrv.sweepListHandler)).
 		Methods("GET")
 	r.HandleFunc("/api/testruns/matrix", NoCache(httpSrv.testRunMatrixHandler)).
@@ -121,14 +168,34 @@ func NewHttpServer(
 		Methods("GET")
 	r.HandleFunc("/api/testruns/maxagents/{max}", NoCache(httpSrv.reconfigureMaxAgentsHandler)).
 		Methods("PUT")
+	r.HandleFunc("/api/testruns/fairshare", NoCache(httpSrv.fairShareHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/testruns/fairshare", httpSrv.updateFairShareHandler).
+		Methods("PUT")
+	r.HandleFunc("/api/testruns/budget", NoCache(httpSrv.budgetHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/testruns/budget", httpSrv.updateBudgetHandler).
+		Methods("PUT")
 	r.HandleFunc("/api/testruns/schedule", httpSrv.scheduleTestRunHandler).
 		Methods("POST")
+	r.HandleFunc("/api/testruns/dag", httpSrv.scheduleDAGHandler).
+		Methods("POST")
 	r.HandleFunc("/api/testruns/estimate", httpSrv.estimateChargeForTestRunHandler).
 		Methods("POST")
+	r.HandleFunc("/api/testruns/plan", httpSrv.planTestRunHandler).
+		Methods("POST")
+	r.HandleFunc("/api/testruns/validate", httpSrv.validateTestRunHandler).
+		Methods("POST")
+	r.HandleFunc("/api/testruns/{runID}/clone", httpSrv.cloneTestRunHandler).
+		Methods("POST")
 	r.HandleFunc("/api/testruns/{runID}/prioritize", httpSrv.prioritizeTestRunHandler).
 		Methods("GET")
+	r.HandleFunc("/api/testruns/{runID}/budgetOverride", httpSrv.budgetOverrideHandler).
+		Methods("PUT", "DELETE")
 	r.HandleFunc("/api/testruns/{runID}/redownloadOutputs", httpSrv.redownloadOutputsHandler).
 		Methods("GET")
+	r.HandleFunc("/api/testruns/{runID}/log/query", NoCache(httpSrv.testRunLogQueryHandler)).
+		Methods("GET")
 	r.HandleFunc("/api/testruns/{runID}/log/{offset}", NoCache(httpSrv.testRunLogHandler)).
 		Methods("GET")
 	r.HandleFunc("/api/testruns/{runID}/log", NoCache(httpSrv.testRunLogHandler)).
@@ -137,12 +204,16 @@ func NewHttpServer(
 		Methods("GET")
 	r.HandleFunc("/api/testruns/{runID}/results", httpSrv.testRunResultsHandler).
 		Methods("GET")
+	r.HandleFunc("/api/testruns/{runID}/cost", NoCache(httpSrv.testRunCostHandler)).
+		Methods("GET")
 	r.HandleFunc("/api/testruns/{runID}/results/recalc", httpSrv.testRunRecalcResultsHandler).
 		Methods("POST")
 	r.HandleFunc("/api/testruns/{runID}/plot/{plot}", NoCache(httpSrv.testRunPlotHandler)).
 		Methods("GET")
 	r.HandleFunc("/api/testruns/{runID}/outputs", NoCache(httpSrv.testRunOutputsHandler)).
 		Methods("GET")
+	r.HandleFunc("/api/testruns/{runID}/bundle", NoCache(httpSrv.testRunBundleHandler)).
+		Methods("GET")
 	r.HandleFunc("/api/testruns/{runID}/terminate", httpSrv.terminateTestRunHandler).
 		Methods("PUT")
 	r.HandleFunc("/api/testruns/{runID}/retrySpawn", httpSrv.retrySpawnHandler).
@@ -152,6 +223,44 @@ func NewHttpServer(
 	r.HandleFunc("/api/testruns/{runID}/confirmPeak", httpSrv.testRunConfirmPeakHandler).
 		Methods("POST")
 
+	// Templates
+	r.HandleFunc("/api/templates", NoCache(httpSrv.listTemplatesHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/templates", httpSrv.createTemplateHandler).
+		Methods("POST")
+	r.HandleFunc("/api/templates/{templateID}", NoCache(httpSrv.getTemplateHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/templates/{templateID}", httpSrv.updateTemplateHandler).
+		Methods("PUT")
+	r.HandleFunc("/api/templates/{templateID}", httpSrv.deleteTemplateHandler).
+		Methods("DELETE")
+	r.HandleFunc("/api/templates/{templateID}/schedule", httpSrv.scheduleTemplateHandler).
+		Methods("POST")
+
+	// Schedules
+	r.HandleFunc("/api/schedules", NoCache(httpSrv.listSchedulesHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/schedules", httpSrv.createScheduleHandler).
+		Methods("POST")
+	r.HandleFunc("/api/schedules/{scheduleID}", NoCache(httpSrv.getScheduleHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/schedules/{scheduleID}", httpSrv.updateScheduleHandler).
+		Methods("PUT")
+	r.HandleFunc("/api/schedules/{scheduleID}", httpSrv.deleteScheduleHandler).
+		Methods("DELETE")
+	r.HandleFunc("/api/schedules/{scheduleID}/fire", httpSrv.fireScheduleHandler).
+		Methods("POST")
+
+	// Bisections
+	r.HandleFunc("/api/bisections", NoCache(httpSrv.listBisectionsHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/bisections", httpSrv.createBisectionHandler).
+		Methods("POST")
+	r.HandleFunc("/api/bisections/{bisectID}", NoCache(httpSrv.getBisectionHandler)).
+		Methods("GET")
+	r.HandleFunc("/api/bisections/{bisectID}/cancel", httpSrv.cancelBisectionHandler).
+		Methods("POST")
+
 	// Sweeps
 	r.HandleFunc("/api/sweeps/{sweepID}/fixMissing", httpSrv.scheduleMissingSweepRuns).
 		Methods("GET")
@@ -159,6 +268,12 @@ func NewHttpServer(
 		Methods("GET")
 	r.HandleFunc("/api/sweeps/{sweepID}/cancel", httpSrv.cancelSweepRuns).
 		Methods("GET")
+	r.HandleFunc("/api/sweeps/{sweepID}/cost", NoCache(httpSrv.sweepCostHandler)).
+		Methods("GET")
+
+	// Costs
+	r.HandleFunc("/api/costs/users", NoCache(httpSrv.userCostsHandler)).
+		Methods("GET")
 
 	// Commands
 	r.HandleFunc("/api/commands/{cmdID}/output/{stream}", httpSrv.commandOutputHandler).
@@ -188,6 +303,8 @@ func NewHttpServer(
 	spa := spaHandler{staticPath: "frontend", indexPath: "index.html"}
 	r.PathPrefix("/").Handler(spa)
 
+	r.Use(httpSrv.authorizeMiddleware)
+
 	// TODO: Probably tighten this once we take this in production
 	var corsOpt = cors.New(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:3000"}, // DEBUG
@@ -202,7 +319,10 @@ func NewHttpServer(
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
 		TLSConfig: &tls.Config{
-			ClientAuth:         tls.RequireAndVerifyClientCert,
+			// Client certificates are optional such that API tokens can be
+			// used instead, authorizeMiddleware rejects requests that
+			// have neither
+			ClientAuth:         tls.VerifyClientCertIfGiven,
 			GetConfigForClient: httpSrv.GetConfigForClient,
 		},
 	}
@@ -232,7 +352,7 @@ func (srv *HttpServer) AuthorizeHandler(
 	w.WriteHeader(200)
 
 	postForm := ""
-	if len(srv.users) == 0 {
+	if len(srv.Users()) == 0 {
 		postForm = `<h2>Authorize first user</h2><p>Since your system does not have any configured users yet, you can add the first one from this unauthenticated endpoint. Browse to the .crt file you created to add it to the list of authenticated users.</p>
 		<form enctype="multipart/form-data" action="/firstTimeAuth" method="post">
 		<input type="file" name="firstTimeCert" />
@@ -291,7 +411,7 @@ func (srv *HttpServer) AuthorizeHandler(
 		`</body>
       </html>`))
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 
 }
@@ -317,7 +437,7 @@ func (srv *HttpServer) AuthorizeScriptHandler(
       echo -e "\n\n\nYour certificate is ready!\n===\nImport the file ${bold}user.p12${normal} in your browser. Use the password ${bold}${EXPORT_PW}${normal}\n\nFor safety reasons, delete the p12 file once you're done. Provide the ${bold}user.crt${normal} to the CBDC-Test Controller Administrator for authorization. Once the certificate has been authorized for access, you can access the CBDC-Test portal using this client-side certificate.\n\nHave a nice day!.\n\n\n"
    `))
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 
 }
@@ -328,7 +448,7 @@ func (srv *HttpServer) ReloadCerts() {
 		common.DataDir(),
 		"certs/users"), 0755)
 	if err != nil && !os.IsExist(err) {
-		logging.Warnf("Error creating user certs folder: %v", err)
+		logger.Warnf("Error creating user certs folder: %v", err)
 	}
 
 	roots := x509.NewCertPool()
@@ -339,7 +459,7 @@ func (srv *HttpServer) ReloadCerts() {
 			if strings.HasSuffix(path, ".crt") {
 				caCertPEM, err := ioutil.ReadFile(path)
 				if err != nil {
-					logging.Warnf(
+					logger.Warnf(
 						"Could not parse certificate %s: %v",
 						path,
 						err,
@@ -348,7 +468,7 @@ func (srv *HttpServer) ReloadCerts() {
 				}
 				ok := roots.AppendCertsFromPEM(caCertPEM)
 				if !ok {
-					logging.Warnf(
+					logger.Warnf(
 						"Could not parse certificate %s: %v",
 						path,
 						err,
@@ -359,7 +479,7 @@ func (srv *HttpServer) ReloadCerts() {
 				block, _ := pem.Decode([]byte(caCertPEM))
 
 				if block == nil {
-					logging.Warnf(
+					logger.Warnf(
 						"Could not parse certificate %s: %v",
 						path,
 						err,
@@ -369,7 +489,7 @@ func (srv *HttpServer) ReloadCerts() {
 
 				cert, err := x509.ParseCertificate(block.Bytes)
 				if err != nil {
-					logging.Warnf(
+					logger.Warnf(
 						"Could not parse certificate %s: %v",
 						path,
 						err,
@@ -384,16 +504,39 @@ func (srv *HttpServer) ReloadCerts() {
 		},
 	)
 	if err != nil {
-		logging.Errorf("Failure loading certs: %v", err)
+		logger.Errorf("Failure loading certs: %v", err)
+	}
+
+	srv.usersLock.Lock()
+	roles, rolesExist, err := loadRoles()
+	if err != nil {
+		logger.Errorf("Failure loading user roles: %v", err)
+	}
+	for _, u := range users {
+		u.Role = RoleViewer
+		if role, ok := roles[u.Thumbprint]; ok {
+			u.Role = role
+		} else if !rolesExist {
+			// Roles were introduced after these users were authorized, so
+			// they keep the permissions they had before
+			u.Role = RoleAdmin
+		}
 	}
 	srv.roots = roots
 	srv.users = users
+	if err == nil && len(users) > 0 {
+		err = srv.persistRoles()
+		if err != nil {
+			logger.Errorf("Failure persisting user roles: %v", err)
+		}
+	}
+	srv.usersLock.Unlock()
 
 	certPath := filepath.Join(common.DataDir(), "certs/server.crt")
 	if _, err := os.Stat(certPath); os.IsNotExist(
 		err,
 	) {
-		logging.Infof(
+		logger.Infof(
 			"No HTTPS certificate found in [%s] - generating self-signed one",
 			certPath,
 		)
@@ -443,7 +586,7 @@ func (srv *HttpServer) GetConfigForClient(
 	hi *tls.ClientHelloInfo,
 ) (*tls.Config, error) {
 	return &tls.Config{
-		ClientAuth:            tls.RequireAndVerifyClientCert,
+		ClientAuth:            tls.VerifyClientCertIfGiven,
 		ClientCAs:             srv.roots,
 		MinVersion:            tls.VersionTLS12,
 		Certificates:          []tls.Certificate{srv.certificate},
@@ -455,6 +598,11 @@ func (srv *HttpServer) getClientValidator(
 	helloInfo *tls.ClientHelloInfo,
 ) func([][]byte, [][]*x509.Certificate) error {
 	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 {
+			// No client certificate given, the request needs to carry an
+			// API token instead
+			return nil
+		}
 
 		opts := x509.VerifyOptions{
 			Roots:         srv.roots,
@@ -464,7 +612,7 @@ func (srv *HttpServer) getClientValidator(
 		}
 		_, err := verifiedChains[0][0].Verify(opts)
 		if err != nil {
-			logging.Warnf("Failed certificate verification: %v", err)
+			logger.Warnf("Failed certificate verification: %v", err)
 		}
 		return err
 	}
@@ -475,7 +623,7 @@ func (srv *HttpServer) HealthHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(200)
 	_, err := w.Write([]byte(`I'm good, thanks.`))
 	if err != nil {
-		logging.Errorf("Error writing output: %v", err)
+		logger.Errorf("Error writing output: %v", err)
 	}
 }
 
@@ -500,7 +648,7 @@ func (srv *HttpServer) Run() error {
 			r,
 		)
 		if err != nil {
-			logging.Errorf("Error on HTTPS server without client cert: %v", err)
+			logger.Errorf("Error on HTTPS server without client cert: %v", err)
 		}
 	}()
 	return srv.srv.ListenAndServeTLS(
@@ -3,16 +3,18 @@ package http
 import (
 	"encoding/json"
 	"net/http"
-
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func writeJson(w http.ResponseWriter, v interface{}) {
+	writeJsonStatus(w, 200, v)
+}
+
+func writeJsonStatus(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
+	w.WriteHeader(status)
 	err := json.NewEncoder(w).Encode(v)
 	if err != nil {
-		logging.Errorf("Error writing JSON response: %v", err)
+		logger.Errorf("Error writing JSON response: %v", err)
 	}
 }
 
@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 func (h *HttpServer) matrixToCsv(
@@ -127,7 +126,7 @@ func (h *HttpServer) matrixToCsv(
 	cw := csv.NewWriter(w)
 	for _, record := range records {
 		if err := cw.Write(record); err != nil {
-			logging.Errorf("error writing record to csv: %v", err)
+			logger.Errorf("error writing record to csv: %v", err)
 		}
 	}
 	cw.Flush()
@@ -16,7 +16,6 @@ import (
 	"strings"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 type reportDefinition struct {
@@ -450,7 +449,7 @@ func (h *HttpServer) generateRunPlot(input string) string {
 	var req runPlotQuery
 	err := json.Unmarshal([]byte(input), &req)
 	if err != nil {
-		logging.Errorf("Error unmarshaling runplot JSON: %v", err)
+		logger.Errorf("Error unmarshaling runplot JSON: %v", err)
 		return ""
 	}
 
@@ -0,0 +1,266 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// Role determines which API endpoints a user has access to. Each role
+// includes all permissions of the roles before it
+type Role string
+
+const (
+	// RoleViewer can view test runs, results and system state
+	RoleViewer Role = "viewer"
+	// RoleOperator can additionally schedule, terminate and otherwise manage
+	// test runs and sweeps
+	RoleOperator Role = "operator"
+	// RoleAdmin can additionally manage users and change the system
+	// configuration such as maintenance mode and the maximum number of agents
+	RoleAdmin Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Valid returns true if the role is one of the known roles
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Includes returns true if the role has at least the permissions of other
+func (r Role) Includes(other Role) bool {
+	return roleRank[r] >= roleRank[other]
+}
+
+// routeRoles holds the role required for the API routes, indexed by the
+// request method and the route's path template. Routes that are not listed
+// here are accessible to every authenticated user
+var routeRoles = map[string]Role{
+	// Every user can manage their own API tokens, the handlers check the
+	// ownership of the token and reject requests made with a token
+	"POST /api/tokens":             RoleViewer,
+	"DELETE /api/tokens/{tokenID}": RoleViewer,
+	// These only compute a result for the posted test run and do not change
+	// any state
+	"POST /api/testruns/estimate": RoleViewer,
+	"POST /api/testruns/plan":     RoleViewer,
+	"POST /api/testruns/validate": RoleViewer,
+
+	"POST /api/testruns/schedule":                    RoleOperator,
+	"POST /api/testruns/dag":                         RoleOperator,
+	"POST /api/testruns/{runID}/clone":               RoleOperator,
+	"PUT /api/testruns/{runID}/terminate":            RoleOperator,
+	"PUT /api/testruns/{runID}/retrySpawn":           RoleOperator,
+	"GET /api/testruns/{runID}/prioritize":           RoleOperator,
+	"GET /api/testruns/{runID}/redownloadOutputs":    RoleOperator,
+	"POST /api/testruns/{runID}/results/recalc":      RoleOperator,
+	"POST /api/testruns/{runID}/confirmPeak":         RoleOperator,
+	"GET /api/sweeps/{sweepID}/fixMissing":           RoleOperator,
+	"GET /api/sweeps/{sweepID}/continue":             RoleOperator,
+	"GET /api/sweeps/{sweepID}/cancel":               RoleOperator,
+	"POST /api/sweepplot":                            RoleOperator,
+	"DELETE /api/sweepplot/saved/{sweepID}/{plotID}": RoleOperator,
+	"POST /api/sources/update":                       RoleOperator,
+	"POST /api/templates":                            RoleOperator,
+	"PUT /api/templates/{templateID}":                RoleOperator,
+	"DELETE /api/templates/{templateID}":             RoleOperator,
+	"POST /api/templates/{templateID}/schedule":      RoleOperator,
+	"POST /api/schedules":                            RoleOperator,
+	"PUT /api/schedules/{scheduleID}":                RoleOperator,
+	"DELETE /api/schedules/{scheduleID}":             RoleOperator,
+	"POST /api/schedules/{scheduleID}/fire":          RoleOperator,
+	"POST /api/bisections":                           RoleOperator,
+	"POST /api/bisections/{bisectID}/cancel":         RoleOperator,
+
+	"POST /api/users":                             RoleAdmin,
+	"PUT /api/users/{thumb}":                      RoleAdmin,
+	"DELETE /api/users/{thumb}":                   RoleAdmin,
+	"PUT /api/maintenance":                        RoleAdmin,
+	"PUT /api/testruns/fairshare":                 RoleAdmin,
+	"PUT /api/testruns/budget":                    RoleAdmin,
+	"PUT /api/testruns/{runID}/budgetOverride":    RoleAdmin,
+	"DELETE /api/testruns/{runID}/budgetOverride": RoleAdmin,
+	"PUT /api/testruns/maxagents/{max}":           RoleAdmin,
+	"PUT /api/logging/{component}/{level}":        RoleAdmin,
+	"DELETE /api/logging/{component}":             RoleAdmin,
+	"GET /api/audit":                              RoleAdmin,
+	"GET /api/audit/csv":                          RoleAdmin,
+}
+
+// requiredRole returns the role needed to access the route matched by r
+func requiredRole(r *http.Request) Role {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return RoleViewer
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return RoleViewer
+	}
+	role, ok := routeRoles[r.Method+" "+tpl]
+	if !ok {
+		return RoleViewer
+	}
+	return role
+}
+
+// authorizeMiddleware authenticates requests that carry an API token, and
+// rejects requests from users that do not have the role required for the
+// requested route
+func (srv *HttpServer) authorizeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, err := srv.authenticateToken(r)
+		if err != nil {
+			logger.Warnf("Rejected API token: %v", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		required := requiredRole(r)
+		role, err := srv.RoleFromRequest(r)
+		if err != nil {
+			logger.Warnf("Unable to determine role for request: %v", err)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if !role.Includes(required) {
+			logger.Warnf(
+				"Denied %s %s: requires role %s, user has %s",
+				r.Method,
+				r.URL.Path,
+				required,
+				role,
+			)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RoleFromRequest returns the role of the user that made request r. For
+// requests authenticated with an API token, the role is limited by the
+// token's scope
+func (srv *HttpServer) RoleFromRequest(r *http.Request) (Role, error) {
+	usr, err := srv.UserFromRequest(r)
+	if err != nil {
+		return "", err
+	}
+	if usr.Role == "" {
+		return "", fmt.Errorf("user %s is not registered", usr.Thumbprint)
+	}
+	if tok := TokenFromRequest(r); tok != nil {
+		scopeRole := scopeRoles[tok.Scope]
+		if !scopeRole.Includes(usr.Role) {
+			return scopeRole, nil
+		}
+	}
+	return usr.Role, nil
+}
+
+func rolesFile() string {
+	return filepath.Join(common.DataDir(), "certs", "roles.json")
+}
+
+// loadRoles reads the roles assigned to users, indexed by their thumbprint.
+// The boolean return value is false if no roles were ever assigned
+func loadRoles() (map[string]Role, bool, error) {
+	roles := map[string]Role{}
+	b, err := ioutil.ReadFile(rolesFile())
+	if os.IsNotExist(err) {
+		return roles, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	err = json.Unmarshal(b, &roles)
+	if err != nil {
+		return nil, false, err
+	}
+	return roles, true, nil
+}
+
+// persistRoles writes the roles of all users to disk. Must be called with
+// usersLock held
+func (srv *HttpServer) persistRoles() error {
+	roles := map[string]Role{}
+	for _, u := range srv.users {
+		roles[u.Thumbprint] = u.Role
+	}
+	return writeRoles(roles)
+}
+
+func writeRoles(roles map[string]Role) error {
+	b, err := json.MarshalIndent(roles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(rolesFile(), b, 0600)
+}
+
+// SetUserRole assigns a role to the user with the given thumbprint. It
+// refuses to take away the admin role from the last admin, to prevent
+// locking everyone out of user management
+func (srv *HttpServer) SetUserRole(thumbprint string, role Role) error {
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %s", role)
+	}
+	srv.usersLock.Lock()
+	defer srv.usersLock.Unlock()
+	for i, u := range srv.users {
+		if u.Thumbprint != thumbprint {
+			continue
+		}
+		if u.Role == RoleAdmin && role != RoleAdmin && srv.adminCount() == 1 {
+			return fmt.Errorf("cannot remove the admin role from the last admin")
+		}
+		// Replace the user rather than modifying it, since other routines
+		// may be reading it
+		usr := *u
+		usr.Role = role
+		srv.users[i] = &usr
+		return srv.persistRoles()
+	}
+	return fmt.Errorf("user %s not found", thumbprint)
+}
+
+// storeRole records the role for a user whose certificate is about to be
+// added, such that it is picked up by the next ReloadCerts
+func (srv *HttpServer) storeRole(thumbprint string, role Role) error {
+	srv.usersLock.Lock()
+	defer srv.usersLock.Unlock()
+	roles, _, err := loadRoles()
+	if err != nil {
+		return err
+	}
+	// Make sure existing users are included, such that creating the file
+	// does not demote them
+	for _, u := range srv.users {
+		roles[u.Thumbprint] = u.Role
+	}
+	roles[thumbprint] = role
+	return writeRoles(roles)
+}
+
+// adminCount returns the number of users with the admin role. Must be called
+// with usersLock held
+func (srv *HttpServer) adminCount() int {
+	n := 0
+	for _, u := range srv.users {
+		if u.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/schedules"
+)
+
+// canEditSchedule returns true if the user that made request r can change,
+// fire or delete the schedule, which is limited to its owner and admins
+func (h *HttpServer) canEditSchedule(
+	r *http.Request,
+	s *schedules.Schedule,
+) bool {
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		return false
+	}
+	if usr.Thumbprint == s.OwnerThumbprint {
+		return true
+	}
+	role, err := h.RoleFromRequest(r)
+	return err == nil && role.Includes(RoleAdmin)
+}
+
+// scheduleFromRequest looks up the schedule in the request's path and writes
+// an error response if it does not exist, or if the user is not allowed to
+// change it and edit is true
+func (h *HttpServer) scheduleFromRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+	edit bool,
+) (*schedules.Schedule, bool) {
+	s, err := h.sm.Get(id)
+	if err != nil {
+		http.Error(w, "Not found", 404)
+		return nil, false
+	}
+	if edit && !h.canEditSchedule(r, s) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, false
+	}
+	return s, true
+}
+
+// canUseTemplate returns true if the template exists and the user that made
+// request r can see it, and can thus create test runs from it
+func (h *HttpServer) canUseTemplate(r *http.Request, id string) bool {
+	t, err := h.tm.Get(id)
+	return err == nil && h.canViewTemplate(r, t)
+}
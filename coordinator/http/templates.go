@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/mit-dci/opencbdc-tctl/coordinator/templates"
+)
+
+// canViewTemplate returns true if the user that made request r can see and
+// schedule the template: shared templates are visible to everyone, others
+// only to their owner and admins
+func (h *HttpServer) canViewTemplate(
+	r *http.Request,
+	t *templates.Template,
+) bool {
+	return t.Shared || h.canEditTemplate(r, t)
+}
+
+// canEditTemplate returns true if the user that made request r can change
+// or delete the template, which is limited to its owner and admins
+func (h *HttpServer) canEditTemplate(
+	r *http.Request,
+	t *templates.Template,
+) bool {
+	usr, err := h.UserFromRequest(r)
+	if err != nil {
+		return false
+	}
+	if usr.Thumbprint == t.OwnerThumbprint {
+		return true
+	}
+	role, err := h.RoleFromRequest(r)
+	return err == nil && role.Includes(RoleAdmin)
+}
+
+// templateFromRequest looks up the template in the request's path and writes
+// an error response if it does not exist or is not visible to the user
+func (h *HttpServer) templateFromRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	id string,
+) (*templates.Template, bool) {
+	t, err := h.tm.Get(id)
+	if err != nil || !h.canViewTemplate(r, t) {
+		http.Error(w, "Not found", 404)
+		return nil, false
+	}
+	return t, true
+}
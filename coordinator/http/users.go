@@ -6,6 +6,14 @@ import (
 )
 
 func (srv *HttpServer) UserFromThumbprint(thumbprint string) *SystemUser {
+	srv.usersLock.RLock()
+	defer srv.usersLock.RUnlock()
+	return srv.userFromThumbprint(thumbprint)
+}
+
+// userFromThumbprint looks up a registered user. Must be called with
+// usersLock held
+func (srv *HttpServer) userFromThumbprint(thumbprint string) *SystemUser {
 	for _, u := range srv.users {
 		if u.Thumbprint == thumbprint {
 			return u
@@ -14,7 +22,28 @@ func (srv *HttpServer) UserFromThumbprint(thumbprint string) *SystemUser {
 	return nil
 }
 
+// Users returns a copy of the list of registered users
+func (srv *HttpServer) Users() []*SystemUser {
+	srv.usersLock.RLock()
+	defer srv.usersLock.RUnlock()
+	ret := make([]*SystemUser, len(srv.users))
+	copy(ret, srv.users)
+	return ret
+}
+
+// UserFromRequest returns the user that made request r. If the request was
+// authenticated with an API token, that is the token's owner. If the
+// certificate belongs to a registered user, that user (including its role) is
+// returned
 func (srv *HttpServer) UserFromRequest(r *http.Request) (*SystemUser, error) {
+	if tok := TokenFromRequest(r); tok != nil {
+		usr := srv.UserFromThumbprint(tok.OwnerThumbprint)
+		if usr == nil {
+			return nil, fmt.Errorf("Owner of token %s not found", tok.ID)
+		}
+		return usr, nil
+	}
+
 	if r.TLS == nil {
 		return nil, fmt.Errorf("Request contains no TLS info")
 	}
@@ -23,5 +52,9 @@ func (srv *HttpServer) UserFromRequest(r *http.Request) (*SystemUser, error) {
 		return nil, fmt.Errorf("Request contains no TLS peer certificate")
 	}
 
-	return srv.UserFromCert(r.TLS.PeerCertificates[0]), nil
+	usr := srv.UserFromCert(r.TLS.PeerCertificates[0])
+	if registered := srv.UserFromThumbprint(usr.Thumbprint); registered != nil {
+		return registered, nil
+	}
+	return usr, nil
 }
@@ -12,7 +12,6 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 var upgrader = websocket.Upgrader{
@@ -39,7 +38,7 @@ func (c *websocketConn) sendLoop() {
 	for msg := range c.outgoing {
 		err := c.conn.WriteMessage(websocket.TextMessage, msg)
 		if err != nil {
-			logging.Errorf("Error writing to websocket: %v", err)
+			logger.Errorf("Error writing to websocket: %v", err)
 		}
 	}
 }
@@ -120,6 +119,8 @@ func (srv *HttpServer) publishToWebsocketsLoop() {
 				switch ev.Type {
 				case coordinator.EventTypeTestRunLogAppended:
 					write = c.subscribedToTestRunLogForTestRunID == ev.Payload.(coordinator.TestRunLogAppendedPayload).TestRunID
+				case coordinator.EventTypeTestRunLiveResults:
+					write = c.subscribedToTestRunLogForTestRunID == ev.Payload.(coordinator.TestRunLiveResultsPayload).TestRunID
 				}
 
 				if write {
@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
+)
+
+// The labels the Docker provider puts on the agent containers, such that it
+// can find them again after the coordinator restarts
+const (
+	dockerLabelTestRun  = "opencbdc-tctl.testrun"
+	dockerLabelTemplate = "opencbdc-tctl.template"
+)
+
+// DockerRegion is the region of all machines of the Docker provider
+const DockerRegion = "local"
+
+// DockerProvider is a provider that runs each agent in a container on a local
+// Docker daemon, attached to a user-defined bridge network. The templates set
+// the CPU and memory limits of the containers, and can use the IDs of the
+// launch templates they mirror such that test runs can be scheduled on either
+// provider. The ID of a machine is the container's name, which is also the
+// host name its agent reports
+type DockerProvider struct {
+	cfg             config.DockerConfig
+	coordinatorPort int
+	containers      map[string]*Machine
+	prepared        bool
+	lock            sync.Mutex
+}
+
+// NewDockerProvider creates a Docker provider. The agents connect to the
+// coordinator on the given port
+func NewDockerProvider(
+	cfg config.DockerConfig,
+	coordinatorPort int,
+) *DockerProvider {
+	return &DockerProvider{
+		cfg:             cfg,
+		coordinatorPort: coordinatorPort,
+		containers:      map[string]*Machine{},
+	}
+}
+
+// docker runs the docker command line client and returns its output
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(
+			"docker %s failed: %v: %s",
+			args[0],
+			err,
+			strings.TrimSpace(string(out)),
+		)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (d *DockerProvider) template(
+	id string,
+) (config.DockerTemplateConfig, error) {
+	for _, t := range d.cfg.Templates {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return config.DockerTemplateConfig{}, fmt.Errorf("template %s not found", id)
+}
+
+func (d *DockerProvider) Templates() []MachineTemplate {
+	ret := []MachineTemplate{}
+	for _, t := range d.cfg.Templates {
+		tpl, _ := d.Template(t.ID)
+		ret = append(ret, tpl)
+	}
+	return ret
+}
+
+func (d *DockerProvider) Template(id string) (MachineTemplate, error) {
+	t, err := d.template(id)
+	if err != nil {
+		return MachineTemplate{}, err
+	}
+	ret := MachineTemplate{
+		ID:           t.ID,
+		Region:       DockerRegion,
+		Description:  t.Description,
+		InstanceType: fmt.Sprintf("docker-%gcpu-%dmb", t.CPUs, t.MemoryMB),
+		VCPUCount:    int32(math.Ceil(t.CPUs)),
+		VCPU:         fmt.Sprintf("%g vCPU", t.CPUs),
+	}
+	if t.MemoryMB > 0 {
+		ret.RAM = fmt.Sprintf("%d MiB", t.MemoryMB)
+	}
+	return ret, nil
+}
+
+// VCPULimit returns the configured maximum number of CPUs of the containers
+// running at the same time, or no limit if it is not configured
+func (d *DockerProvider) VCPULimit(region string) int32 {
+	if d.cfg.MaxCPUs == 0 {
+		return math.MaxInt32
+	}
+	return int32(d.cfg.MaxCPUs)
+}
+
+func (d *DockerProvider) Available(requests []MachineRequest) error {
+	for _, r := range requests {
+		if _, err := d.template(r.TemplateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare builds the agent image if a build context is configured, and
+// creates the network if it does not exist yet. Must be called with the lock
+// held
+func (d *DockerProvider) prepare() error {
+	if d.prepared {
+		return nil
+	}
+	if d.cfg.BuildContext != "" {
+		logger.Infof("Building agent image %s", d.cfg.Image)
+		_, err := docker(
+			"build",
+			"-f",
+			filepath.Join(d.cfg.BuildContext, "Dockerfile.agent"),
+			"-t",
+			d.cfg.Image,
+			d.cfg.BuildContext,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := docker("network", "inspect", d.cfg.Network); err != nil {
+		logger.Infof("Creating network %s", d.cfg.Network)
+		_, err = docker(
+			"network",
+			"create",
+			"--driver",
+			"bridge",
+			d.cfg.Network,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	d.prepared = true
+	return nil
+}
+
+// coordinatorHost returns the host the agents connect to: the configured one,
+// or else the gateway of the network, which is the Docker host
+func (d *DockerProvider) coordinatorHost() (string, error) {
+	if d.cfg.CoordinatorHost != "" {
+		return d.cfg.CoordinatorHost, nil
+	}
+	return docker(
+		"network",
+		"inspect",
+		"-f",
+		"{{(index .IPAM.Config 0).Gateway}}",
+		d.cfg.Network,
+	)
+}
+
+// Launch starts an agent container for each of the requests
+func (d *DockerProvider) Launch(
+	requests []MachineRequest,
+	testRunID string,
+) ([]*Machine, []error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ret := make([]*Machine, len(requests))
+	err := d.prepare()
+	if err != nil {
+		return ret, []error{err}
+	}
+	host, err := d.coordinatorHost()
+	if err != nil {
+		return ret, []error{err}
+	}
+
+	for i, r := range requests {
+		t, err := d.template(r.TemplateID)
+		if err != nil {
+			return ret, []error{err}
+		}
+		randName := make([]byte, 8)
+		_, err = rand.Read(randName)
+		if err != nil {
+			return ret, []error{err}
+		}
+		name := fmt.Sprintf("test-agent-%x", randName)
+		args := []string{
+			"run",
+			"-d",
+			"--name", name,
+			"--hostname", name,
+			"--network", d.cfg.Network,
+			"--label", fmt.Sprintf("%s=%s", dockerLabelTestRun, testRunID),
+			"--label", fmt.Sprintf("%s=%s", dockerLabelTemplate, t.ID),
+			"--cpus", fmt.Sprintf("%g", t.CPUs),
+			"-e", fmt.Sprintf("COORDINATOR_HOST=%s", host),
+			"-e", fmt.Sprintf("COORDINATOR_PORT=%d", d.coordinatorPort),
+		}
+		if t.MemoryMB > 0 {
+			args = append(args, "--memory", fmt.Sprintf("%dm", t.MemoryMB))
+		}
+		for k, v := range d.cfg.Environment {
+			args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+		}
+		args = append(args, d.cfg.Image)
+		_, err = docker(args...)
+		if err != nil {
+			return ret, []error{err}
+		}
+		ret[i] = &Machine{
+			ID:         name,
+			TemplateID: t.ID,
+			Region:     DockerRegion,
+			TestRunID:  testRunID,
+		}
+		d.containers[name] = ret[i]
+	}
+	logger.Infof(
+		"Started %d agent containers for test run %s",
+		len(requests),
+		testRunID,
+	)
+	return ret, nil
+}
+
+// Terminate removes the agent containers
+func (d *DockerProvider) Terminate(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, err := docker(append([]string{"rm", "-f"}, ids...)...)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(d.containers, id)
+	}
+	return nil
+}
+
+// Running lists the agent containers on the Docker daemon, including the
+// ones started before the coordinator restarted
+func (d *DockerProvider) Running() []*Machine {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	out, err := docker(
+		"ps",
+		"--filter", fmt.Sprintf("label=%s", dockerLabelTestRun),
+		"--format", fmt.Sprintf(
+			"{{.Names}}\t{{.Label \"%s\"}}\t{{.Label \"%s\"}}",
+			dockerLabelTestRun,
+			dockerLabelTemplate,
+		),
+	)
+	if err != nil {
+		logger.Warnf("Unable to list agent containers: %v", err)
+		ret := []*Machine{}
+		for _, m := range d.containers {
+			ret = append(ret, m)
+		}
+		return ret
+	}
+	ret := []*Machine{}
+	containers := map[string]*Machine{}
+	for _, l := range strings.Split(out, "\n") {
+		f := strings.Split(l, "\t")
+		if len(f) != 3 {
+			continue
+		}
+		m := &Machine{
+			ID:         f[0],
+			TestRunID:  f[1],
+			TemplateID: f[2],
+			Region:     DockerRegion,
+		}
+		containers[m.ID] = m
+		ret = append(ret, m)
+	}
+	d.containers = containers
+	return ret
+}
+
+// MachineID returns the host name of the agent if it runs in one of the
+// provider's containers
+func (d *DockerProvider) MachineID(info common.AgentSystemInfo) string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if _, ok := d.containers[info.HostName]; ok {
+		return info.HostName
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// FakeProvider is an in-memory provider for tests. Launching a machine only
+// records it as running, no agent is started for it. Tests can simulate the
+// agent of a fake machine by connecting an agent whose host name is the
+// machine's ID
+type FakeProvider struct {
+	templates []MachineTemplate
+	vcpuLimit map[string]int32
+	machines  []*Machine
+	nextID    int
+	// If set, Launch fails with this error after launching FailAfter
+	// machines
+	LaunchErr error
+	FailAfter int
+	lock      sync.Mutex
+}
+
+// NewFakeProvider creates a fake provider with the given templates and vCPU
+// limits per region
+func NewFakeProvider(
+	templates []MachineTemplate,
+	vcpuLimit map[string]int32,
+) *FakeProvider {
+	return &FakeProvider{
+		templates: templates,
+		vcpuLimit: vcpuLimit,
+		machines:  []*Machine{},
+	}
+}
+
+func (f *FakeProvider) Templates() []MachineTemplate {
+	return f.templates
+}
+
+func (f *FakeProvider) Template(id string) (MachineTemplate, error) {
+	for _, t := range f.templates {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return MachineTemplate{}, fmt.Errorf("template %s not found", id)
+}
+
+func (f *FakeProvider) VCPULimit(region string) int32 {
+	return f.vcpuLimit[region]
+}
+
+func (f *FakeProvider) Available(requests []MachineRequest) error {
+	for _, r := range requests {
+		if _, err := f.Template(r.TemplateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FakeProvider) Launch(
+	requests []MachineRequest,
+	testRunID string,
+) ([]*Machine, []error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	ret := make([]*Machine, len(requests))
+	for i, r := range requests {
+		if f.LaunchErr != nil && i >= f.FailAfter {
+			return ret, []error{f.LaunchErr}
+		}
+		t, err := f.Template(r.TemplateID)
+		if err != nil {
+			return ret, []error{err}
+		}
+		f.nextID++
+		ret[i] = &Machine{
+			ID:         fmt.Sprintf("fake-%d", f.nextID),
+			TemplateID: r.TemplateID,
+			Region:     t.Region,
+			TestRunID:  testRunID,
+		}
+		f.machines = append(f.machines, ret[i])
+	}
+	return ret, nil
+}
+
+func (f *FakeProvider) Terminate(ids []string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	running := make([]*Machine, 0, len(f.machines))
+	for _, m := range f.machines {
+		terminate := false
+		for _, id := range ids {
+			if m.ID == id {
+				terminate = true
+				break
+			}
+		}
+		if !terminate {
+			running = append(running, m)
+		}
+	}
+	f.machines = running
+	return nil
+}
+
+func (f *FakeProvider) Running() []*Machine {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]*Machine{}, f.machines...)
+}
+
+func (f *FakeProvider) MachineID(info common.AgentSystemInfo) string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, m := range f.machines {
+		if m.ID == info.HostName {
+			return m.ID
+		}
+	}
+	return ""
+}
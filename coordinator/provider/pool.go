@@ -0,0 +1,334 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
+)
+
+// PoolProvider is a provider for a fixed set of hosts on which the agent
+// already runs as a service, for instance bare-metal machines in a rack.
+// Nothing is launched: the hosts are leased exclusively to a test run while
+// their agent is connected, and released again when it is terminated. The
+// ID of a machine is the host name its agent reports
+type PoolProvider struct {
+	coord  *coordinator.Coordinator
+	hosts  []config.PoolHostConfig
+	leases map[string]*Machine
+	lock   sync.Mutex
+}
+
+// NewPoolProvider creates a provider for the given hosts
+func NewPoolProvider(
+	c *coordinator.Coordinator,
+	hosts []config.PoolHostConfig,
+) *PoolProvider {
+	return &PoolProvider{
+		coord:  c,
+		hosts:  hosts,
+		leases: map[string]*Machine{},
+	}
+}
+
+// Templates returns no templates, roles select hosts by their labels
+func (p *PoolProvider) Templates() []MachineTemplate {
+	return []MachineTemplate{}
+}
+
+func (p *PoolProvider) Template(id string) (MachineTemplate, error) {
+	return MachineTemplate{}, fmt.Errorf(
+		"template %s not found, the host pool has no templates",
+		id,
+	)
+}
+
+// VCPULimit returns 0, the number of hosts limits the pool's capacity in
+// stead
+func (p *PoolProvider) VCPULimit(region string) int32 {
+	return 0
+}
+
+// idleHosts returns the hosts whose agent is connected and that are not
+// leased. Must be called with the lock held
+func (p *PoolProvider) idleHosts() []config.PoolHostConfig {
+	connected := map[string]bool{}
+	for _, a := range p.coord.GetAgents() {
+		connected[a.SystemInfo.HostName] = true
+	}
+	idle := []config.PoolHostConfig{}
+	for _, h := range p.hosts {
+		if _, leased := p.leases[h.HostName]; !leased && connected[h.HostName] {
+			idle = append(idle, h)
+		}
+	}
+	return idle
+}
+
+// maxPoolMatchSteps bounds the search for hosts that meet the placement
+// constraints of the requests
+const maxPoolMatchSteps = 100000
+
+func hasLabels(h config.PoolHostConfig, labels map[string]string) bool {
+	for k, v := range labels {
+		if h.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func hasExcludedLabel(
+	h config.PoolHostConfig,
+	excluded map[string][]string,
+) bool {
+	for k, values := range excluded {
+		v, ok := h.Labels[k]
+		if !ok {
+			continue
+		}
+		for _, e := range values {
+			if v == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatLabels(labels map[string]string) string {
+	l := []string{}
+	for k, v := range labels {
+		l = append(l, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(l)
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+// poolMatch searches for an idle host for each request, such that the hosts
+// meet the labels and placement constraints of the requests
+type poolMatch struct {
+	requests []MachineRequest
+	hosts    []config.PoolHostConfig
+	// The hosts that meet the labels of each request
+	candidates [][]int
+	// The order in which the requests pick a host, and the host each request
+	// picked or -1
+	order    []int
+	assigned []int
+	taken    []bool
+	steps    int
+}
+
+// related returns true if the constraints share a key with the constraint
+func related(constraints []PlacementConstraint, c PlacementConstraint) bool {
+	for _, o := range constraints {
+		if o.Key == c.Key && o.Label == c.Label {
+			return true
+		}
+	}
+	return false
+}
+
+// fits returns true if the host meets the placement constraints of request i
+// given the hosts the other requests picked so far
+func (m *poolMatch) fits(i, h int) bool {
+	labels := m.hosts[h].Labels
+	for _, c := range m.requests[i].Affinity {
+		v, ok := labels[c.Label]
+		if !ok {
+			return false
+		}
+		for j, o := range m.requests {
+			if m.assigned[j] == -1 || !related(o.Affinity, c) {
+				continue
+			}
+			if m.hosts[m.assigned[j]].Labels[c.Label] != v {
+				return false
+			}
+		}
+	}
+	for _, c := range m.requests[i].AntiAffinity {
+		v, ok := labels[c.Label]
+		if !ok {
+			return false
+		}
+		for j, o := range m.requests {
+			if m.assigned[j] == -1 || !related(o.AntiAffinity, c) {
+				continue
+			}
+			if m.hosts[m.assigned[j]].Labels[c.Label] == v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// search assigns a host to the requests from position n in the order on,
+// backtracking when a request has no host left that fits. Hosts with the same
+// labels are interchangeable, so only one of them is tried per request
+func (m *poolMatch) search(n int) bool {
+	if n == len(m.order) {
+		return true
+	}
+	i := m.order[n]
+	tried := map[string]bool{}
+	for _, h := range m.candidates[i] {
+		if m.taken[h] {
+			continue
+		}
+		key := formatLabels(m.hosts[h].Labels)
+		if tried[key] {
+			continue
+		}
+		tried[key] = true
+		m.steps++
+		if m.steps > maxPoolMatchSteps {
+			return false
+		}
+		if !m.fits(i, h) {
+			continue
+		}
+		m.taken[h] = true
+		m.assigned[i] = h
+		if m.search(n + 1) {
+			return true
+		}
+		m.taken[h] = false
+		m.assigned[i] = -1
+	}
+	return false
+}
+
+// assign picks an idle host for each of the requests, or returns an error if
+// there are not enough matching idle hosts or they cannot meet the placement
+// constraints. The requests with the fewest matching hosts pick first, and
+// the search backtracks if a later request is left without a host. Must be
+// called with the lock held
+func (p *PoolProvider) assign(
+	requests []MachineRequest,
+) ([]config.PoolHostConfig, error) {
+	m := &poolMatch{
+		requests:   requests,
+		hosts:      p.idleHosts(),
+		candidates: make([][]int, len(requests)),
+		order:      make([]int, len(requests)),
+		assigned:   make([]int, len(requests)),
+	}
+	m.taken = make([]bool, len(m.hosts))
+	for i, r := range requests {
+		m.order[i] = i
+		m.assigned[i] = -1
+		for h, host := range m.hosts {
+			if hasLabels(host, r.Labels) &&
+				!hasExcludedLabel(host, r.ExcludedLabels) {
+				m.candidates[i] = append(m.candidates[i], h)
+			}
+		}
+	}
+
+	// Report a shortage of hosts with the required labels as such, rather
+	// than as constraints that cannot be met
+	for i, r := range requests {
+		need := 0
+		for _, o := range requests {
+			if formatLabels(o.Labels) == formatLabels(r.Labels) {
+				need++
+			}
+		}
+		if len(m.candidates[i]) < need {
+			return nil, fmt.Errorf(
+				"needs %d idle hosts with labels %s in the pool",
+				need,
+				formatLabels(r.Labels),
+			)
+		}
+	}
+
+	sort.SliceStable(m.order, func(i, j int) bool {
+		return len(m.candidates[m.order[i]]) < len(m.candidates[m.order[j]])
+	})
+	if !m.search(0) {
+		return nil, fmt.Errorf(
+			"the idle hosts in the pool cannot meet the placement constraints of %d machines",
+			len(requests),
+		)
+	}
+	ret := make([]config.PoolHostConfig, len(requests))
+	for i, h := range m.assigned {
+		ret[i] = m.hosts[h]
+	}
+	return ret, nil
+}
+
+func (p *PoolProvider) Available(requests []MachineRequest) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	_, err := p.assign(requests)
+	return err
+}
+
+// Launch leases a matching idle host for each of the requests. Either all
+// requests get a host, or none
+func (p *PoolProvider) Launch(
+	requests []MachineRequest,
+	testRunID string,
+) ([]*Machine, []error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	hosts, err := p.assign(requests)
+	if err != nil {
+		return make([]*Machine, len(requests)), []error{err}
+	}
+	ret := make([]*Machine, len(requests))
+	for i, h := range hosts {
+		ret[i] = &Machine{
+			ID:        h.HostName,
+			Region:    h.Labels["region"],
+			TestRunID: testRunID,
+		}
+		p.leases[h.HostName] = ret[i]
+		logger.Infof("Leased host %s to test run %s", h.HostName, testRunID)
+	}
+	return ret, nil
+}
+
+// Terminate releases the leases on the hosts, such that other test runs can
+// use them
+func (p *PoolProvider) Terminate(ids []string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, id := range ids {
+		if m, ok := p.leases[id]; ok {
+			logger.Infof("Released host %s from test run %s", id, m.TestRunID)
+			delete(p.leases, id)
+		}
+	}
+	return nil
+}
+
+// Running returns the leased hosts
+func (p *PoolProvider) Running() []*Machine {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ret := []*Machine{}
+	for _, m := range p.leases {
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// MachineID returns the host name of the agent if its host is leased
+func (p *PoolProvider) MachineID(info common.AgentSystemInfo) string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.leases[info.HostName]; ok {
+		return info.HostName
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+)
+
+var logger = logging.NewLogger("provider")
+
+// MachineTemplate describes a kind of machine a provider can launch, such as
+// an EC2 launch template
+type MachineTemplate struct {
+	ID           string `json:"id"`
+	Region       string `json:"region"`
+	Description  string `json:"description"`
+	InstanceType string `json:"instanceType"`
+	VCPUCount    int32  `json:"vCPUCount"`
+	// Descriptions of the resources of the machines, for display
+	VCPU      string `json:"vCPU"`
+	RAM       string `json:"ram"`
+	Bandwidth string `json:"bandwidth"`
+}
+
+// Machine describes a running machine that was launched by a provider
+type Machine struct {
+	ID         string `json:"id"`
+	TemplateID string `json:"templateID"`
+	Region     string `json:"region"`
+	// The test run the machine was launched for, empty if it is not known
+	TestRunID string `json:"testRunID"`
+}
+
+// PlacementConstraint relates the machines of all requests that carry a
+// constraint with the same key: they must all have the same value of the
+// label (affinity), or each a different one (anti-affinity). Keys include the
+// test run, such that the requests of several test runs can be combined
+type PlacementConstraint struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// MachineRequest describes a machine a role needs: one launched from a
+// template, or a host that has all of the given labels. The placement
+// constraints relate it to the other requests, and the excluded labels are
+// the values the machine cannot have because machines that were placed
+// before have them
+type MachineRequest struct {
+	TemplateID     string                `json:"templateID"`
+	Labels         map[string]string     `json:"labels"`
+	ExcludedLabels map[string][]string   `json:"excludedLabels"`
+	Affinity       []PlacementConstraint `json:"affinity"`
+	AntiAffinity   []PlacementConstraint `json:"antiAffinity"`
+}
+
+// Provider is the infrastructure the test agents run on. The test run manager
+// uses it to launch the machines for a test run's roles, and to terminate them
+// once the test run no longer needs them
+type Provider interface {
+	// Templates returns the templates machines can be launched from
+	Templates() []MachineTemplate
+	// Template returns a single template, or an error if it does not exist
+	Template(id string) (MachineTemplate, error)
+	// VCPULimit returns the maximum number of vCPUs of the machines that can
+	// run in a region at the same time
+	VCPULimit(region string) int32
+	// Available returns an error describing what is missing if the requested
+	// machines cannot be launched at the moment
+	Available(requests []MachineRequest) error
+	// Launch launches a machine for each of the requests, tagged with the
+	// test run. Providers that choose between machines with different labels
+	// honor the placement constraints of the requests, others launch the
+	// machines their templates describe. The machines are returned in the same order as the requests.
+	// If errors occur, the machines that did launch are returned as well and
+	// the others are nil
+	Launch(requests []MachineRequest, testRunID string) ([]*Machine, []error)
+	// Terminate terminates the machines with the given IDs
+	Terminate(ids []string) error
+	// Running returns the machines that are currently running
+	Running() []*Machine
+	// MachineID returns the ID of the machine an agent runs on, based on the
+	// system information it sent on connecting, or an empty string if the
+	// agent does not run on a machine of this provider
+	MachineID(info common.AgentSystemInfo) string
+}
@@ -0,0 +1,111 @@
+package results
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// Tolerances of Compare, relative to the largest of the two values. The
+// script approximates the latency percentiles of tx_samples files, so these
+// get a larger tolerance
+const (
+	compareTolerance           = 1e-6
+	latencyPercentileTolerance = 0.01
+)
+
+// Difference is a metric for which two results differ by more than the
+// tolerance
+type Difference struct {
+	Field    string  `json:"field"`
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
+}
+
+// Compare returns the metrics in which actual differs from expected, where
+// fields are named by their JSON name, with the bucket or title in brackets.
+// The block latencies are not compared, as the script does not calculate
+// them
+func Compare(expected, actual *common.TestResult) []Difference {
+	diffs := []Difference{}
+	check := func(field string, e, a, tolerance float64) {
+		if math.Abs(e-a) <= tolerance*math.Max(math.Abs(e), math.Abs(a)) {
+			return
+		}
+		diffs = append(diffs, Difference{field, e, a})
+	}
+
+	check("throughputAvg", expected.ThroughputAvg, actual.ThroughputAvg,
+		compareTolerance)
+	check("throughputStd", expected.ThroughputStd, actual.ThroughputStd,
+		compareTolerance)
+	check("throughputMin", expected.ThroughputMin, actual.ThroughputMin,
+		compareTolerance)
+	check("throughputMax", expected.ThroughputMax, actual.ThroughputMax,
+		compareTolerance)
+	check("throughputAvg2", expected.ThroughputAvg2, actual.ThroughputAvg2,
+		compareTolerance)
+	check("latencyAvg", expected.LatencyAvg, actual.LatencyAvg,
+		compareTolerance)
+	check("latencyStd", expected.LatencyStd, actual.LatencyStd,
+		compareTolerance)
+	check("latencyMin", expected.LatencyMin, actual.LatencyMin,
+		compareTolerance)
+	check("latencyMax", expected.LatencyMax, actual.LatencyMax,
+		compareTolerance)
+
+	titles := map[string]bool{}
+	for title := range expected.ThroughputAvgs {
+		titles[title] = true
+	}
+	for title := range actual.ThroughputAvgs {
+		titles[title] = true
+	}
+	sorted := []string{}
+	for title := range titles {
+		sorted = append(sorted, title)
+	}
+	sort.Strings(sorted)
+	for _, title := range sorted {
+		check(
+			fmt.Sprintf("throughputAvgs[%s]", title),
+			expected.ThroughputAvgs[title],
+			actual.ThroughputAvgs[title],
+			compareTolerance,
+		)
+	}
+
+	comparePercentiles := func(
+		field string,
+		expected, actual []common.TestResultPercentile,
+		tolerance float64,
+	) {
+		values := map[float64]float64{}
+		for _, p := range actual {
+			values[p.Bucket] = p.Value
+		}
+		for _, p := range expected {
+			check(
+				fmt.Sprintf("%s[%g]", field, p.Bucket),
+				p.Value,
+				values[p.Bucket],
+				tolerance,
+			)
+		}
+	}
+	comparePercentiles(
+		"throughputPercentiles",
+		expected.ThroughputPercentiles,
+		actual.ThroughputPercentiles,
+		compareTolerance,
+	)
+	comparePercentiles(
+		"latencyPercentiles",
+		expected.LatencyPercentiles,
+		actual.LatencyPercentiles,
+		latencyPercentileTolerance,
+	)
+	return diffs
+}
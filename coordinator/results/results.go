@@ -0,0 +1,223 @@
+// Package results calculates the results of a test run from the sample files
+// the roles write, in the same way as the calculate_results.py script but
+// without plotting
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// Options are the settings of the test run that affect its results
+type Options struct {
+	// The number of samples to drop from the start of each throughput series,
+	// after trimming the zeroes
+	TrimSamples int
+	// Drop the zero samples at the start of each throughput series, while the
+	// system is started but no load is generated yet
+	TrimZeroesAtStart bool
+	// Drop the zero samples at the end of each throughput series
+	TrimZeroesAtEnd bool
+}
+
+// OptionsFor returns the result options of the test run
+func OptionsFor(tr *common.TestRun) Options {
+	return Options{
+		TrimSamples:       tr.TrimSamplesAtStart,
+		TrimZeroesAtStart: tr.TrimZeroesAtStart,
+		TrimZeroesAtEnd:   tr.TrimZeroesAtEnd,
+	}
+}
+
+// Calculate calculates the results from the output files of a test run in
+// dir. Load generators that write tx_samples files give the throughput per
+// second and the latency of each transaction. Otherwise the throughput comes
+// from the tp_samples files, per block, and the latency from the
+// latency_samples files. The latencies of the block_log files are reported
+// per role in BlockLatencies
+func Calculate(dir string, opts Options) (*common.TestResult, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, f := range files {
+		if !f.IsDir() && !strings.Contains(f.Name(), "hdf5") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	twoPhase := false
+	for _, name := range names {
+		if strings.Contains(name, "tx_samples") {
+			twoPhase = true
+		}
+	}
+
+	lines := []series{}
+	latencies := newDistribution()
+	if twoPhase {
+		tx := newTxSamples()
+		targets := &tpsTargets{}
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if strings.Contains(name, "tx_samples") {
+				err = tx.read(path)
+			} else if strings.Contains(name, "tps_target_") {
+				err = targets.read(path)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if tx.latencies.n > 0 {
+			lines = append(lines, series{"Loadgens", tx.throughput()})
+			if len(targets.files) > 0 {
+				lines = append(lines, series{
+					"Loadgen target",
+					targets.throughput(tx.firstSec, tx.lastSec),
+				})
+			}
+		}
+		latencies = tx.latencies
+	} else {
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if strings.Contains(name, "tp_samples") {
+				vals, err := readValues(path, 1)
+				if err != nil {
+					return nil, err
+				}
+				title := strings.Replace(name, "-tp_samples.txt", "", 1)
+				lines = append(lines, series{title, vals})
+			} else if strings.Contains(name, "latency_samples_") {
+				vals, err := readValues(path, 1e9)
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range vals {
+					latencies.add(v)
+				}
+			}
+		}
+	}
+
+	for i := range lines {
+		lines[i].values = trim(lines[i].values, opts)
+	}
+	if len(lines) == 0 || len(lines[0].values) == 0 {
+		return nil, errors.New("no throughput samples")
+	}
+	if latencies.n == 0 {
+		return nil, errors.New("no latency samples")
+	}
+
+	res := &common.TestResult{}
+	tp := seriesDistribution(lines[0].values)
+	res.ThroughputAvg = tp.mean()
+	res.ThroughputStd = tp.std()
+	res.ThroughputMin = tp.min()
+	res.ThroughputMax = tp.max()
+	res.ThroughputPercentiles = percentiles(tp)
+	if len(lines) > 1 {
+		if len(lines[1].values) > 0 {
+			res.ThroughputAvg2 = mean(lines[1].values)
+		}
+		res.ThroughputAvgs = map[string]float64{}
+		for _, l := range lines {
+			if len(l.values) > 0 {
+				res.ThroughputAvgs[l.title] = mean(l.values)
+			}
+		}
+	}
+
+	res.LatencyAvg = latencies.mean()
+	res.LatencyStd = latencies.std()
+	res.LatencyMin = latencies.min()
+	res.LatencyMax = latencies.max()
+	res.LatencyPercentiles = percentiles(latencies)
+
+	res.BlockLatencies = map[string]common.BlockLatencyResult{}
+	for _, name := range names {
+		if !strings.HasSuffix(name, "block_log.txt") {
+			continue
+		}
+		d, err := readBlockLog(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if d.n == 0 {
+			continue
+		}
+		title := strings.TrimSuffix(strings.TrimSuffix(name, "block_log.txt"), "-")
+		res.BlockLatencies[title] = common.BlockLatencyResult{
+			Average: d.mean(),
+			Min:     d.min(),
+			Max:     d.max(),
+			StdDev:  d.std(),
+		}
+	}
+	return res, nil
+}
+
+// trim drops the zeroes at the start and end of a throughput series and the
+// warm-up samples at its start, according to the options. Values are zero if
+// they round down to zero
+func trim(vals []float64, opts Options) []float64 {
+	isZero := func(v float64) bool { return math.Trunc(v) == 0 }
+	if opts.TrimZeroesAtStart {
+		for len(vals) > 0 && isZero(vals[0]) {
+			vals = vals[1:]
+		}
+	}
+	if opts.TrimZeroesAtEnd {
+		for len(vals) > 0 && isZero(vals[len(vals)-1]) {
+			vals = vals[:len(vals)-1]
+		}
+	}
+	n := opts.TrimSamples
+	if n < 0 {
+		// Like a negative slice index in the script, keep the last samples
+		n += len(vals)
+		if n < 0 {
+			n = 0
+		}
+	}
+	if n > len(vals) {
+		n = len(vals)
+	}
+	return vals[n:]
+}
+
+func percentiles(d *distribution) []common.TestResultPercentile {
+	ret := []common.TestResultPercentile{}
+	for i, v := range d.percentiles(percentileBuckets) {
+		ret = append(ret, common.TestResultPercentile{
+			Bucket: percentileBuckets[i],
+			Value:  v,
+		})
+	}
+	return ret
+}
+
+// Write writes the result to path in the format of the calculation script
+func Write(path string, res *common.TestResult) error {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,431 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// writeFiles writes the files, keyed by name, to a temporary directory and
+// returns its path
+func writeFiles(t *testing.T, files map[string][]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, lines := range files {
+		content := strings.Join(lines, "\n") + "\n"
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !almostEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		name string
+		vals []float64
+		opts Options
+		want []float64
+	}{
+		{
+			name: "no trimming",
+			vals: []float64{0, 1, 2, 0},
+			opts: Options{},
+			want: []float64{0, 1, 2, 0},
+		},
+		{
+			name: "zeroes at start",
+			vals: []float64{0, 0.9, 1, 0, 2, 0},
+			opts: Options{TrimZeroesAtStart: true},
+			want: []float64{1, 0, 2, 0},
+		},
+		{
+			name: "zeroes at end",
+			vals: []float64{0, 1, 0, 2, 0.5, 0},
+			opts: Options{TrimZeroesAtEnd: true},
+			want: []float64{0, 1, 0, 2},
+		},
+		{
+			name: "zeroes and samples at start",
+			vals: []float64{0, 0, 5, 6, 7, 8, 0},
+			opts: Options{
+				TrimSamples:       2,
+				TrimZeroesAtStart: true,
+				TrimZeroesAtEnd:   true,
+			},
+			want: []float64{7, 8},
+		},
+		{
+			name: "more samples than there are",
+			vals: []float64{1, 2},
+			opts: Options{TrimSamples: 5},
+			want: []float64{},
+		},
+		{
+			name: "negative keeps the last samples",
+			vals: []float64{1, 2, 3, 4},
+			opts: Options{TrimSamples: -1},
+			want: []float64{4},
+		},
+		{
+			name: "only zeroes",
+			vals: []float64{0, 0.2, 0},
+			opts: Options{TrimZeroesAtStart: true, TrimZeroesAtEnd: true},
+			want: []float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trim(tt.vals, tt.opts)
+			if !equalValues(got, tt.want) {
+				t.Errorf("trim() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		vals    []float64
+		buckets []float64
+		want    []float64
+	}{
+		{
+			name:    "single value",
+			vals:    []float64{3},
+			buckets: []float64{0.001, 50, 99.999},
+			want:    []float64{3, 3, 3},
+		},
+		{
+			name:    "interpolates between ranks",
+			vals:    []float64{4, 1, 3, 2},
+			buckets: []float64{0, 25, 50, 75, 100},
+			want:    []float64{1, 1.75, 2.5, 3.25, 4},
+		},
+		{
+			name:    "repeated values",
+			vals:    []float64{1, 1, 1, 5},
+			buckets: []float64{50, 90, 99},
+			want:    []float64{1, 3.8, 4.88},
+		},
+		{
+			name:    "tail",
+			vals:    []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			buckets: []float64{1, 99.9},
+			want:    []float64{1.09, 9.991},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := seriesDistribution(tt.vals).percentiles(tt.buckets)
+			if !equalValues(got, tt.want) {
+				t.Errorf("percentiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTxSamplesThroughput(t *testing.T) {
+	// Sample times are in nanoseconds, at second 1700000000 and on
+	const sec = int64(1700000000) * 1e9
+	line := func(offset float64, latency string) string {
+		return fmt.Sprintf("%d %s", sec+int64(offset*1e9), latency)
+	}
+	tests := []struct {
+		name          string
+		lines         []string
+		wantTPS       []float64
+		wantLatencies int64
+	}{
+		{
+			name: "last second is incomplete",
+			lines: []string{
+				line(0.1, "1000000"),
+				line(0.5, "1000000"),
+				line(1.2, "1000000"),
+				line(2.9, "1000000"),
+			},
+			wantTPS:       []float64{2, 1},
+			wantLatencies: 4,
+		},
+		{
+			name: "gaps count as zero",
+			lines: []string{
+				line(0, "1000000"),
+				line(3, "1000000"),
+				line(4, "1000000"),
+			},
+			wantTPS:       []float64{1, 0, 0, 1},
+			wantLatencies: 3,
+		},
+		{
+			name: "corrupt lines and times are skipped",
+			lines: []string{
+				"1000 1000000",
+				"not a sample",
+				line(0, "1000000"),
+				line(1, "1000000 7"),
+				line(1, "1000000"),
+			},
+			wantTPS:       []float64{1},
+			wantLatencies: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string][]string{
+				"tx_samples_0.txt": tt.lines,
+			})
+			tx := newTxSamples()
+			err := tx.read(filepath.Join(dir, "tx_samples_0.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tx.throughput(); !equalValues(got, tt.wantTPS) {
+				t.Errorf("throughput() = %v, want %v", got, tt.wantTPS)
+			}
+			if tx.latencies.n != tt.wantLatencies {
+				t.Errorf(
+					"%d latencies, want %d",
+					tx.latencies.n,
+					tt.wantLatencies,
+				)
+			}
+		})
+	}
+}
+
+func TestTpsTargetsThroughput(t *testing.T) {
+	tests := []struct {
+		name  string
+		files [][]string
+		want  []float64
+	}{
+		{
+			name:  "target holds until it changes",
+			files: [][]string{{"10000000000 100", "12000000000 200"}},
+			want:  []float64{100, 100, 200, 200},
+		},
+		{
+			name:  "target set before the first second",
+			files: [][]string{{"6000000000 50"}},
+			want:  []float64{50, 50, 50, 50},
+		},
+		{
+			name:  "target set too long before the first second",
+			files: [][]string{{"4000000000 50", "11000000000 60"}},
+			want:  []float64{0, 60, 60, 60},
+		},
+		{
+			name: "targets of load generators are summed",
+			files: [][]string{
+				{"10000000000 100"},
+				{"11000000000 30", "13000000000 40"},
+			},
+			want: []float64{100, 130, 130, 140},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string][]string{}
+			for i, lines := range tt.files {
+				files[fmt.Sprintf("tps_target_%d.txt", i)] = lines
+			}
+			dir := writeFiles(t, files)
+			targets := &tpsTargets{}
+			for name := range files {
+				err := targets.read(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := targets.throughput(10, 14)
+			if !equalValues(got, tt.want) {
+				t.Errorf("throughput() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockLatencies(t *testing.T) {
+	samples := map[string][]string{
+		"archiver0-tp_samples.txt": {"10", "20"},
+		"latency_samples_0_0.txt":  {"1000000000"},
+	}
+	tests := []struct {
+		name  string
+		files map[string][]string
+		want  map[string]common.BlockLatencyResult
+	}{
+		{
+			name: "per role, rounded down to milliseconds",
+			files: map[string][]string{
+				"shard0-block_log.txt": {
+					"1 1500900000 1",
+					"2 2500000000 2",
+					"corrupt",
+				},
+				"atomizer0-block_log.txt": {"1 3000000 1"},
+			},
+			want: map[string]common.BlockLatencyResult{
+				"shard0": {
+					Average: 2,
+					Min:     1.5,
+					Max:     2.5,
+					StdDev:  0.5,
+				},
+				"atomizer0": {
+					Average: 0.003,
+					Min:     0.003,
+					Max:     0.003,
+				},
+			},
+		},
+		{
+			name: "empty block logs are left out",
+			files: map[string][]string{
+				"shard0-block_log.txt": {},
+			},
+			want: map[string]common.BlockLatencyResult{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string][]string{}
+			for name, lines := range samples {
+				files[name] = lines
+			}
+			for name, lines := range tt.files {
+				files[name] = lines
+			}
+			res, err := Calculate(writeFiles(t, files), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.BlockLatencies) != len(tt.want) {
+				t.Fatalf(
+					"BlockLatencies = %v, want %v",
+					res.BlockLatencies,
+					tt.want,
+				)
+			}
+			values := func(r common.BlockLatencyResult) []float64 {
+				return []float64{r.Average, r.Min, r.Max, r.StdDev}
+			}
+			for role, want := range tt.want {
+				got, ok := res.BlockLatencies[role]
+				if !ok || !equalValues(values(got), values(want)) {
+					t.Errorf(
+						"BlockLatencies[%s] = %v, want %v",
+						role,
+						got,
+						want,
+					)
+				}
+			}
+		})
+	}
+}
+
+// TestParity compares the results with those of calculate_results.py for the
+// sample files in testdata/parity/outputs, which are in results2.json: the
+// mean, population standard deviation and linearly interpolated percentiles
+// numpy gives for the trimmed samples. The script writes the file when it is
+// run in testdata/parity (with a plots directory) with the environment
+// TRIM_ZEROES_START=1 TRIM_ZEROES_END=1 TRIM_SAMPLES=2 BLOCK_TIME=1000
+func TestParity(t *testing.T) {
+	dir := filepath.Join("testdata", "parity")
+	b, err := ioutil.ReadFile(filepath.Join(dir, "results2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want common.TestResult
+	if err := json.Unmarshal(b, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Calculate(filepath.Join(dir, "outputs"), Options{
+		TrimSamples:       2,
+		TrimZeroesAtStart: true,
+		TrimZeroesAtEnd:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := []struct {
+		name      string
+		got, want float64
+	}{
+		{"throughputAvg", got.ThroughputAvg, want.ThroughputAvg},
+		{"throughputStd", got.ThroughputStd, want.ThroughputStd},
+		{"throughputMin", got.ThroughputMin, want.ThroughputMin},
+		{"throughputMax", got.ThroughputMax, want.ThroughputMax},
+		{"latencyAvg", got.LatencyAvg, want.LatencyAvg},
+		{"latencyStd", got.LatencyStd, want.LatencyStd},
+		{"latencyMin", got.LatencyMin, want.LatencyMin},
+		{"latencyMax", got.LatencyMax, want.LatencyMax},
+	}
+	for _, f := range fields {
+		if !almostEqual(f.got, f.want) {
+			t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+		}
+	}
+	percentiles := []struct {
+		name      string
+		got, want []common.TestResultPercentile
+	}{
+		{
+			"throughputPercentiles",
+			got.ThroughputPercentiles,
+			want.ThroughputPercentiles,
+		},
+		{
+			"latencyPercentiles",
+			got.LatencyPercentiles,
+			want.LatencyPercentiles,
+		},
+	}
+	for _, p := range percentiles {
+		if len(p.got) != len(p.want) {
+			t.Errorf("%d %s, want %d", len(p.got), p.name, len(p.want))
+			continue
+		}
+		for i := range p.want {
+			if p.got[i].Bucket != p.want[i].Bucket ||
+				!almostEqual(p.got[i].Value, p.want[i].Value) {
+				t.Errorf(
+					"%s[%d] = %v, want %v",
+					p.name,
+					i,
+					p.got[i],
+					p.want[i],
+				)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package results
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// minSampleTime filters out corrupt sample times. The calculation script
+// compares the nanosecond timestamps with this value, so this only drops
+// timestamps within the first half hour of 1970
+const minSampleTime = 1609459200000
+
+// maxFailedLines is the number of lines of a throughput or latency sample
+// file that may fail to parse before the file is considered corrupt
+const maxFailedLines = 10
+
+// series is a line of per-second (or per-block) throughput values
+type series struct {
+	title  string
+	values []float64
+}
+
+// forEachLine calls f with the fields of each line of the file
+func forEachLine(path string, f func(fields []string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		f(strings.Fields(scanner.Text()))
+	}
+	return scanner.Err()
+}
+
+// parseIntFields parses a line that should have exactly n integer fields
+func parseIntFields(fields []string, n int) ([]int64, bool) {
+	if len(fields) != n {
+		return nil, false
+	}
+	ret := make([]int64, n)
+	for i, f := range fields {
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ret[i] = v
+	}
+	return ret, true
+}
+
+// txSamples holds the transactions completed by the load generators, from
+// the `<time> <latency>` lines of their tx_samples files, where both are in
+// nanoseconds
+type txSamples struct {
+	// The number of transactions completed in each second
+	perSecond         map[int64]float64
+	firstSec, lastSec int64
+	// The latencies in seconds, rounded down to whole milliseconds
+	latencies *distribution
+}
+
+func newTxSamples() *txSamples {
+	return &txSamples{
+		perSecond: map[int64]float64{},
+		latencies: newDistribution(),
+	}
+}
+
+func (s *txSamples) read(path string) error {
+	return forEachLine(path, func(fields []string) {
+		v, ok := parseIntFields(fields, 2)
+		if !ok || v[0] <= minSampleTime {
+			return
+		}
+		sec := v[0] / 1e9
+		if s.latencies.n == 0 || sec < s.firstSec {
+			s.firstSec = sec
+		}
+		if s.latencies.n == 0 || sec > s.lastSec {
+			s.lastSec = sec
+		}
+		s.perSecond[sec]++
+		s.latencies.add(float64(v[1]/1e6) / 1e3)
+	})
+}
+
+// throughput returns the number of transactions per second, from the first
+// second with samples up to (but not including) the last one, which is
+// incomplete
+func (s *txSamples) throughput() []float64 {
+	tps := []float64{}
+	for sec := s.firstSec; sec < s.lastSec; sec++ {
+		tps = append(tps, s.perSecond[sec])
+	}
+	return tps
+}
+
+// tpsTargets holds the targets of the load generators from the
+// `<time> <target>` lines of their tps_target files
+type tpsTargets struct {
+	files []map[int64]float64
+}
+
+func (t *tpsTargets) read(path string) error {
+	targets := map[int64]float64{}
+	err := forEachLine(path, func(fields []string) {
+		v, ok := parseIntFields(fields, 2)
+		if !ok {
+			return
+		}
+		targets[v[0]/1e9] = float64(v[1])
+	})
+	if err != nil {
+		return err
+	}
+	if len(targets) > 0 {
+		t.files = append(t.files, targets)
+	}
+	return nil
+}
+
+// throughput returns the sum of the targets of all load generators for each
+// second from firstSec up to (but not including) lastSec. A load generator's
+// target holds until it changes, starting at most five seconds before
+// firstSec
+func (t *tpsTargets) throughput(firstSec, lastSec int64) []float64 {
+	tps := []float64{}
+	for sec := firstSec; sec < lastSec; sec++ {
+		tps = append(tps, 0)
+	}
+	for _, targets := range t.files {
+		set := false
+		current := float64(0)
+		for sec := firstSec - 5; sec < lastSec; sec++ {
+			if v, ok := targets[sec]; ok {
+				current = v
+				set = true
+			}
+			if set && sec >= firstSec {
+				tps[sec-firstSec] += current
+			}
+		}
+	}
+	return tps
+}
+
+// readValues reads a file with a number per line, divided by div
+func readValues(path string, div float64) ([]float64, error) {
+	vals := []float64{}
+	failed := 0
+	err := forEachLine(path, func(fields []string) {
+		if len(fields) != 1 {
+			failed++
+			return
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || math.IsNaN(v) {
+			failed++
+			return
+		}
+		vals = append(vals, v/div)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed > maxFailedLines {
+		return nil, fmt.Errorf("too many failed values in %s", path)
+	}
+	return vals, nil
+}
+
+// readBlockLog reads the latencies from the `<time> <latency> <height>`
+// lines of a block log, in seconds rounded down to whole milliseconds
+func readBlockLog(path string) (*distribution, error) {
+	d := newDistribution()
+	err := forEachLine(path, func(fields []string) {
+		v, ok := parseIntFields(fields, 3)
+		if !ok {
+			return
+		}
+		d.add(float64(v[1]/1e6) / 1e3)
+	})
+	return d, err
+}
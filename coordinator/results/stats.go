@@ -0,0 +1,107 @@
+package results
+
+import (
+	"math"
+	"sort"
+)
+
+// percentileBuckets are the percentiles reported for throughput and latency
+var percentileBuckets = []float64{
+	0.001, 0.01, 0.1, 1, 25, 50, 75, 99, 99.9, 99.99, 99.999,
+}
+
+// distribution holds the number of occurrences of each value of a sample,
+// such that the latencies of large sample files, which are whole numbers of
+// milliseconds, take little memory
+type distribution struct {
+	counts map[float64]int64
+	n      int64
+	sum    float64
+}
+
+func newDistribution() *distribution {
+	return &distribution{counts: map[float64]int64{}}
+}
+
+func (d *distribution) add(v float64) {
+	d.counts[v]++
+	d.n++
+	d.sum += v
+}
+
+func (d *distribution) mean() float64 {
+	return d.sum / float64(d.n)
+}
+
+// std returns the population standard deviation
+func (d *distribution) std() float64 {
+	m := d.mean()
+	ss := float64(0)
+	for v, c := range d.counts {
+		ss += float64(c) * (v - m) * (v - m)
+	}
+	return math.Sqrt(ss / float64(d.n))
+}
+
+// sorted returns the distinct values in ascending order
+func (d *distribution) sorted() []float64 {
+	vals := make([]float64, 0, len(d.counts))
+	for v := range d.counts {
+		vals = append(vals, v)
+	}
+	sort.Float64s(vals)
+	return vals
+}
+
+func (d *distribution) min() float64 {
+	return d.sorted()[0]
+}
+
+func (d *distribution) max() float64 {
+	vals := d.sorted()
+	return vals[len(vals)-1]
+}
+
+// percentiles returns the given percentiles, interpolated linearly between
+// the closest ranks like numpy's default
+func (d *distribution) percentiles(buckets []float64) []float64 {
+	vals := d.sorted()
+	// cum[i] is the number of samples with a value up to vals[i]
+	cum := make([]int64, len(vals))
+	total := int64(0)
+	for i, v := range vals {
+		total += d.counts[v]
+		cum[i] = total
+	}
+	at := func(rank int64) float64 {
+		i := sort.Search(len(cum), func(i int) bool { return cum[i] > rank })
+		return vals[i]
+	}
+
+	ret := make([]float64, len(buckets))
+	for i, b := range buckets {
+		rank := b / 100 * float64(d.n-1)
+		lo := math.Floor(rank)
+		lower := at(int64(lo))
+		upper := at(int64(math.Ceil(rank)))
+		ret[i] = lower + (upper-lower)*(rank-lo)
+	}
+	return ret
+}
+
+// seriesDistribution returns the distribution of the values of a series
+func seriesDistribution(vals []float64) *distribution {
+	d := newDistribution()
+	for _, v := range vals {
+		d.add(v)
+	}
+	return d
+}
+
+func mean(vals []float64) float64 {
+	sum := float64(0)
+	for _, v := range vals {
+		sum += v
+	}
+	return sum / float64(len(vals))
+}
@@ -0,0 +1,215 @@
+package schedules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField describes the range of one of the five fields of a cron
+// expression
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// cronShorthands are the supported @-expressions
+var cronShorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// CronExpr is a parsed cron expression in the standard five-field format
+// (minute, hour, day of month, month, day of week). Fields support *, lists
+// (1,15), ranges (1-5) and steps (*/15, 0-30/10)
+type CronExpr struct {
+	// The allowed values per field, as bit sets
+	minute, hour, dom, month, dow uint64
+	// If either day field is restricted, a day matches if it matches either
+	// of them, like in Vixie cron
+	domAny, dowAny bool
+}
+
+// ParseCron parses a cron expression
+func ParseCron(expr string) (*CronExpr, error) {
+	expr = strings.TrimSpace(expr)
+	if s, ok := cronShorthands[expr]; ok {
+		expr = s
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf(
+			"cron expression %q should have %d fields",
+			expr,
+			len(cronFields),
+		)
+	}
+	sets := make([]uint64, len(parts))
+	for i, p := range parts {
+		set, err := parseCronField(p, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	// Sunday can be written as 7 as well
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &CronExpr{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseCronField(s string, f cronField) (uint64, error) {
+	max := f.max
+	if f.name == "day of week" {
+		max = 7
+	}
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %s", f.name, s)
+			}
+			item = item[:i]
+		}
+		lo, hi := f.min, f.max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			lo, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s field: %s", f.name, s)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid %s field: %s", f.name, s)
+				}
+			} else if step > 1 {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%s field out of range: %s", f.name, s)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// dayMatches returns true if the day of t is allowed by the expression
+func (c *CronExpr) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time after t that matches the expression in the
+// location of t, or the zero time if there is none within five years. Like in
+// cron, times skipped by a daylight saving transition do not match, and times
+// repeated by one only match once
+func (c *CronExpr) Next(t time.Time) time.Time {
+	after := wallClock(t)
+	// Time zone offsets are whole minutes, so this is the next wall clock
+	// minute too
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = advance(t, time.Date(
+				t.Year(),
+				t.Month()+1,
+				1,
+				0, 0, 0, 0,
+				t.Location(),
+			))
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = advance(t, time.Date(
+				t.Year(),
+				t.Month(),
+				t.Day()+1,
+				0, 0, 0, 0,
+				t.Location(),
+			))
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = advance(t, time.Date(
+				t.Year(),
+				t.Month(),
+				t.Day(),
+				t.Hour()+1,
+				0, 0, 0,
+				t.Location(),
+			))
+			continue
+		}
+		// A wall clock time that is not after the one of the start is
+		// repeated by a daylight saving transition
+		if c.minute&(1<<uint(t.Minute())) == 0 ||
+			!wallClock(t).After(after) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// advance returns next, the start of the next month, day or hour after t. If
+// that wall clock time is skipped by a daylight saving transition, time.Date
+// returns a time before the transition that may not be after t, in which case
+// the minute after t is returned to continue minute by minute past the
+// transition
+func advance(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Minute)
+}
+
+// wallClock returns the wall clock time of t, which is comparable between
+// offsets of the same location
+func wallClock(t time.Time) time.Time {
+	return time.Date(
+		t.Year(),
+		t.Month(),
+		t.Day(),
+		t.Hour(),
+		t.Minute(),
+		t.Second(),
+		t.Nanosecond(),
+		time.UTC,
+	)
+}
@@ -0,0 +1,155 @@
+package schedules
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func location(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 0-6,18-23 1,15 */2 1-5"},
+		{expr: " 0 9 * * 7 "},
+		{expr: "@daily"},
+		{expr: "@hourly"},
+		{expr: "* * * *", wantErr: true},
+		{expr: "* * * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+		{expr: "@yearly", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	kolkata := location(t, "Asia/Kolkata")
+	kathmandu := location(t, "Asia/Kathmandu")
+	newYork := location(t, "America/New_York")
+	santiago := location(t, "America/Santiago")
+	at := func(loc *time.Location, y int, mo time.Month, d, h, m int) time.Time {
+		return time.Date(y, mo, d, h, m, 0, 0, loc)
+	}
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{
+			name:  "next minute of the step",
+			expr:  "*/15 * * * *",
+			after: at(time.UTC, 2026, 10, 19, 10, 7),
+			want:  at(time.UTC, 2026, 10, 19, 10, 15),
+		},
+		{
+			name:  "strictly after",
+			expr:  "0 10 * * *",
+			after: at(time.UTC, 2026, 10, 19, 10, 0),
+			want:  at(time.UTC, 2026, 10, 20, 10, 0),
+		},
+		{
+			name:  "next month",
+			expr:  "0 0 1 * *",
+			after: at(time.UTC, 2026, 1, 31, 12, 0),
+			want:  at(time.UTC, 2026, 2, 1, 0, 0),
+		},
+		{
+			name:  "either day field",
+			expr:  "0 0 13 * 5",
+			after: at(time.UTC, 2026, 10, 19, 0, 0),
+			want:  at(time.UTC, 2026, 10, 23, 0, 0),
+		},
+		{
+			name:  "sunday as seven",
+			expr:  "0 0 * * 7",
+			after: at(time.UTC, 2026, 10, 19, 0, 0),
+			want:  at(time.UTC, 2026, 10, 25, 0, 0),
+		},
+		{
+			name:  "half hour offset",
+			expr:  "0 9 * * *",
+			after: at(kolkata, 2026, 10, 19, 8, 10),
+			want:  at(kolkata, 2026, 10, 19, 9, 0),
+		},
+		{
+			name:  "quarter hour offset",
+			expr:  "0 * * * *",
+			after: at(kathmandu, 2026, 10, 19, 10, 20),
+			want:  at(kathmandu, 2026, 10, 19, 11, 0),
+		},
+		{
+			name:  "hour after the clocks spring forward",
+			expr:  "0 * * * *",
+			after: at(newYork, 2026, 3, 8, 1, 10),
+			want:  at(newYork, 2026, 3, 8, 3, 0),
+		},
+		{
+			name:  "skipped time does not match",
+			expr:  "30 2 * * *",
+			after: at(newYork, 2026, 3, 8, 1, 0),
+			want:  at(newYork, 2026, 3, 9, 2, 30),
+		},
+		{
+			name:  "repeated hour matches once",
+			expr:  "30 1 * * *",
+			after: at(newYork, 2026, 11, 1, 1, 30),
+			want:  at(newYork, 2026, 11, 2, 1, 30),
+		},
+		{
+			name:  "hour after the clocks fall back",
+			expr:  "0 * * * *",
+			after: at(newYork, 2026, 11, 1, 1, 0),
+			want:  at(newYork, 2026, 11, 1, 2, 0),
+		},
+		{
+			name:  "day after a skipped midnight",
+			expr:  "0 9 * * *",
+			after: at(santiago, 2026, 9, 5, 10, 0),
+			want:  at(santiago, 2026, 9, 6, 9, 0),
+		},
+		{
+			name:  "never",
+			expr:  "0 0 30 2 *",
+			after: at(time.UTC, 2026, 10, 19, 0, 0),
+			want:  time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := cron.Next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package schedules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/templates"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/testruns"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+)
+
+var logger = logging.NewLogger("schedules")
+
+// ErrNotFound is returned when a schedule does not exist
+var ErrNotFound = errors.New("schedule not found")
+
+// checkInterval is how often the schedule manager looks for due schedules
+const checkInterval = 15 * time.Second
+
+// CommitSelectorType determines which commit a schedule runs against
+type CommitSelectorType string
+
+const (
+	// CommitLatestMain selects the most recent commit on the main branch
+	CommitLatestMain CommitSelectorType = "latest-main"
+	// CommitFixed selects a fixed commit hash
+	CommitFixed CommitSelectorType = "fixed"
+	// CommitLatestPath selects the most recent commit on the main branch that
+	// changed a file or directory
+	CommitLatestPath CommitSelectorType = "latest-path"
+)
+
+// CommitSelector selects the commit a schedule's test runs are run against
+type CommitSelector struct {
+	Type CommitSelectorType `json:"type"`
+	// The commit hash, for the fixed selector
+	Hash string `json:"hash,omitempty"`
+	// The file or directory, for the latest-path selector
+	Path string `json:"path,omitempty"`
+}
+
+// Validate checks that the fields the selector's type needs are set
+func (c CommitSelector) Validate() error {
+	switch c.Type {
+	case CommitLatestMain:
+	case CommitFixed:
+		if c.Hash == "" {
+			return errors.New("the fixed commit selector requires a hash")
+		}
+	case CommitLatestPath:
+		if c.Path == "" {
+			return errors.New("the latest-path commit selector requires a path")
+		}
+	default:
+		return fmt.Errorf("unknown commit selector %q", c.Type)
+	}
+	return nil
+}
+
+// ScheduleConfig holds the user-defined settings of a schedule
+type ScheduleConfig struct {
+	Name string `json:"name"`
+	// A cron expression (minute hour day-of-month month day-of-week) in the
+	// coordinator's time zone
+	Cron string `json:"cron"`
+	// The template the test runs are created from, and its version (0 for
+	// the latest version at the time of firing)
+	TemplateID      string `json:"templateID"`
+	TemplateVersion int    `json:"templateVersion"`
+	// Test run fields, keyed by their JSON name, that replace the ones in
+	// the template
+	Overrides map[string]json.RawMessage `json:"overrides"`
+	Commit    CommitSelector             `json:"commit"`
+	// Do not fire if the selected commit is the same as at the last firing
+	SkipUnchangedCommit bool `json:"skipUnchangedCommit"`
+	Enabled             bool `json:"enabled"`
+}
+
+// Schedule periodically creates test runs from a template
+type Schedule struct {
+	ID string `json:"id"`
+	ScheduleConfig
+	OwnerThumbprint string    `json:"ownerThumbprint"`
+	Created         time.Time `json:"created"`
+	// The next time the schedule fires, zero if it is disabled
+	NextFire time.Time `json:"nextFire"`
+	// The outcome of the last firing
+	LastFired      time.Time `json:"lastFired"`
+	LastCommit     string    `json:"lastCommit"`
+	LastTestRunIDs []string  `json:"lastTestRunIDs"`
+	LastError      string    `json:"lastError"`
+}
+
+// updateNextFire computes when the schedule fires next, after the given time
+func (s *Schedule) updateNextFire(after time.Time) {
+	s.NextFire = time.Time{}
+	if !s.Enabled {
+		return
+	}
+	cron, err := ParseCron(s.Cron)
+	if err != nil {
+		return
+	}
+	s.NextFire = cron.Next(after)
+}
+
+// ScheduleManager holds the recurring schedules, persists them in the data
+// directory and creates the test runs when they fire
+type ScheduleManager struct {
+	path      string
+	schedules map[string]*Schedule
+	lock      sync.Mutex
+	tr        *testruns.TestRunManager
+	tm        *templates.TemplateManager
+	src       *sources.SourcesManager
+	// Serializes the firings of each schedule, guarded by lock
+	firing map[string]*sync.Mutex
+}
+
+// NewScheduleManager loads the persisted schedules. Firings that were missed
+// while the coordinator was not running are skipped
+func NewScheduleManager(
+	tr *testruns.TestRunManager,
+	tm *templates.TemplateManager,
+	src *sources.SourcesManager,
+) (*ScheduleManager, error) {
+	sm := &ScheduleManager{
+		path:      filepath.Join(common.DataDir(), "schedules.json"),
+		schedules: map[string]*Schedule{},
+		firing:    map[string]*sync.Mutex{},
+		tr:        tr,
+		tm:        tm,
+		src:       src,
+	}
+	b, err := ioutil.ReadFile(sm.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &sm.schedules)
+		if err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	for _, s := range sm.schedules {
+		s.updateNextFire(now)
+	}
+	logger.Infof("Loaded %d schedules", len(sm.schedules))
+	return sm, nil
+}
+
+// persist writes the schedules to disk. Must be called with the lock held
+func (sm *ScheduleManager) persist() error {
+	b, err := json.MarshalIndent(sm.schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(sm.path, b, 0644)
+}
+
+// validate checks the schedule's settings, including that the overrides can
+// be applied to the template
+func (sm *ScheduleManager) validate(cfg ScheduleConfig) error {
+	if cfg.Name == "" {
+		return errors.New("name is required")
+	}
+	_, err := ParseCron(cfg.Cron)
+	if err != nil {
+		return err
+	}
+	err = cfg.Commit.Validate()
+	if err != nil {
+		return err
+	}
+	spec, _, err := sm.tm.Spec(cfg.TemplateID, cfg.TemplateVersion)
+	if err != nil {
+		return fmt.Errorf("template %s: %v", cfg.TemplateID, err)
+	}
+	_, err = common.ApplyTestRunOverrides(spec, cfg.Overrides)
+	return err
+}
+
+// List returns all schedules, sorted by name
+func (sm *ScheduleManager) List() []*Schedule {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	ret := make([]*Schedule, 0, len(sm.schedules))
+	for _, s := range sm.schedules {
+		cp := *s
+		ret = append(ret, &cp)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Get returns the schedule with the given ID
+func (sm *ScheduleManager) Get(id string) (*Schedule, error) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	s, ok := sm.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+// Create adds a new schedule owned by the user with the given thumbprint
+func (sm *ScheduleManager) Create(
+	owner string,
+	cfg ScheduleConfig,
+) (*Schedule, error) {
+	err := sm.validate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	id, err := common.RandomID(12)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schedule{
+		ID:              id,
+		ScheduleConfig:  cfg,
+		OwnerThumbprint: owner,
+		Created:         time.Now(),
+	}
+	s.updateNextFire(time.Now())
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	sm.schedules[id] = s
+	err = sm.persist()
+	if err != nil {
+		delete(sm.schedules, id)
+		return nil, err
+	}
+	cp := *s
+	return &cp, nil
+}
+
+// Update replaces the settings of the schedule with the given ID
+func (sm *ScheduleManager) Update(
+	id string,
+	cfg ScheduleConfig,
+) (*Schedule, error) {
+	err := sm.validate(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	s, ok := sm.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	old := *s
+	s.ScheduleConfig = cfg
+	s.updateNextFire(time.Now())
+	err = sm.persist()
+	if err != nil {
+		*s = old
+		return nil, err
+	}
+	cp := *s
+	return &cp, nil
+}
+
+// Delete removes the schedule with the given ID. Test runs it created are
+// not affected
+func (sm *ScheduleManager) Delete(id string) error {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	s, ok := sm.schedules[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(sm.schedules, id)
+	delete(sm.firing, id)
+	err := sm.persist()
+	if err != nil {
+		sm.schedules[id] = s
+		return err
+	}
+	return nil
+}
+
+// Run is the main loop that fires the schedules when they are due. Nothing is
+// fired until the test run manager has loaded the existing test runs
+func (sm *ScheduleManager) Run() {
+	for {
+		if !sm.tr.TestRunsLoaded() {
+			time.Sleep(checkInterval)
+			continue
+		}
+		now := time.Now()
+		due := []string{}
+		sm.lock.Lock()
+		for id, s := range sm.schedules {
+			if s.Enabled && !s.NextFire.IsZero() && !s.NextFire.After(now) {
+				due = append(due, id)
+			}
+		}
+		sm.lock.Unlock()
+
+		for _, id := range due {
+			_, err := sm.fire(id, true)
+			if err != nil {
+				logger.Warnf("Schedule %s failed to fire: %v", id, err)
+			}
+		}
+		time.Sleep(checkInterval)
+	}
+}
+
+// Fire creates the test runs for the schedule with the given ID right away,
+// and returns the schedule with the outcome recorded. An error is returned
+// if the schedule does not exist or no test run could be created
+func (sm *ScheduleManager) Fire(id string) (*Schedule, error) {
+	return sm.fire(id, false)
+}
+
+// fireLock returns the lock that serializes the firings of the schedule
+func (sm *ScheduleManager) fireLock(id string) *sync.Mutex {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	l, ok := sm.firing[id]
+	if !ok {
+		l = &sync.Mutex{}
+		sm.firing[id] = l
+	}
+	return l
+}
+
+// fire creates the test runs for the schedule and records the outcome. With
+// onlyDue set the schedule is skipped if it is no longer due, which happens
+// when it was fired manually while waiting for the lock
+func (sm *ScheduleManager) fire(id string, onlyDue bool) (*Schedule, error) {
+	fl := sm.fireLock(id)
+	fl.Lock()
+	defer fl.Unlock()
+
+	s, err := sm.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if onlyDue && (s.NextFire.IsZero() || s.NextFire.After(time.Now())) {
+		return s, nil
+	}
+
+	commit, ids, fireErr := sm.createTestRuns(s)
+	if fireErr == nil && ids == nil {
+		logger.Infof(
+			"Schedule %s (%s) skipped, commit %s is unchanged",
+			s.ID,
+			s.Name,
+			commit,
+		)
+	} else if fireErr == nil {
+		logger.Infof(
+			"Schedule %s (%s) scheduled %v for commit %s",
+			s.ID,
+			s.Name,
+			ids,
+			commit,
+		)
+	}
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	cur, ok := sm.schedules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	now := time.Now()
+	cur.LastFired = now
+	cur.LastError = ""
+	if fireErr != nil {
+		cur.LastError = fireErr.Error()
+	} else if ids != nil {
+		cur.LastCommit = commit
+		cur.LastTestRunIDs = ids
+	}
+	cur.updateNextFire(now)
+	err = sm.persist()
+	if err != nil {
+		logger.Warnf("Unable to persist schedules: %v", err)
+	}
+	cp := *cur
+	return &cp, fireErr
+}
+
+// createTestRuns schedules the test runs for a firing of the schedule, and
+// returns the commit they run against and their IDs. The IDs are nil if the
+// firing was skipped because the commit did not change
+func (sm *ScheduleManager) createTestRuns(
+	s *Schedule,
+) (string, []string, error) {
+	commit, err := sm.selectCommit(s.Commit)
+	if err != nil {
+		return "", nil, err
+	}
+	if s.SkipUnchangedCommit && commit == s.LastCommit {
+		return commit, nil, nil
+	}
+
+	spec, version, err := sm.tm.Spec(s.TemplateID, s.TemplateVersion)
+	if err != nil {
+		return "", nil, fmt.Errorf("template %s: %v", s.TemplateID, err)
+	}
+	tr, err := common.ApplyTestRunOverrides(spec, s.Overrides)
+	if err != nil {
+		return "", nil, err
+	}
+	tr.CommitHash = commit
+	tr.TemplateID = s.TemplateID
+	tr.TemplateVersion = version
+	tr.ScheduleID = s.ID
+
+	_, ids, err := sm.tr.ScheduleTestRunSpec(tr, s.OwnerThumbprint)
+	if err != nil {
+		return "", nil, err
+	}
+	return commit, ids, nil
+}
+
+// selectCommit resolves the commit selector to a commit hash
+func (sm *ScheduleManager) selectCommit(c CommitSelector) (string, error) {
+	switch c.Type {
+	case CommitFixed:
+		return c.Hash, nil
+	case CommitLatestPath:
+		return sm.src.LatestCommit(c.Path)
+	}
+	return sm.src.LatestCommit("")
+}
@@ -16,9 +16,12 @@ import (
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
 	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+var logger = logging.NewLogger("sources")
+
 var ErrGitLogOutOfBounds = errors.New("Requested out-of-bounds git log")
 
 type GitLogRecord struct {
@@ -41,10 +44,15 @@ type GitLogPerson struct {
 type SourcesManager struct {
 	gitLog      []GitLogRecord
 	sourcesLock sync.Mutex
+	cfg         *config.Config
 }
 
-func NewSourcesManager() *SourcesManager {
-	s := &SourcesManager{gitLog: []GitLogRecord{}, sourcesLock: sync.Mutex{}}
+func NewSourcesManager(cfg *config.Config) *SourcesManager {
+	s := &SourcesManager{
+		gitLog:      []GitLogRecord{},
+		sourcesLock: sync.Mutex{},
+		cfg:         cfg,
+	}
 	return s
 }
 
@@ -155,7 +163,7 @@ func (s *SourcesManager) Compile(
 	if err != nil {
 		return err
 	}
-	logging.Infof(
+	logger.Infof(
 		"[Compile %s-%t]: Checkout complete",
 		hash,
 		profilingOrDebugging,
@@ -178,7 +186,7 @@ func (s *SourcesManager) Compile(
 	if err != nil {
 		return err
 	}
-	logging.Infof(
+	logger.Infof(
 		"[Compile %s-%t]: Update submodules complete",
 		hash,
 		profilingOrDebugging,
@@ -189,7 +197,7 @@ func (s *SourcesManager) Compile(
 	}
 
 	os.RemoveAll(filepath.Join(sourcesDir(), "build"))
-	logging.Infof(
+	logger.Infof(
 		"[Compile %s-%t]: Cleaned build directory",
 		hash,
 		profilingOrDebugging,
@@ -216,7 +224,7 @@ func (s *SourcesManager) Compile(
 				avoid_legacy_setup = false
 				return fmt.Errorf("Build-environment setup failed: %v\n\n%v", err, string(out))
 			} else {
-				logging.Infof(
+				logger.Infof(
 					"[Compile %s-%t]: Build-environment setup complete",
 					hash,
 					profilingOrDebugging,
@@ -244,7 +252,7 @@ func (s *SourcesManager) Compile(
 				avoid_legacy_setup = false
 				return fmt.Errorf("Dependency installation failed: %v\n\n%v", err, string(out))
 			} else {
-				logging.Infof(
+				logger.Infof(
 					"[Compile %s-%t]: Dependency installation complete",
 					hash,
 					profilingOrDebugging,
@@ -256,7 +264,7 @@ func (s *SourcesManager) Compile(
 	}
 
 	if !avoid_legacy_setup {
-		logging.Infof(
+		logger.Infof(
 			"[Compile %s-%t]: Attempting to use legacy configuration",
 			hash,
 			profilingOrDebugging,
@@ -279,7 +287,7 @@ func (s *SourcesManager) Compile(
 		if err != nil {
 			return fmt.Errorf("Legacy configuration failed: %v\n\n%v", err, string(out))
 		} else {
-			logging.Infof(
+			logger.Infof(
 				"[Compile %s-%t]: Legacy configuration complete",
 				hash,
 				profilingOrDebugging,
@@ -308,7 +316,7 @@ func (s *SourcesManager) Compile(
 		return fmt.Errorf("Build failed: %v\n\n%v", err, string(out))
 	}
 
-	logging.Infof(
+	logger.Infof(
 		"[Compile %s-%t]: Build script complete",
 		hash,
 		profilingOrDebugging,
@@ -327,7 +335,7 @@ func (s *SourcesManager) Compile(
 		"rpc_proxy",
 	)
 	if _, err := os.Stat(proxy_path); !os.IsNotExist(err) {
-		logging.Infof(
+		logger.Infof(
 			"[Compile %s-%t]: Copying 3PC/EVM RPC proxy",
 			hash,
 			profilingOrDebugging,
@@ -418,7 +426,7 @@ func (s *SourcesManager) updateCommitHistory() error {
 			string(out),
 		)
 	}
-	logging.Infof("ls-remote:\n\n%s", string(out))
+	logger.Infof("ls-remote:\n\n%s", string(out))
 	prs := map[int]bool{}
 	prHeadCommits := map[int]string{}
 	lines := strings.Split(string(out), "\n")
@@ -432,7 +440,7 @@ func (s *SourcesManager) updateCommitHistory() error {
 				if err == nil {
 					if strings.HasSuffix(parts[1], "/merge") {
 						prs[pr] = true
-						logging.Infof(
+						logger.Infof(
 							"Detected (at one point) mergeable PR #%d",
 							pr,
 						)
@@ -458,7 +466,7 @@ func (s *SourcesManager) updateCommitHistory() error {
 		cmd.Dir = sourcesDir()
 		out, err = cmd.CombinedOutput()
 		if err != nil {
-			logging.Warnf("git log for PR %d failed: %v", pr, err)
+			logger.Warnf("git log for PR %d failed: %v", pr, err)
 			continue
 		}
 		outString := strings.ReplaceAll(string(out), "\"", "\\\"")
@@ -467,7 +475,7 @@ func (s *SourcesManager) updateCommitHistory() error {
 		var prData PRData
 		err = json.Unmarshal(out, &prData)
 		if err != nil {
-			logging.Warnf(
+			logger.Warnf(
 				"Unmarshal JSON from log for PR %d failed: %v",
 				pr,
 				err,
@@ -492,7 +500,7 @@ func (s *SourcesManager) updateCommitHistory() error {
 				})
 			}
 		} else {
-			logging.Warnf("Authored date for PR %d could not be parsed: %v", pr, err)
+			logger.Warnf("Authored date for PR %d could not be parsed: %v", pr, err)
 		}
 	}
 
@@ -551,14 +559,14 @@ func (s *SourcesManager) FindMostRecentCommitChangingSeeder(
 	cmd = exec.Command(
 		"git",
 		"checkout",
-		os.Getenv("TRANSACTION_PROCESSOR_MAIN_BRANCH"),
+		s.cfg.Sources.MainBranch,
 	)
 	cmd.Dir = sourcesDir()
 	out, err = cmd.CombinedOutput()
 	if err != nil {
 		return "", fmt.Errorf(
 			"Failed to find seeder change commit - [git checkout %s] failed: %v\n\n%s",
-			os.Getenv("TRANSACTION_PROCESSOR_MAIN_BRANCH"),
+			s.cfg.Sources.MainBranch,
 			err,
 			string(out),
 		)
@@ -566,17 +574,53 @@ func (s *SourcesManager) FindMostRecentCommitChangingSeeder(
 	return commitHash, err
 }
 
+// LatestCommit returns the hash of the most recent commit on the main branch.
+// If path is not empty, it returns the most recent commit on the main branch
+// that changed the file or directory at path instead
+func (s *SourcesManager) LatestCommit(path string) (string, error) {
+	s.sourcesLock.Lock()
+	defer s.sourcesLock.Unlock()
+	args := []string{
+		"log",
+		"-1",
+		"--pretty=format:%H",
+		fmt.Sprintf("origin/%s", s.cfg.Sources.MainBranch),
+	}
+	if path != "" {
+		args = append(args, "--", path)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = sourcesDir()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf(
+			"failed to find latest commit - failed to execute git log: %v\n\n%s",
+			err,
+			string(out),
+		)
+	}
+	hash := strings.TrimSpace(string(out))
+	if hash == "" {
+		return "", fmt.Errorf(
+			"no commit on %s changes %s",
+			s.cfg.Sources.MainBranch,
+			path,
+		)
+	}
+	return hash, nil
+}
+
 func (s *SourcesManager) cloneSources() error {
 	s.sourcesLock.Lock()
 	defer s.sourcesLock.Unlock()
 
-	gitUrl, err := url.Parse(os.Getenv("TRANSACTION_PROCESSOR_REPO_URL"))
+	gitUrl, err := url.Parse(s.cfg.Sources.RepoURL)
 	if err != nil {
 		return err
 	}
-	if os.Getenv("TRANSACTION_PROCESSOR_ACCESS_TOKEN") != "" {
+	if s.cfg.Sources.AccessToken != "" {
 		gitUrl.User = url.UserPassword(
-			os.Getenv("TRANSACTION_PROCESSOR_ACCESS_TOKEN"),
+			s.cfg.Sources.AccessToken,
 			"x-oauth-basic",
 		)
 	}
@@ -618,19 +662,19 @@ func (s *SourcesManager) updateSources() error {
 	cmd := exec.Command(
 		"git",
 		"checkout",
-		os.Getenv("TRANSACTION_PROCESSOR_MAIN_BRANCH"),
+		s.cfg.Sources.MainBranch,
 	)
 	cmd.Dir = sourcesDir()
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		logging.Errorf("Error on git checkout: %v", string(out))
+		logger.Errorf("Error on git checkout: %v", string(out))
 		return err
 	}
 	cmd = exec.Command("git", "pull")
 	cmd.Dir = sourcesDir()
 	out, err = cmd.CombinedOutput()
 	if err != nil {
-		logging.Errorf("Error on git pull: %v", string(out))
+		logger.Errorf("Error on git pull: %v", string(out))
 		return err
 	}
 	return nil
@@ -659,6 +703,17 @@ func (s *SourcesManager) GetGitLog(
 	return ret, nil
 }
 
+// CommitTime returns the time the commit with the given hash was committed,
+// and false if the commit is not in the commit history of the main branch
+func (s *SourcesManager) CommitTime(hash string) (time.Time, bool) {
+	for _, c := range s.gitLog {
+		if c.CommitHash == hash {
+			return c.Committed, true
+		}
+	}
+	return time.Time{}, false
+}
+
 func (s *SourcesManager) CommitExists(hash string) bool {
 	for _, c := range s.gitLog {
 		if c.CommitHash == hash {
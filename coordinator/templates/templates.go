@@ -0,0 +1,300 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/logging"
+)
+
+var logger = logging.NewLogger("templates")
+
+// ErrNotFound is returned when a template or template version does not exist
+var ErrNotFound = errors.New("template not found")
+
+// Template is a named test run specification that can be scheduled
+// repeatedly. Every change to a template is stored as a new version, such
+// that test runs scheduled from an earlier version can be traced back to the
+// exact specification they were created from
+type Template struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	OwnerThumbprint string `json:"ownerThumbprint"`
+	// Shared templates are visible to all users, others only to their owner
+	Shared   bool               `json:"shared"`
+	Created  time.Time          `json:"created"`
+	Versions []*TemplateVersion `json:"versions"`
+}
+
+// TemplateVersion is a single version of a template's test run specification
+type TemplateVersion struct {
+	Version             int             `json:"version"`
+	Created             time.Time       `json:"created"`
+	CreatedByThumbprint string          `json:"createdByThumbprint"`
+	Spec                *common.TestRun `json:"spec"`
+}
+
+// Latest returns the most recent version of the template
+func (t *Template) Latest() *TemplateVersion {
+	return t.Versions[len(t.Versions)-1]
+}
+
+// Version returns the given version of the template. Version 0 is the latest
+func (t *Template) Version(version int) (*TemplateVersion, error) {
+	if version == 0 {
+		return t.Latest(), nil
+	}
+	for _, v := range t.Versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Summary returns a copy of the template that only contains its latest
+// version, for use in listings
+func (t *Template) Summary() *Template {
+	cp := *t
+	cp.Versions = []*TemplateVersion{t.Latest()}
+	return &cp
+}
+
+// TemplateManager holds the test run templates and persists them in the
+// data directory, one JSON file per template
+type TemplateManager struct {
+	dir       string
+	templates map[string]*Template
+	lock      sync.RWMutex
+}
+
+// NewTemplateManager loads the persisted templates
+func NewTemplateManager() (*TemplateManager, error) {
+	tm := &TemplateManager{
+		dir:       filepath.Join(common.DataDir(), "templates"),
+		templates: map[string]*Template{},
+	}
+	err := os.MkdirAll(tm.dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(tm.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(tm.dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var t Template
+		err = json.Unmarshal(b, &t)
+		if err != nil {
+			logger.Warnf("Unable to parse template %s: %v", f.Name(), err)
+			continue
+		}
+		if len(t.Versions) == 0 {
+			logger.Warnf("Template %s has no versions", f.Name())
+			continue
+		}
+		tm.templates[t.ID] = &t
+	}
+	logger.Infof("Loaded %d test run templates", len(tm.templates))
+	return tm, nil
+}
+
+// persist writes the template to disk. Must be called with the lock held
+func (tm *TemplateManager) persist(t *Template) error {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(
+		filepath.Join(tm.dir, fmt.Sprintf("%s.json", t.ID)),
+		b,
+		0644,
+	)
+}
+
+// List returns the summaries of all templates, sorted by name
+func (tm *TemplateManager) List() []*Template {
+	tm.lock.RLock()
+	defer tm.lock.RUnlock()
+	ret := make([]*Template, 0, len(tm.templates))
+	for _, t := range tm.templates {
+		ret = append(ret, t.Summary())
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Get returns the template with the given ID, including all of its versions
+func (tm *TemplateManager) Get(id string) (*Template, error) {
+	tm.lock.RLock()
+	defer tm.lock.RUnlock()
+	t, ok := tm.templates[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *t
+	cp.Versions = append([]*TemplateVersion{}, t.Versions...)
+	return &cp, nil
+}
+
+// Spec returns a copy of the test run specification of the given version of
+// a template (0 for the latest), along with the version number
+func (tm *TemplateManager) Spec(
+	id string,
+	version int,
+) (*common.TestRun, int, error) {
+	t, err := tm.Get(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	v, err := t.Version(version)
+	if err != nil {
+		return nil, 0, err
+	}
+	spec, err := common.TestRunSpec(v.Spec)
+	if err != nil {
+		return nil, 0, err
+	}
+	return spec, v.Version, nil
+}
+
+// Create stores a new template with the given specification as its first
+// version
+func (tm *TemplateManager) Create(
+	name, description, owner string,
+	shared bool,
+	spec *common.TestRun,
+) (*Template, error) {
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	spec, err := common.TestRunSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	id, err := common.RandomID(12)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	t := &Template{
+		ID:              id,
+		Name:            name,
+		Description:     description,
+		OwnerThumbprint: owner,
+		Shared:          shared,
+		Created:         now,
+		Versions: []*TemplateVersion{
+			{
+				Version:             1,
+				Created:             now,
+				CreatedByThumbprint: owner,
+				Spec:                spec,
+			},
+		},
+	}
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	err = tm.persist(t)
+	if err != nil {
+		return nil, err
+	}
+	tm.templates[id] = t
+	return t, nil
+}
+
+// TemplateUpdate holds the changes to a template. Nil fields are left as
+// they are. A new version is created only if Spec is set
+type TemplateUpdate struct {
+	Name        *string         `json:"name"`
+	Description *string         `json:"description"`
+	Shared      *bool           `json:"shared"`
+	Spec        *common.TestRun `json:"spec"`
+}
+
+// Update applies the changes to the template with the given ID on behalf of
+// the user with the given thumbprint
+func (tm *TemplateManager) Update(
+	id, by string,
+	upd TemplateUpdate,
+) (*Template, error) {
+	var spec *common.TestRun
+	if upd.Spec != nil {
+		var err error
+		spec, err = common.TestRunSpec(upd.Spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if upd.Name != nil && *upd.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	t, ok := tm.templates[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *t
+	cp.Versions = append([]*TemplateVersion{}, t.Versions...)
+	if upd.Name != nil {
+		cp.Name = *upd.Name
+	}
+	if upd.Description != nil {
+		cp.Description = *upd.Description
+	}
+	if upd.Shared != nil {
+		cp.Shared = *upd.Shared
+	}
+	if spec != nil {
+		cp.Versions = append(cp.Versions, &TemplateVersion{
+			Version:             t.Latest().Version + 1,
+			Created:             time.Now(),
+			CreatedByThumbprint: by,
+			Spec:                spec,
+		})
+	}
+	err := tm.persist(&cp)
+	if err != nil {
+		return nil, err
+	}
+	tm.templates[id] = &cp
+	return &cp, nil
+}
+
+// Delete removes the template with the given ID and all of its versions
+func (tm *TemplateManager) Delete(id string) error {
+	tm.lock.Lock()
+	defer tm.lock.Unlock()
+	if _, ok := tm.templates[id]; !ok {
+		return ErrNotFound
+	}
+	err := os.Remove(filepath.Join(tm.dir, fmt.Sprintf("%s.json", id)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(tm.templates, id)
+	return nil
+}
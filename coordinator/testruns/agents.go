@@ -14,9 +14,7 @@ func (t *TestRunManager) SnapshotAgents(tr *common.TestRun) {
 				SystemInfo:   a.SystemInfo,
 				AgentVersion: a.AgentVersion,
 				PingRTT:      a.PingRTT,
-				AwsRegion: t.awsm.GetLaunchTemplateRegion(
-					role.AwsLaunchTemplateID,
-				),
+				AwsRegion:    t.templateRegion(role.AwsLaunchTemplateID),
 			})
 		}
 	}
@@ -481,43 +481,15 @@ func (t *TestRunManager) ValidateTestRunTwoPhase(
 	sentinels := t.GetAllRolesSorted(tr, common.SystemRoleSentinelTwoPhase)
 	loadgens := t.GetAllRolesSorted(tr, common.SystemRoleTwoPhaseGen)
 
-	if len(coordinators) < 1 {
-		errs = append(
-			errs,
-			errors.New("the system needs at least 1 coordinator"),
-		)
-	}
-
-	if len(shards) < 1 {
-		errs = append(errs, errors.New("the system needs at least 1 shard"))
-	}
-
-	if len(sentinels) < 1 {
-		errs = append(errs, errors.New("the system needs at least 1 sentinel"))
-	}
-
-	if len(loadgens) < 1 {
-		errs = append(
-			errs,
-			errors.New("the system needs at least 1 load generator"),
-		)
-	}
-
-	if len(shards)%tr.ShardReplicationFactor != 0 {
-		errs = append(errs, fmt.Errorf(
-			"number of shards [%d] should be a multiple of replication factor [%d]",
-			len(shards),
-			tr.ShardReplicationFactor,
-		))
-	}
-
-	if len(coordinators)%tr.ShardReplicationFactor != 0 {
-		errs = append(errs, fmt.Errorf(
-			"number of coordinators [%d] should be a multiple of replication factor [%d]",
-			len(coordinators),
-			tr.ShardReplicationFactor,
-		))
-	}
+	errs = append(errs, minRoles(coordinators, 1, "coordinator")...)
+	errs = append(errs, minRoles(shards, 1, "shard")...)
+	errs = append(errs, minRoles(sentinels, 1, "sentinel")...)
+	errs = append(errs, minRoles(loadgens, 1, "load generator")...)
+	errs = append(errs, replicationMultiple(tr, shards, "shards")...)
+	errs = append(
+		errs,
+		replicationMultiple(tr, coordinators, "coordinators")...,
+	)
 
 	return errs
 }
@@ -326,43 +326,13 @@ func (t *TestRunManager) ValidateTestRunAtomizer(
 	loadgens := t.GetAllRolesSorted(tr, common.SystemRoleAtomizerCliWatchtower)
 	atomizers := t.GetAllRolesSorted(tr, common.SystemRoleRaftAtomizer)
 
-	if len(archivers) < 1 {
-		errs = append(errs, errors.New("the system needs at least 1 archiver"))
-	}
-
-	if len(shards) < 1 {
-		errs = append(errs, errors.New("the system needs at least 1 shard"))
-	}
-
-	if len(sentinels) < 1 {
-		errs = append(errs, errors.New("the system needs at least 1 sentinel"))
-	}
-
-	if len(watchtowers) < 1 {
-		errs = append(
-			errs,
-			errors.New("the system needs at least 1 watchtower"),
-		)
-	}
-
-	if len(loadgens) < 1 {
-		errs = append(
-			errs,
-			errors.New("the system needs at least 1 load generator"),
-		)
-	}
-
-	if len(atomizers) < 1 {
-		errs = append(errs, errors.New("the system needs at least 1 atomizer"))
-	}
-
-	if len(shards)%tr.ShardReplicationFactor != 0 {
-		errs = append(errs, fmt.Errorf(
-			"number of shards [%d] should be a multiple of replication factor [%d]",
-			len(shards),
-			tr.ShardReplicationFactor,
-		))
-	}
+	errs = append(errs, minRoles(archivers, 1, "archiver")...)
+	errs = append(errs, minRoles(shards, 1, "shard")...)
+	errs = append(errs, minRoles(sentinels, 1, "sentinel")...)
+	errs = append(errs, minRoles(watchtowers, 1, "watchtower")...)
+	errs = append(errs, minRoles(loadgens, 1, "load generator")...)
+	errs = append(errs, minRoles(atomizers, 1, "atomizer")...)
+	errs = append(errs, replicationMultiple(tr, shards, "shards")...)
 
 	return errs
 }
@@ -243,7 +243,10 @@ func (t *TestRunManager) CreateStartSequencePhaseTwo(
 	return startSequence
 }
 
-func (t *TestRunManager) GenerateParams(tr *common.TestRun) ([]string, error) {
+func (t *TestRunManager) GenerateParams(
+	tr *common.TestRun,
+	dummy bool,
+) ([]string, error) {
 	ret := make([]string, 0)
 
 	ticket_machines := t.GetAllRolesSorted(tr, common.SystemRoleTicketMachine)
@@ -257,8 +260,9 @@ func (t *TestRunManager) GenerateParams(tr *common.TestRun) ([]string, error) {
 	)
 
 	for i, s := range ticket_machines {
-		a, err := t.coord.GetAgent(
+		a, err := t.GetAgentOrDummy(
 			s.AgentID,
+			dummy,
 		)
 		if err != nil {
 			return nil, err
@@ -298,8 +302,9 @@ func (t *TestRunManager) GenerateParams(tr *common.TestRun) ([]string, error) {
 		for j := 0; j < tr.ShardReplicationFactor; j++ {
 			s := shards[(i*tr.ShardReplicationFactor)+j]
 
-			a, err := t.coord.GetAgent(
+			a, err := t.GetAgentOrDummy(
 				s.AgentID,
+				dummy,
 			)
 			if err != nil {
 				return nil, err
@@ -324,8 +329,9 @@ func (t *TestRunManager) GenerateParams(tr *common.TestRun) ([]string, error) {
 	ret = append(ret, fmt.Sprintf("--agent_count=%d", len(agents)))
 
 	for i, s := range agents {
-		a, err := t.coord.GetAgent(
+		a, err := t.GetAgentOrDummy(
 			s.AgentID,
+			dummy,
 		)
 		if err != nil {
 			return nil, err
@@ -360,3 +366,22 @@ func (t *TestRunManager) GenerateParams(tr *common.TestRun) ([]string, error) {
 
 	return ret, nil
 }
+
+// ValidateTestRunPhaseTwo validates the role composition of the test run for
+// a phase two system. Reports all errors back as an array
+func (t *TestRunManager) ValidateTestRunPhaseTwo(
+	tr *common.TestRun,
+) []error {
+	errs := make([]error, 0)
+
+	ticketMachines := t.GetAllRolesSorted(tr, common.SystemRoleTicketMachine)
+	shards := t.GetAllRolesSorted(tr, common.SystemRoleRuntimeLockingShard)
+	agents := t.GetAllRolesSorted(tr, common.SystemRoleAgent)
+
+	errs = append(errs, minRoles(ticketMachines, 1, "ticket machine")...)
+	errs = append(errs, minRoles(shards, 1, "shard")...)
+	errs = append(errs, minRoles(agents, 1, "agent")...)
+	errs = append(errs, replicationMultiple(tr, shards, "shards")...)
+
+	return errs
+}
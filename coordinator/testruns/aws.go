@@ -6,7 +6,7 @@ import (
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
 )
 
 // KillAwsAgents will terminate all running EC2 instances for the specified
@@ -36,14 +36,14 @@ func (t *TestRunManager) KillAwsAgents(tr *common.TestRun) error {
 		}
 	}
 	t.WriteLog(tr, "Killing %d agents", len(ids))
-	return t.awsm.StopAgentsByInstanceIds(ids)
+	return t.prov.Terminate(ids)
 }
 
 // HasAWSRoles will return true if the test run has roles that (are supposed to)
-// run on AWS EC2
+// run on machines of the provider, such as AWS EC2 instances
 func (t *TestRunManager) HasAWSRoles(tr *common.TestRun) bool {
 	for _, r := range tr.Roles {
-		if r.AwsLaunchTemplateID != "" {
+		if needsMachine(r) {
 			return true
 		}
 	}
@@ -51,6 +51,30 @@ func (t *TestRunManager) HasAWSRoles(tr *common.TestRun) bool {
 	return false
 }
 
+// needsMachine returns true if the role needs a machine from the provider,
+// and false if it runs on an agent that was chosen when scheduling the test
+// run
+func needsMachine(r *common.TestRunRole) bool {
+	return r.AwsLaunchTemplateID != "" || len(r.RequiredLabels) > 0
+}
+
+// pendingMachineRequests returns the machines the test run needs from the
+// provider that have not been launched yet
+func (t *TestRunManager) pendingMachineRequests(
+	tr *common.TestRun,
+) []provider.MachineRequest {
+	pending := []int{}
+	for i, r := range tr.Roles {
+		if r.AgentID != -1 || r.AwsAgentInstanceId != "" {
+			continue
+		}
+		if needsMachine(r) {
+			pending = append(pending, i)
+		}
+	}
+	return t.machineRequests(tr, pending)
+}
+
 // RetrySpawn is used to manually initiate respawning of the AWS roles that are
 // not online yet
 func (t *TestRunManager) RetrySpawn(id string) {
@@ -72,7 +96,6 @@ func (t *TestRunManager) SpawnAWSInstances(tr *common.TestRun) bool {
 	killInstances := []string{}
 
 	spawnIndexes := []int{}
-	spawnInstances := []string{}
 
 	// First, idenfity all test run roles that have no agent ID assigned
 	// (meaning they are not connected to the controller yet), but do have an
@@ -100,18 +123,21 @@ func (t *TestRunManager) SpawnAWSInstances(tr *common.TestRun) bool {
 			}
 
 			// This is an agent that we still need (either first or retrying
-			// attempt). Append to the array(s) of instances to spawn
+			// attempt). Append to the array of roles to spawn
+			if !needsMachine(r) {
+				continue
+			}
 			spawnIndexes = append(spawnIndexes, i)
-			spawnInstances = append(
-				spawnInstances,
-				tr.Roles[i].AwsLaunchTemplateID,
-			)
 		}
 	}
 
+	// Request the machines with the placement constraints of their roles,
+	// such that providers that choose between machines can meet them
+	spawnInstances := t.machineRequests(tr, spawnIndexes)
+
 	// If we need to kill instances we are retrying, do so now.
 	if len(killInstances) > 0 {
-		err := t.awsm.StopAgentsByInstanceIds(killInstances)
+		err := t.prov.Terminate(killInstances)
 		if err != nil {
 			t.WriteLog(
 				tr,
@@ -122,10 +148,10 @@ func (t *TestRunManager) SpawnAWSInstances(tr *common.TestRun) bool {
 		}
 	}
 
-	// Call StartNewAgents on the AWS manager to do the actual API calls to AWS
-	// EC2 and boot up the compute instances. We get a list of instances back in
-	// the same order as we requested them
-	instances, errs := t.awsm.StartNewAgents(spawnInstances, tr.ID)
+	// Call Launch on the provider to boot up the machines, which for AWS does
+	// the actual API calls to EC2. We get a list of machines back in the same
+	// order as we requested them
+	instances, errs := t.prov.Launch(spawnInstances, tr.ID)
 	if len(errs) > 0 {
 		// If something went wrong during spawning the roles, abort the test run
 		jointErr := ""
@@ -137,10 +163,10 @@ func (t *TestRunManager) SpawnAWSInstances(tr *common.TestRun) bool {
 		// Find any non-nil instance in the return array, which are roles that
 		// were already spawned when the error occurred. We need to stop them
 		// because we won't be using them for the test.
-		nonNilInstances := []*awsmgr.AwsInstance{}
+		nonNilInstances := []string{}
 		for _, i := range instances {
 			if i != nil {
-				nonNilInstances = append(nonNilInstances, i)
+				nonNilInstances = append(nonNilInstances, i.ID)
 			}
 		}
 		if len(nonNilInstances) > 0 {
@@ -149,9 +175,9 @@ func (t *TestRunManager) SpawnAWSInstances(tr *common.TestRun) bool {
 				"Stopping %d already spawned instances",
 				len(nonNilInstances),
 			)
-			err := t.awsm.StopAgents(nonNilInstances)
+			err := t.prov.Terminate(nonNilInstances)
 			if err != nil {
-				t.WriteLog(tr, "Error stopping instances: %v", err)
+				t.WriteLogError(tr, "Error stopping instances: %v", err)
 			}
 		}
 		return false
@@ -163,7 +189,7 @@ func (t *TestRunManager) SpawnAWSInstances(tr *common.TestRun) bool {
 	// easily. We use the AwsAgentInstanceId to monitor the progress of the
 	// spawned agents connecting to the controller
 	for i, idx := range spawnIndexes {
-		tr.Roles[idx].AwsAgentInstanceId = *instances[i].Instance.InstanceId
+		tr.Roles[idx].AwsAgentInstanceId = instances[i].ID
 	}
 
 	return true
@@ -189,21 +215,22 @@ func (t *TestRunManager) WaitForAWSInstances(
 		}
 
 		// Get all the agent data from the coordinator - this is information
-		// from all connected agents. The agent will send the coordinator its
-		// AWS instance ID as part of the system information that gets sent on
-		// first connection. The startup script for the agent determines the EC2
-		// Instance ID and passes it as environment variable to the agent
-		// binary, that will then send it to the controller. We can use this to
-		// match the connecting agent against the role that's waiting for the
-		// given instance ID because we have assigned the instance ID to the
-		// role metadata. If we find a match, we assign the agent ID to the
-		// role, such that we know which agent to instruct to run that
-		// particular role.
+		// from all connected agents. The agent will send the coordinator the
+		// ID of its machine as part of the system information that gets sent
+		// on first connection. For AWS, the startup script for the agent
+		// determines the EC2 Instance ID and passes it as environment variable
+		// to the agent binary, that will then send it to the controller. We
+		// can use this to match the connecting agent against the role that's
+		// waiting for the given machine ID because we have assigned the
+		// machine ID to the role metadata. If we find a match, we assign the
+		// agent ID to the role, such that we know which agent to instruct to
+		// run that particular role.
 		for _, a := range t.coord.GetAgents() {
-			if a.SystemInfo.AWS {
+			machineID := t.prov.MachineID(a.SystemInfo)
+			if machineID != "" {
 				for i, r := range tr.Roles {
 					if r.AgentID == -1 &&
-						r.AwsAgentInstanceId == a.SystemInfo.EC2InstanceID {
+						r.AwsAgentInstanceId == machineID {
 						tr.Roles[i].AgentID = a.ID
 					}
 				}
@@ -294,3 +321,19 @@ func (t *TestRunManager) WaitForAWSInstances(
 		}
 	}
 }
+
+// MachineTemplates returns the templates the provider can launch the machines
+// of roles from
+func (t *TestRunManager) MachineTemplates() []provider.MachineTemplate {
+	return t.prov.Templates()
+}
+
+// templateRegion returns the region of a machine template, or "unknown" if the
+// provider does not have the template
+func (t *TestRunManager) templateRegion(templateID string) string {
+	lt, err := t.prov.Template(templateID)
+	if err != nil {
+		return "unknown"
+	}
+	return lt.Region
+}
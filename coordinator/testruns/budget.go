@@ -0,0 +1,291 @@
+package testruns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+)
+
+// budgetRefreshInterval is how often the scheduler recalculates the spending
+// from the recorded instance usage
+const budgetRefreshInterval = 30 * time.Second
+
+// BudgetConfig limits the spending on instances per calendar month (UTC),
+// both in total and per user (identified by the thumbprint of the test run's
+// creator). Limits are in USD, 0 means no limit
+type BudgetConfig struct {
+	MonthlyLimit float64 `json:"monthlyLimit"`
+	// The limit for users that are not listed in UserLimits
+	UserMonthlyLimit float64            `json:"userMonthlyLimit"`
+	UserLimits       map[string]float64 `json:"userLimits"`
+	// The percentages of a limit at which a warning event is fired, once per
+	// month
+	WarningThresholds []float64 `json:"warningThresholds"`
+}
+
+// Validate checks that the limits and thresholds are not negative
+func (b BudgetConfig) Validate() error {
+	if b.MonthlyLimit < 0 {
+		return errors.New("monthlyLimit cannot be negative")
+	}
+	if b.UserMonthlyLimit < 0 {
+		return errors.New("userMonthlyLimit cannot be negative")
+	}
+	for u, l := range b.UserLimits {
+		if l < 0 {
+			return fmt.Errorf("limit for user %s cannot be negative", u)
+		}
+	}
+	for _, th := range b.WarningThresholds {
+		if th <= 0 {
+			return fmt.Errorf("warning threshold %g must be positive", th)
+		}
+	}
+	return nil
+}
+
+// userLimit returns the monthly limit for the given user, 0 for no limit
+func (b BudgetConfig) userLimit(user string) float64 {
+	if l, ok := b.UserLimits[user]; ok {
+		return l
+	}
+	return b.UserMonthlyLimit
+}
+
+// BudgetStatus is the spending in the current month
+type BudgetStatus struct {
+	Config BudgetConfig `json:"config"`
+	// The start of the current month
+	Month time.Time `json:"month"`
+	// The cost of the instances that ran this month, plus the estimated
+	// remaining cost of the running test runs
+	Spent     float64            `json:"spent"`
+	UserSpent map[string]float64 `json:"userSpent"`
+}
+
+// budgetTracker holds the spending the scheduler checks the budgets against.
+// Must only be used with testRunsLock held
+type budgetTracker struct {
+	updated   time.Time
+	month     time.Time
+	spent     float64
+	userSpent map[string]float64
+	// The warnings that were fired, such that they are fired only once
+	warned map[string]bool
+}
+
+// SetBudget changes the budget configuration of the scheduler
+func (t *TestRunManager) SetBudget(b BudgetConfig) error {
+	err := b.Validate()
+	if err != nil {
+		return err
+	}
+	t.testRunsLock.Lock()
+	t.config.Budget = b
+	t.budget.updated = time.Time{}
+	t.testRunsLock.Unlock()
+	return t.PersistConfig()
+}
+
+// BudgetStatus returns the spending in the current month
+func (t *TestRunManager) BudgetStatus() BudgetStatus {
+	warnings := []coordinator.Event{}
+	t.testRunsLock.Lock()
+	t.refreshBudget(&warnings)
+	status := BudgetStatus{
+		Config:    t.config.Budget,
+		Month:     t.budget.month,
+		Spent:     t.budget.spent,
+		UserSpent: map[string]float64{},
+	}
+	for u, s := range t.budget.userSpent {
+		status.UserSpent[u] = s
+	}
+	t.testRunsLock.Unlock()
+	for _, ev := range warnings {
+		t.ev <- ev
+	}
+	return status
+}
+
+// SetBudgetOverride allows (or again disallows) a test run to start even if
+// it would exceed the budget
+func (t *TestRunManager) SetBudgetOverride(
+	tr *common.TestRun,
+	override bool,
+) {
+	t.testRunsLock.Lock()
+	tr.BudgetOverride = override
+	t.testRunsLock.Unlock()
+	t.PersistTestRun(tr)
+}
+
+// budgetsEnabled returns true if any limit is configured
+func (t *TestRunManager) budgetsEnabled() bool {
+	b := t.config.Budget
+	return b.MonthlyLimit > 0 || b.UserMonthlyLimit > 0 || len(b.UserLimits) > 0
+}
+
+// estimatedCost returns the estimated cost of a test run's instances at
+// on-demand prices
+func (t *TestRunManager) estimatedCost(tr *common.TestRun) float64 {
+	hours, _ := t.RuntimeEstimator().Estimate(tr)
+	return t.instanceCost(tr, hours).Cost
+}
+
+// instanceCost returns the instance-hours and cost of running each of a test
+// run's instances for the given number of hours at on-demand prices. Roles
+// that are not launched from a template are not included
+func (t *TestRunManager) instanceCost(
+	tr *common.TestRun,
+	hours float64,
+) awsmgr.CostSummary {
+	cost := awsmgr.NewCostSummary()
+	for _, r := range tr.Roles {
+		lt, err := t.prov.Template(r.AwsLaunchTemplateID)
+		if err != nil {
+			continue
+		}
+		cost.InstanceHours[lt.InstanceType] += hours
+		price, ok := t.awsm.HourlyPrice(lt.Region, lt.InstanceType, false)
+		if !ok {
+			cost.Add(awsmgr.CostSummary{
+				UnpricedTypes: []string{lt.InstanceType},
+			})
+		}
+		cost.Cost += hours * price
+	}
+	return cost
+}
+
+// refreshBudget recalculates the spending in the current month, if it was
+// not recalculated recently, and adds the warnings for the thresholds that
+// were crossed to warnings. Must be called with testRunsLock held, the
+// warnings should be sent after releasing it
+func (t *TestRunManager) refreshBudget(warnings *[]coordinator.Event) {
+	now := time.Now().UTC()
+	if now.Sub(t.budget.updated) < budgetRefreshInterval {
+		return
+	}
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if !month.Equal(t.budget.month) {
+		t.budget.warned = map[string]bool{}
+	}
+	t.budget.month = month
+	t.budget.updated = now
+	t.budget.spent = 0
+	t.budget.userSpent = map[string]float64{}
+	estimator := t.RuntimeEstimator()
+
+	// Only the part of an instance's runtime that falls in this month counts
+	usage := t.awsm.AllInstanceUsage()
+	for _, tr := range t.testRuns {
+		monthUsage := []awsmgr.InstanceUsage{}
+		for _, u := range usage[tr.ID] {
+			if !u.Terminated.IsZero() && u.Terminated.Before(month) {
+				continue
+			}
+			if u.Launched.Before(month) {
+				u.Launched = month
+			}
+			monthUsage = append(monthUsage, u)
+		}
+		cost := t.awsm.Cost(monthUsage).Cost
+
+		// Running test runs are expected to cost at least their estimate
+		if tr.Status == common.TestRunStatusRunning &&
+			!tr.AWSInstancesStopped {
+			hours, _ := estimator.Estimate(tr)
+			if est := t.instanceCost(tr, hours).Cost; est > cost {
+				cost = est
+			}
+		}
+		t.budget.spent += cost
+		t.budget.userSpent[tr.CreatedByThumbprint] += cost
+	}
+
+	t.checkBudgetWarnings(warnings)
+}
+
+// checkBudgetWarnings adds a warning event to warnings for every threshold of
+// the monthly and user limits that the spending crossed for the first time
+// this month. Must be called with testRunsLock held
+func (t *TestRunManager) checkBudgetWarnings(warnings *[]coordinator.Event) {
+	b := t.config.Budget
+	check := func(scope, user string, spent, limit float64) {
+		if limit <= 0 {
+			return
+		}
+		for _, th := range b.WarningThresholds {
+			key := fmt.Sprintf("%s-%s-%g", scope, user, th)
+			if spent < limit*th/100 || t.budget.warned[key] {
+				continue
+			}
+			t.budget.warned[key] = true
+			logger.Warnf(
+				"Spending of $%.2f reached %g%% of the %s budget of $%.2f %s",
+				spent,
+				th,
+				scope,
+				limit,
+				user,
+			)
+			*warnings = append(*warnings, coordinator.Event{
+				Type: coordinator.EventTypeBudgetWarning,
+				Payload: coordinator.BudgetWarningPayload{
+					Scope:            scope,
+					User:             user,
+					ThresholdPercent: th,
+					Spent:            spent,
+					Limit:            limit,
+				},
+			})
+		}
+	}
+	check("monthly", "", t.budget.spent, b.MonthlyLimit)
+	for user, spent := range t.budget.userSpent {
+		check("user", user, spent, b.userLimit(user))
+	}
+}
+
+// checkBudget returns the reason the test run cannot start within the
+// remaining budget, or an empty string if it can. If it can, its estimated
+// cost is reserved until the spending is recalculated. Must be called with
+// testRunsLock held, the budget warnings added to warnings should be sent
+// after releasing it
+func (t *TestRunManager) checkBudget(
+	tr *common.TestRun,
+	warnings *[]coordinator.Event,
+) string {
+	if !t.budgetsEnabled() {
+		return ""
+	}
+	t.refreshBudget(warnings)
+	b := t.config.Budget
+	user := tr.CreatedByThumbprint
+	est := t.estimatedCost(tr)
+	if !tr.BudgetOverride {
+		if b.MonthlyLimit > 0 && t.budget.spent+est > b.MonthlyLimit {
+			return fmt.Sprintf(
+				"estimated cost $%.2f exceeds the remaining monthly budget of $%.2f",
+				est,
+				b.MonthlyLimit-t.budget.spent,
+			)
+		}
+		limit := b.userLimit(user)
+		if limit > 0 && t.budget.userSpent[user]+est > limit {
+			return fmt.Sprintf(
+				"estimated cost $%.2f exceeds the user's remaining monthly budget of $%.2f",
+				est,
+				limit-t.budget.userSpent[user],
+			)
+		}
+	}
+	t.budget.spent += est
+	t.budget.userSpent[user] += est
+	return ""
+}
@@ -0,0 +1,305 @@
+package testruns
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// placeholderAgentBase is the ID of the placeholder agent of the first role
+// in a manual-run bundle. The IDs count down from there, since -1 already
+// means that a role has no agent yet
+const placeholderAgentBase int32 = -2
+
+func placeholderAgentID(i int) int32 {
+	return placeholderAgentBase - int32(i)
+}
+
+func placeholderIndex(agentID int32) (int, bool) {
+	if agentID > placeholderAgentBase {
+		return 0, false
+	}
+	return int(placeholderAgentBase - agentID), true
+}
+
+// placeholderIP returns the IP address that stands in for the host of the
+// i-th role in the config of a manual-run bundle
+func placeholderIP(i int) net.IP {
+	return net.IP{10, 255, byte(i / 250), byte(i%250 + 1)}
+}
+
+// BundleRole is the command line and environment of a role in a manual-run
+// bundle
+type BundleRole struct {
+	Name  string `json:"name"`
+	Role  string `json:"role"`
+	Index int    `json:"index"`
+	// The IP address that stands in for the role's host in the config
+	PlaceholderIP string   `json:"placeholderIP"`
+	Binary        string   `json:"binary"`
+	Args          []string `json:"args"`
+	Env           []string `json:"env"`
+}
+
+// BundleManifest describes how to run a test run outside of the controller
+type BundleManifest struct {
+	TestRunID     string             `json:"testRunID"`
+	Architecture  string             `json:"architecture"`
+	CommitHash    string             `json:"commitHash"`
+	SeederHash    string             `json:"seederHash"`
+	Debug         bool               `json:"debug"`
+	Roles         []BundleRole       `json:"roles"`
+	StartSequence []PlannedStartStep `json:"startSequence"`
+}
+
+// ManualRunBundle creates a TAR.GZ archive to reproduce the test run outside
+// of the controller. It contains the generated config, in which each role's
+// host is a placeholder IP address, a manifest with the command line and
+// environment of each role and the start sequence, a hosts file mapping the
+// roles to hosts and a shell script that starts the roles over SSH on those
+// hosts in the same order as the controller does
+func (t *TestRunManager) ManualRunBundle(
+	run *common.TestRun,
+) ([]byte, error) {
+	_, tr, err := common.GetTestRunCopy(run)
+	if err != nil {
+		return nil, err
+	}
+	applyRunDefaults(tr)
+	if errs := t.validateTestRun(tr); len(errs) > 0 {
+		return nil, fmt.Errorf(
+			"%d error(s) in the test run configuration, first: %v",
+			len(errs),
+			errs[0],
+		)
+	}
+
+	for i, r := range tr.Roles {
+		r.AgentID = placeholderAgentID(i)
+	}
+	cfg, err := t.generateConfig(tr, true)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := BundleManifest{
+		TestRunID:     tr.ID,
+		Architecture:  tr.Architecture,
+		CommitHash:    tr.CommitHash,
+		SeederHash:    tr.SeederHash,
+		Debug:         tr.RunPerf || tr.Debug,
+		Roles:         []BundleRole{},
+		StartSequence: t.plannedStartSequence(tr),
+	}
+	roles := map[*common.TestRunRole]BundleRole{}
+	for i, r := range tr.Roles {
+		args := append([]string{}, tr.Params...)
+		args = append(
+			args,
+			t.SubstituteParameters(roleParameters[r.Role], r, tr)...,
+		)
+		name := roleID(r)
+		br := BundleRole{
+			Name:          name,
+			Role:          string(r.Role),
+			Index:         r.Index,
+			PlaceholderIP: placeholderIP(i).String(),
+			Binary:        roleBinaries[r.Role],
+			Args:          args,
+			Env: []string{
+				fmt.Sprintf("TESTRUN_ID=%s", tr.ID),
+				fmt.Sprintf("TESTRUN_ROLE=%s", name),
+			},
+		}
+		roles[r] = br
+		manifest.Roles = append(manifest.Roles, br)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts bytes.Buffer
+	hosts.WriteString(
+		"# <role> <host>, replace the placeholder IP addresses\n",
+	)
+	for _, br := range manifest.Roles {
+		hosts.WriteString(fmt.Sprintf("%s %s\n", br.Name, br.PlaceholderIP))
+	}
+
+	dir := fmt.Sprintf("testrun-%s", tr.ID)
+	files := []struct {
+		name string
+		mode int64
+		data []byte
+	}{
+		{"config.cfg", 0644, cfg},
+		{"manifest.json", 0644, manifestJSON},
+		{"hosts.txt", 0644, hosts.Bytes()},
+		{"run.sh", 0755, t.manualRunScript(tr, manifest, roles)},
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    fmt.Sprintf("%s/%s", dir, f.name),
+			Mode:    f.mode,
+			Size:    int64(len(f.data)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for use as a single word in a shell command
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+const manualRunScriptHeader = `#!/bin/sh
+# Starts test run %s (%s, commit %s) on the hosts in
+# hosts.txt, in the same order as the test controller does. Each host must be
+# reachable over SSH and have the binaries of that commit extracted in
+# $CBDC_DIR (default: opencbdc-tx, relative to the home directory). The output
+# of each role is written to <role>.log in $CBDC_DIR on its host.
+set -e
+cd "$(dirname "$0")"
+CBDC_DIR=${CBDC_DIR:-opencbdc-tx}
+
+host() {
+	awk -v r="$1" '$1 == r { print $2 }' hosts.txt
+}
+
+start() {
+	h=$(host "$1")
+	cmd=$(printf '%%s\n' "$2" | sed -f hosts.sed)
+	echo "Starting $1 on $h"
+	ssh "$h" "cd $CBDC_DIR && nohup $cmd > $1.log 2>&1 < /dev/null &"
+}
+
+wait_for() {
+	port=$1
+	count=$2
+	end=$(($(date +%%s) + $3))
+	shift 3
+	while :; do
+		up=0
+		for r in "$@"; do
+			if nc -z "$(host "$r")" "$port" 2> /dev/null; then
+				up=$((up + 1))
+			fi
+		done
+		if [ "$up" -ge "$count" ]; then
+			return 0
+		fi
+		if [ "$(date +%%s)" -ge "$end" ]; then
+			echo "Only $up of $count roles are online on port $port" >&2
+			return 1
+		fi
+		sleep 1
+	done
+}
+
+`
+
+// manualRunScript generates the shell script of a manual-run bundle
+func (t *TestRunManager) manualRunScript(
+	tr *common.TestRun,
+	manifest BundleManifest,
+	roles map[*common.TestRunRole]BundleRole,
+) []byte {
+	var sh bytes.Buffer
+	sh.WriteString(fmt.Sprintf(
+		manualRunScriptHeader,
+		tr.ID,
+		tr.Architecture,
+		tr.CommitHash,
+	))
+
+	// Replace the placeholder IP addresses in the config and the command
+	// lines with the hosts, and copy the config to each of them
+	sh.WriteString("cat > hosts.sed << EOF\n")
+	for _, br := range manifest.Roles {
+		sh.WriteString(fmt.Sprintf(
+			"s/%s:/$(host %s):/g\n",
+			strings.ReplaceAll(br.PlaceholderIP, ".", "\\."),
+			br.Name,
+		))
+	}
+	sh.WriteString("EOF\n")
+	sh.WriteString("sed -f hosts.sed config.cfg > config.local.cfg\n")
+	sh.WriteString(
+		"for h in $(awk '!/^#/ { print $2 }' hosts.txt | sort -u); do\n",
+	)
+	sh.WriteString("\tscp -q config.local.cfg \"$h:$CBDC_DIR/config.cfg\"\n")
+	sh.WriteString("done\n")
+
+	for _, seq := range t.createStartSequence(tr) {
+		if len(seq.roles) == 0 {
+			continue
+		}
+		sh.WriteString("\n")
+		if seq.waitBefore > time.Millisecond {
+			sh.WriteString(fmt.Sprintf("sleep %g\n", seq.waitBefore.Seconds()))
+		}
+		names := []string{}
+		for _, r := range seq.roles {
+			br := roles[r]
+			cmd := []string{"env"}
+			for _, e := range br.Env {
+				cmd = append(cmd, shellQuote(e))
+			}
+			cmd = append(cmd, shellQuote("./"+br.Binary))
+			for _, a := range br.Args {
+				cmd = append(cmd, shellQuote(a))
+			}
+			sh.WriteString(fmt.Sprintf(
+				"start %s %s\n",
+				br.Name,
+				shellQuote(strings.Join(cmd, " ")),
+			))
+			names = append(names, br.Name)
+		}
+		for i, inc := range seq.waitForPort {
+			count := len(seq.roles)
+			if i < len(seq.waitForPortCount) && seq.waitForPortCount[i] > 0 {
+				count = seq.waitForPortCount[i]
+			}
+			sh.WriteString(fmt.Sprintf(
+				"wait_for %d %d %d %s\n",
+				portNums[seq.roles[0].Role]+int(inc),
+				count,
+				int(seq.timeout.Seconds()),
+				strings.Join(names, " "),
+			))
+		}
+	}
+	return sh.Bytes()
+}
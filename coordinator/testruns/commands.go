@@ -8,6 +8,7 @@ import (
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // runningCommand is used to store a reference to all active commands' IDs and
@@ -125,8 +126,9 @@ func (t *TestRunManager) StartRoleBinaries(
 				params,
 				t.SubstituteParameters(roleParameters[r.Role], r, tr)...)
 
-			t.WriteLog(
+			t.WriteLogFields(
 				tr,
+				roleLogFields(r),
 				"Starting %s on agent %d with parameters %v",
 				roleBinaries[r.Role],
 				r.AgentID,
@@ -153,13 +155,17 @@ func (t *TestRunManager) StartRoleBinaries(
 				tr.PerfSampleRate,
 				tr.Debug,
 				tr.RecordNetworkTraffic,
+				t.SubstituteParameters(liveSampleFiles[r.Role], r, tr),
+				t.liveSamplesChan(tr),
 			)
 			cmdLock.Lock()
 			if err != nil {
 				// If an error occurred, write it to the test run log and
 				// append it to the errors array
-				t.WriteLog(
+				t.WriteLogLevel(
 					tr,
+					logging.LogLevelError,
+					roleLogFields(r),
 					"Error occurred starting %s on agent %d: %s",
 					roleBinaries[r.Role],
 					r.AgentID,
@@ -167,6 +173,18 @@ func (t *TestRunManager) StartRoleBinaries(
 				)
 				errs = append(errs, err)
 			} else {
+				t.WriteLogFields(
+					tr,
+					logging.Fields{
+						"agent":   r.AgentID,
+						"role":    fmt.Sprintf("%s-%d", r.Role, r.Index),
+						"command": fmt.Sprintf("%x", cmdID),
+					},
+					"Started %s on agent %d as command %x",
+					roleBinaries[r.Role],
+					r.AgentID,
+					cmdID,
+				)
 				// The command started succesfully on the agent, so append the
 				// command's id associated with the agent ID to the running
 				// commands array
@@ -256,8 +274,12 @@ func (t *TestRunManager) TerminateAllCmds(
 		go func(cmd runningCommand) {
 			err := t.am.TerminateCommand(cmd.agentID, cmd.commandID)
 			if err != nil && err != coordinator.ErrAgentNotFound {
-				t.WriteLog(
+				t.WriteLogFields(
 					tr,
+					logging.Fields{
+						"agent":   cmd.agentID,
+						"command": fmt.Sprintf("%x", cmd.commandID),
+					},
 					"Error terminating command %x on agent %d: %s",
 					cmd.commandID,
 					cmd.agentID,
@@ -292,8 +314,12 @@ func (t *TestRunManager) BreakAllCmds(
 		go func(cmd runningCommand) {
 			err := t.am.BreakCommand(cmd.agentID, cmd.commandID)
 			if err != nil && err != coordinator.ErrAgentNotFound {
-				t.WriteLog(
+				t.WriteLogFields(
 					tr,
+					logging.Fields{
+						"agent":   cmd.agentID,
+						"command": fmt.Sprintf("%x", cmd.commandID),
+					},
 					"Error breaking command %x on agent %d: %s",
 					cmd.commandID,
 					cmd.agentID,
@@ -323,7 +349,7 @@ func (t *TestRunManager) HandleCommandFailure(
 ) error {
 	err := t.BreakAndTerminateAllCmds(tr, allCmds)
 	if err != nil {
-		t.WriteLog(tr, "Error breaking commands: %v", err)
+		t.WriteLogError(tr, "Error breaking commands: %v", err)
 	}
 
 	// Even if commands fail, the performance profiles might be
@@ -331,12 +357,12 @@ func (t *TestRunManager) HandleCommandFailure(
 	time.Sleep(5 * time.Second)
 	err = t.GetPerformanceProfiles(tr, allCmds, envs)
 	if err != nil {
-		t.WriteLog(tr, "Error getting performance profiles: %v", err)
+		t.WriteLogError(tr, "Error getting performance profiles: %v", err)
 	}
 
 	err = t.GetLogFiles(tr, allCmds, envs)
 	if err != nil {
-		t.WriteLog(tr, "Error getting log files: %v", err)
+		t.WriteLogError(tr, "Error getting log files: %v", err)
 	}
 
 	// Instruct all agents to upload their outputs, but ignore it if the files
@@ -344,7 +370,7 @@ func (t *TestRunManager) HandleCommandFailure(
 	// interested in the files that are available
 	err = t.CopyOutputs(tr, envs, true)
 	if err != nil {
-		t.WriteLog(tr, "Error copying outputs: %v", err)
+		t.WriteLogError(tr, "Error copying outputs: %v", err)
 	}
 
 	t.FailTestRun(
@@ -369,6 +395,11 @@ func (t *TestRunManager) RunBinaries(
 	cmd chan *common.ExecutedCommand,
 	failures chan *common.ExecutedCommand,
 ) error {
+	// Aggregate the samples the load generators report while the test is
+	// running, to show live results in the frontend
+	t.startLiveResults(tr)
+	defer t.stopLiveResults(tr)
+
 	if t.IsAtomizer(tr.Architecture) {
 		return t.RunBinariesAtomizer(tr, envs, cmd, failures)
 	} else if t.Is2PC(tr.Architecture) {
@@ -12,7 +12,9 @@ import (
 // TestManagerConfig is the main type in which parameters for the controller can
 // be persisted
 type TestManagerConfig struct {
-	MaxAgents int `json:"maxAgents"`
+	MaxAgents int             `json:"maxAgents"`
+	FairShare FairShareConfig `json:"fairShare"`
+	Budget    BudgetConfig    `json:"budget"`
 }
 
 // SetMaxAgents changes the maximum number of parallel running agents which is
@@ -58,7 +60,7 @@ func (t *TestRunManager) LoadConfig() error {
 func (t *TestRunManager) PersistConfig() error {
 	f, err := os.OpenFile(
 		filepath.Join(common.DataDir(), "testruns", "manager.config.json"),
-		os.O_CREATE|os.O_WRONLY,
+		os.O_CREATE|os.O_WRONLY|os.O_TRUNC,
 		0644,
 	)
 	if err != nil {
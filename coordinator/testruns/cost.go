@@ -0,0 +1,206 @@
+package testruns
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+)
+
+// DefaultInstanceHours is the estimated runtime of an instance when there are
+// no similar test runs to calibrate the estimate with
+const DefaultInstanceHours = 0.25
+
+// calibrationRuns is the maximum number of similar test runs the estimate of
+// an instance's runtime is based on
+const calibrationRuns = 10
+
+// TestRunCost returns the cost of the instances launched for the test run
+func (t *TestRunManager) TestRunCost(tr *common.TestRun) awsmgr.CostSummary {
+	return t.awsm.Cost(t.awsm.InstanceUsage(tr.ID))
+}
+
+// ChargeEstimate is the estimated instance-hours and cost of scheduling a test
+// run (or sweep)
+type ChargeEstimate struct {
+	awsmgr.CostSummary
+	TestRuns int `json:"testruns"`
+	// The number of runs whose runtime was estimated on each basis
+	Calibration map[string]int `json:"calibration"`
+}
+
+// EstimateCharge estimates the instance-hours and cost of scheduling the test
+// run (or sweep), with the runtime of the instances of each run calibrated
+// from the actual runtime of similar test runs that completed before. It
+// returns an error if a role uses a template the provider does not have
+func (t *TestRunManager) EstimateCharge(
+	tr *common.TestRun,
+) (*ChargeEstimate, error) {
+	runs := common.ExpandSweepRun(tr, "")
+	for _, run := range runs {
+		for _, r := range run.Roles {
+			if r.AwsLaunchTemplateID == "" {
+				continue
+			}
+			if _, err := t.prov.Template(r.AwsLaunchTemplateID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	estimator := t.RuntimeEstimator()
+	est := &ChargeEstimate{
+		CostSummary: awsmgr.NewCostSummary(),
+		TestRuns:    len(runs),
+		Calibration: map[string]int{},
+	}
+	for _, run := range runs {
+		hours, basis := estimator.Estimate(run)
+		est.Calibration[basis]++
+		est.Add(t.instanceCost(run, hours))
+	}
+	return est, nil
+}
+
+// estimateKeys returns the keys by which test runs are considered similar for
+// estimating their runtime: an exact key for runs with the same normalized
+// configuration regardless of the commits, and a loose key for runs with the
+// same architecture and sample count
+func estimateKeys(tr *common.TestRun) (string, string) {
+	cfg := tr.NormalizedConfigWithAgentData(false)
+	cfg.CommitHash = ""
+	cfg.ControllerCommitHash = ""
+	return fmt.Sprintf("%x", cfg.Hash()),
+		fmt.Sprintf("%s-%d", tr.Architecture, tr.SampleCount)
+}
+
+// calibrationSample is the runtime of the instances of a completed test run
+type calibrationSample struct {
+	exactKey, looseKey string
+	completed          time.Time
+	hours              float64
+}
+
+// RuntimeEstimator estimates how long the instances of a test run will run
+// from the actual runtime of similar test runs that completed before
+type RuntimeEstimator struct {
+	samples []calibrationSample
+}
+
+// RuntimeEstimator returns an estimator based on the test runs that have
+// completed so far. It is built once the test runs are loaded, and updated
+// as test runs complete. The returned estimator is not modified afterwards
+func (t *TestRunManager) RuntimeEstimator() *RuntimeEstimator {
+	t.estimatorLock.Lock()
+	defer t.estimatorLock.Unlock()
+	if t.estimator != nil {
+		return t.estimator
+	}
+	e := t.buildRuntimeEstimator()
+	// Until the test runs are loaded the estimator is incomplete, so it is
+	// only cached afterwards
+	if t.TestRunsLoaded() {
+		t.estimator = e
+	}
+	return e
+}
+
+// buildRuntimeEstimator builds an estimator from all completed test runs
+func (t *TestRunManager) buildRuntimeEstimator() *RuntimeEstimator {
+	usage := t.awsm.AllInstanceUsage()
+	now := time.Now()
+	e := &RuntimeEstimator{}
+	for _, tr := range t.GetTestRuns() {
+		if s, ok := newCalibrationSample(tr, usage[tr.ID], now); ok {
+			e.samples = append(e.samples, s)
+		}
+	}
+	sort.Slice(e.samples, func(i, j int) bool {
+		return e.samples[i].completed.After(e.samples[j].completed)
+	})
+	return e
+}
+
+// addCalibrationSample adds a test run that just completed to the cached
+// estimator. The estimator is replaced rather than modified, since callers
+// may still be using the previous one
+func (t *TestRunManager) addCalibrationSample(tr *common.TestRun) {
+	s, ok := newCalibrationSample(tr, t.awsm.InstanceUsage(tr.ID), time.Now())
+	if !ok {
+		return
+	}
+	t.estimatorLock.Lock()
+	defer t.estimatorLock.Unlock()
+	if t.estimator == nil {
+		return
+	}
+	samples := make([]calibrationSample, 0, len(t.estimator.samples)+1)
+	samples = append(samples, s)
+	samples = append(samples, t.estimator.samples...)
+	t.estimator = &RuntimeEstimator{samples: samples}
+}
+
+// newCalibrationSample returns the runtime of the instances of a completed
+// test run, or false if the test run is not completed
+func newCalibrationSample(
+	tr *common.TestRun,
+	usage []awsmgr.InstanceUsage,
+	now time.Time,
+) (calibrationSample, bool) {
+	if tr.Status != common.TestRunStatusCompleted ||
+		tr.Started.IsZero() || tr.Completed.Before(tr.Started) {
+		return calibrationSample{}, false
+	}
+	// Use the average runtime of the instances if it was recorded, and
+	// the duration of the test run otherwise
+	hours := tr.Completed.Sub(tr.Started).Hours()
+	if len(usage) > 0 {
+		hours = 0
+		for _, iu := range usage {
+			hours += iu.Hours(now)
+		}
+		hours /= float64(len(usage))
+	}
+	exact, loose := estimateKeys(tr)
+	return calibrationSample{
+		exactKey:  exact,
+		looseKey:  loose,
+		completed: tr.Completed,
+		hours:     hours,
+	}, true
+}
+
+// Estimate returns the expected runtime in hours of each instance of the test
+// run, and the basis of the estimate: "exact" if it is based on test runs
+// with the same configuration, "similar" if it is based on test runs with
+// the same architecture and sample count, or "default"
+func (e *RuntimeEstimator) Estimate(tr *common.TestRun) (float64, string) {
+	exact, loose := estimateKeys(tr)
+	for _, basis := range []string{"exact", "similar"} {
+		hours := []float64{}
+		for _, s := range e.samples {
+			if (basis == "exact" && s.exactKey == exact) ||
+				(basis == "similar" && s.looseKey == loose) {
+				hours = append(hours, s.hours)
+			}
+			if len(hours) == calibrationRuns {
+				break
+			}
+		}
+		if len(hours) > 0 {
+			return median(hours), basis
+		}
+	}
+	return DefaultInstanceHours, "default"
+}
+
+func median(x []float64) float64 {
+	s := append([]float64{}, x...)
+	sort.Float64s(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
@@ -0,0 +1,50 @@
+package testruns
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// ValidateDependencies checks that the dependencies of the test run are valid
+// and refer to known test runs
+func (t *TestRunManager) ValidateDependencies(tr *common.TestRun) error {
+	for _, d := range tr.Dependencies {
+		err := d.Validate()
+		if err != nil {
+			return err
+		}
+		if _, ok := t.GetTestRun(d.TestRunID); !ok {
+			return fmt.Errorf("dependency on unknown test run %s", d.TestRunID)
+		}
+	}
+	return nil
+}
+
+// checkDependencies evaluates the dependencies of a queued test run. The
+// test run can start if all of them are met, and should be canceled if any
+// of them failed. Must be called with testRunsLock held
+func (t *TestRunManager) checkDependencies(
+	tr *common.TestRun,
+) (common.DependencyState, string) {
+	state := common.DependencyMet
+	reason := ""
+	for _, d := range tr.Dependencies {
+		dep, ok := t.GetTestRun(d.TestRunID)
+		if !ok {
+			return common.DependencyFailed, fmt.Sprintf(
+				"dependency on unknown test run %s",
+				d.TestRunID,
+			)
+		}
+		s, r := d.Check(dep)
+		if s == common.DependencyFailed {
+			return s, r
+		}
+		if s == common.DependencyPending && state == common.DependencyMet {
+			state = s
+			reason = r
+		}
+	}
+	return state, reason
+}
@@ -22,7 +22,7 @@ func (t *TestRunManager) ExecuteTestRun(tr *common.TestRun) {
 	errs := t.ValidateTestRun(tr)
 	if len(errs) > 0 {
 		for _, err := range errs {
-			t.WriteLog(tr, "Error in test run configuration: %v", err)
+			t.WriteLogError(tr, "Error in test run configuration: %v", err)
 		}
 		t.FailTestRun(tr,
 			fmt.Errorf("%d error(s) in the test run configuration "+
@@ -172,6 +172,21 @@ func (t *TestRunManager) ExecuteTestRun(tr *common.TestRun) {
 		}
 	}
 
+	// The provider was asked for machines that meet the placement
+	// constraints, but agents chosen when scheduling and providers that
+	// launch from fixed templates can still violate them. Now that all
+	// agents are known, check that they meet the constraints of their roles
+	if errs := t.ValidatePlacement(tr); len(errs) > 0 {
+		for _, err := range errs {
+			t.WriteLog(tr, "Placement: %v", err)
+		}
+		t.FailTestRun(tr, fmt.Errorf(
+			"%d placement constraints are not met, see the log",
+			len(errs),
+		))
+		return
+	}
+
 	// Make a channel to receive completion of commands
 	cmd := make(chan *common.ExecutedCommand, 10)
 
@@ -318,7 +333,24 @@ func (t *TestRunManager) ExecuteTestRun(tr *common.TestRun) {
 	t.UpdateStatus(tr, common.TestRunStatusRunning, "Calculating test results")
 	_, err = t.CalculateResults(tr, false)
 	if err != nil {
-		t.WriteLog(tr, "Test result calculation failed: %v", err)
+		t.WriteLogError(tr, "Test result calculation failed: %v", err)
+	}
+
+	t.finishTestRun(tr, killErr)
+}
+
+// finishTestRun sets the final status of a test run that ran to its end, which
+// is only completed if it was not terminated by one of its guards
+func (t *TestRunManager) finishTestRun(tr *common.TestRun, killErr error) {
+	// A run that was terminated by one of its guards still has its outputs
+	// and results, but did not complete
+	if tr.GuardReason != "" {
+		details := guardDetails(tr)
+		if killErr != nil {
+			details += ", and unable to kill AWS agent(s)"
+		}
+		t.UpdateStatus(tr, common.TestRunStatusGuardTripped, details)
+		return
 	}
 
 	if killErr != nil {
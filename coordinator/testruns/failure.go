@@ -59,8 +59,9 @@ func (t *TestRunManager) FailRoles(tr *common.TestRun, cancel chan bool) {
 		}
 
 		// Execute the defined failure
-		t.WriteLog(
+		t.WriteLogFields(
 			tr,
+			roleLogFields(nextFailureRole),
 			"Failing role %s %d (Agent %d)",
 			string(nextFailureRole.Role),
 			nextFailureRole.Index,
@@ -89,7 +90,7 @@ func (t *TestRunManager) FailRoles(tr *common.TestRun, cancel chan bool) {
 			)
 			err := t.am.TerminateCommand(cmd.AgentID, cmdID)
 			if err != nil {
-				t.WriteLog(tr, "Could not kill command %x: %v", cmdID, err)
+				t.WriteLogWarning(tr, "Could not kill command %x: %v", cmdID, err)
 			}
 		}
 		// Set the failure as having executed succesfully, so that it's not
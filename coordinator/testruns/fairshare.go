@@ -0,0 +1,139 @@
+package testruns
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator"
+)
+
+// FairShareConfig configures how the scheduler divides the agents between
+// the users (identified by the thumbprint of the test run's creator) that
+// have test runs queued
+type FairShareConfig struct {
+	// The maximum number of agents the test runs of a single user can use at
+	// the same time, 0 for no limit
+	MaxAgentsPerUser int `json:"maxAgentsPerUser"`
+	// Per-user overrides of MaxAgentsPerUser, keyed by thumbprint
+	UserAgentQuotas map[string]int `json:"userAgentQuotas"`
+	// The relative share of the agents per user, keyed by thumbprint. Users
+	// that are not listed have weight 1
+	UserWeights map[string]float64 `json:"userWeights"`
+}
+
+// Validate checks that the quotas are not negative and the weights positive
+func (f FairShareConfig) Validate() error {
+	if f.MaxAgentsPerUser < 0 {
+		return errors.New("maxAgentsPerUser cannot be negative")
+	}
+	for u, q := range f.UserAgentQuotas {
+		if q < 0 {
+			return fmt.Errorf("agent quota for user %s cannot be negative", u)
+		}
+	}
+	for u, w := range f.UserWeights {
+		if w <= 0 {
+			return fmt.Errorf("weight for user %s must be positive", u)
+		}
+	}
+	return nil
+}
+
+// quota returns the maximum number of agents the given user can use at the
+// same time, 0 for no limit
+func (f FairShareConfig) quota(user string) int {
+	if q, ok := f.UserAgentQuotas[user]; ok {
+		return q
+	}
+	return f.MaxAgentsPerUser
+}
+
+// weight returns the relative share of the agents for the given user
+func (f FairShareConfig) weight(user string) float64 {
+	if w, ok := f.UserWeights[user]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// SetFairShare changes the fair-share configuration of the scheduler
+func (t *TestRunManager) SetFairShare(f FairShareConfig) error {
+	err := f.Validate()
+	if err != nil {
+		return err
+	}
+	t.testRunsLock.Lock()
+	t.config.FairShare = f
+	t.testRunsLock.Unlock()
+	return t.PersistConfig()
+}
+
+// nextFairShare picks the next test run the scheduler should consider from
+// the queue, and returns it along with the remainder of the queue. Test runs
+// with a higher priority always go first. Between test runs with equal
+// priority, the users take turns in a weighted round-robin: the user with
+// the fewest agents in use relative to its weight goes next, starting with
+// its oldest test run
+func (t *TestRunManager) nextFairShare(
+	queue []*common.TestRun,
+	userAgents map[string]int,
+) (*common.TestRun, []*common.TestRun) {
+	priority := queue[0].Priority
+	for _, tr := range queue {
+		if tr.Priority > priority {
+			priority = tr.Priority
+		}
+	}
+
+	// Find the oldest test run at this priority for every user
+	heads := map[string]int{}
+	for i, tr := range queue {
+		if tr.Priority != priority {
+			continue
+		}
+		h, ok := heads[tr.CreatedByThumbprint]
+		if !ok || tr.Created.Before(queue[h].Created) {
+			heads[tr.CreatedByThumbprint] = i
+		}
+	}
+
+	next := -1
+	nextShare := 0.0
+	for user, i := range heads {
+		share := float64(userAgents[user]) / t.config.FairShare.weight(user)
+		if next == -1 || share < nextShare ||
+			(share == nextShare && queue[i].Created.Before(queue[next].Created)) {
+			next = i
+			nextShare = share
+		}
+	}
+
+	tr := queue[next]
+	rest := make([]*common.TestRun, 0, len(queue)-1)
+	rest = append(rest, queue[:next]...)
+	rest = append(rest, queue[next+1:]...)
+	return tr, rest
+}
+
+// setScheduleReason records the scheduler's latest decision about a test run
+// and, if it changed, appends the event announcing it to events. The scheduler
+// sends these once it released testRunsLock, so a slow real-time channel does
+// not hold up the other users of the lock
+func (t *TestRunManager) setScheduleReason(
+	tr *common.TestRun,
+	reason string,
+	events *[]coordinator.Event,
+) {
+	if tr.ScheduleReason == reason {
+		return
+	}
+	tr.ScheduleReason = reason
+	*events = append(*events, coordinator.Event{
+		Type: coordinator.EventTypeTestRunScheduleReasonChanged,
+		Payload: coordinator.TestRunScheduleReasonChangedPayload{
+			TestRunID: tr.ID,
+			Reason:    reason,
+		},
+	})
+}
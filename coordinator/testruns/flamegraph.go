@@ -7,7 +7,6 @@ import (
 	"path/filepath"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // CalculateFlameGraph uses a python script to turn the data gathered by running
@@ -20,7 +19,7 @@ func (t *TestRunManager) CalculateFlameGraph(
 ) error {
 	t.testRunResultsLock.Lock()
 	defer t.testRunResultsLock.Unlock()
-	logging.Infof("Calculating flame graph for command %s", commandID)
+	logger.Infof("Calculating flame graph for command %s", commandID)
 
 	exeDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
 	if err != nil {
@@ -30,7 +29,7 @@ func (t *TestRunManager) CalculateFlameGraph(
 	cmd.Dir = exeDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		logging.Warnf(
+		logger.Warnf(
 			"Unable to calculate flame graph: %v\n\n%s",
 			err,
 			string(out),
@@ -0,0 +1,237 @@
+package testruns
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// guardLag is how long the guards wait for the samples of a second to be
+// reported by all load generators before they evaluate it
+const guardLag = 2 * liveResultsInterval
+
+// The window and percentile of guards that do not set them
+const (
+	defaultGuardWindow     = 30
+	defaultGuardPercentile = 99
+)
+
+// guardWindow returns the number of seconds a guard is evaluated over
+func guardWindow(window int) int64 {
+	if window <= 0 {
+		return defaultGuardWindow
+	}
+	return int64(window)
+}
+
+// violatedGuard evaluates the guards of the test run against the live
+// results at time now, and returns why a guard is violated or an empty string
+// if none are. The guards are evaluated once the first samples have been
+// reported and the warm-up has passed
+func (l *liveResults) violatedGuard(tr *common.TestRun, now time.Time) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	warmup := time.Duration(tr.GuardWarmup) * time.Second
+	if l.firstReport.IsZero() || now.Sub(l.firstReport) < warmup {
+		return ""
+	}
+
+	if tr.GuardNoProgress > 0 {
+		idle := now.Sub(l.lastProgress)
+		if idle >= time.Duration(tr.GuardNoProgress)*time.Second {
+			return fmt.Sprintf(
+				"no transactions completed for %d seconds",
+				int(idle.Seconds()),
+			)
+		}
+	}
+
+	// The windows end at the last second all samples should be reported for,
+	// and cannot start before the warm-up has passed
+	end := now.Add(-guardLag).Unix()
+	start := l.firstSec + int64(tr.GuardWarmup)
+
+	if tr.GuardMinThroughput > 0 {
+		window := guardWindow(tr.GuardThroughputWindow)
+		if end-window+1 >= start {
+			count := int64(0)
+			for sec := end - window + 1; sec <= end; sec++ {
+				if w, ok := l.windows[sec]; ok {
+					count += w.count
+				}
+			}
+			tps := float64(count) / float64(window)
+			if tps < tr.GuardMinThroughput {
+				return fmt.Sprintf(
+					"throughput of %.2f TX/s over the last %d seconds is "+
+						"below %g TX/s",
+					tps,
+					window,
+					tr.GuardMinThroughput,
+				)
+			}
+		}
+	}
+
+	if tr.GuardMaxLatency > 0 {
+		window := guardWindow(tr.GuardLatencyWindow)
+		percentile := tr.GuardLatencyPercentile
+		if percentile <= 0 {
+			percentile = defaultGuardPercentile
+		}
+		if end-window+1 >= start {
+			histogram := map[int32]int64{}
+			for sec := end - window + 1; sec <= end; sec++ {
+				if w, ok := l.windows[sec]; ok {
+					for b, n := range w.histogram {
+						histogram[b] += n
+					}
+				}
+			}
+			// Without samples there is no latency, which is up to the other
+			// guards
+			latency := common.HistogramPercentile(histogram, percentile)
+			if latency > tr.GuardMaxLatency {
+				return fmt.Sprintf(
+					"p%g latency of %.3fs over the last %d seconds is "+
+						"above %gs",
+					percentile,
+					latency,
+					window,
+					tr.GuardMaxLatency,
+				)
+			}
+		}
+	}
+	return ""
+}
+
+// checkGuards terminates the test run through its TerminateChan if one of
+// its guards is violated. The reason is kept with the live results, since
+// the test run is owned by the goroutine executing it, which copies the
+// reason to GuardReason when it stops the live results
+func (t *TestRunManager) checkGuards(tr *common.TestRun, l *liveResults) {
+	if !tr.HasGuards() || l.violation() != "" {
+		return
+	}
+	reason := l.violatedGuard(tr, time.Now())
+	if reason == "" {
+		return
+	}
+	l.lock.Lock()
+	l.guardReason = reason
+	l.lock.Unlock()
+	t.WriteLogWarning(
+		tr,
+		"Guard violated: %s, terminating the test run",
+		reason,
+	)
+	select {
+	case tr.TerminateChan <- true:
+	default:
+		// A termination is already pending
+	}
+}
+
+// violation returns why a guard of the test run was violated, or an empty
+// string if none was
+func (l *liveResults) violation() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.guardReason
+}
+
+// guardViolation returns why a guard of the test run was violated, or an
+// empty string if none was or no live results are aggregated for it
+func (t *TestRunManager) guardViolation(tr *common.TestRun) string {
+	v, ok := t.live.Load(tr.ID)
+	if !ok {
+		return ""
+	}
+	return v.(*liveResults).violation()
+}
+
+// guardDetails returns the details of the status of a test run that was
+// terminated by one of its guards
+func guardDetails(tr *common.TestRun) string {
+	return fmt.Sprintf("Aborted by guard: %s", tr.GuardReason)
+}
+
+// validateGuards validates the guards of the test run
+func (t *TestRunManager) validateGuards(tr *common.TestRun) []error {
+	errs := []error{}
+	nonNegative := []struct {
+		field string
+		value float64
+	}{
+		{"guardWarmup", float64(tr.GuardWarmup)},
+		{"guardMinThroughput", tr.GuardMinThroughput},
+		{"guardThroughputWindow", float64(tr.GuardThroughputWindow)},
+		{"guardMaxLatency", tr.GuardMaxLatency},
+		{"guardLatencyWindow", float64(tr.GuardLatencyWindow)},
+		{"guardNoProgress", float64(tr.GuardNoProgress)},
+	}
+	for _, f := range nonNegative {
+		if f.value < 0 {
+			errs = append(errs, common.NewValidationError(
+				f.field,
+				common.ValidationCodeOutOfRange,
+				"the guard setting cannot be negative",
+			))
+		}
+	}
+	if tr.GuardLatencyPercentile < 0 || tr.GuardLatencyPercentile > 100 {
+		errs = append(errs, common.NewValidationError(
+			"guardLatencyPercentile",
+			common.ValidationCodeOutOfRange,
+			"the latency percentile [%g] should be between 0 and 100",
+			tr.GuardLatencyPercentile,
+		))
+	}
+	windows := []struct {
+		field string
+		value int
+	}{
+		{"guardThroughputWindow", tr.GuardThroughputWindow},
+		{"guardLatencyWindow", tr.GuardLatencyWindow},
+	}
+	for _, w := range windows {
+		if w.value > maxLiveResultSeconds {
+			errs = append(errs, common.NewValidationError(
+				w.field,
+				common.ValidationCodeOutOfRange,
+				"the guard window cannot be longer than %d seconds",
+				maxLiveResultSeconds,
+			))
+		}
+	}
+	// Samples are reported every interval, so a shorter time without
+	// progress would trip between reports
+	minNoProgress := int(2 * liveResultsInterval / time.Second)
+	if tr.GuardNoProgress > 0 && tr.GuardNoProgress < minNoProgress {
+		errs = append(errs, common.NewValidationError(
+			"guardNoProgress",
+			common.ValidationCodeOutOfRange,
+			"the time without progress should be at least %d seconds",
+			minNoProgress,
+		))
+	}
+
+	if tr.HasGuards() {
+		reports := false
+		for _, r := range tr.Roles {
+			if len(liveSampleFiles[r.Role]) > 0 {
+				reports = true
+			}
+		}
+		if !reports {
+			errs = append(errs, common.NewValidationError(
+				"roles",
+				common.ValidationCodeRequired,
+				"guards need a load generator that reports samples",
+			))
+		}
+	}
+	return errs
+}
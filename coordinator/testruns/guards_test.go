@@ -0,0 +1,59 @@
+package testruns
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/wire"
+)
+
+// TestGuardTrippedDuringLoad trips a guard while a fake load phase waits for
+// termination the way the architectures do, and checks the test run ends up
+// with the guard tripped status
+func TestGuardTrippedDuringLoad(t *testing.T) {
+	m, _ := newFakeManager(t)
+	tr := fakeTestRun()
+	tr.GuardMinThroughput = 10
+	tr.GuardThroughputWindow = 30
+
+	// The load phase, run like RunBinaries does
+	loadDone := make(chan bool)
+	started := make(chan *liveResults)
+	go func() {
+		defer close(loadDone)
+		m.startLiveResults(tr)
+		defer m.stopLiveResults(tr)
+		v, _ := m.live.Load(tr.ID)
+		started <- v.(*liveResults)
+		select {
+		case <-tr.TerminateChan:
+		case <-time.After(10 * time.Second):
+			t.Error("the guard did not terminate the load phase")
+		}
+	}()
+	l := <-started
+
+	// A single transaction long enough ago for the window to be evaluated
+	l.add(&wire.LiveSamplesMsg{Windows: []wire.SampleWindow{
+		{Second: time.Now().Unix() - 100, Count: 1},
+	}})
+	m.checkGuards(tr, l)
+	<-loadDone
+
+	if !strings.HasPrefix(tr.GuardReason, "throughput of") {
+		t.Fatalf("GuardReason = %q, want the throughput guard", tr.GuardReason)
+	}
+	m.finishTestRun(tr, nil)
+	if tr.Status != common.TestRunStatusGuardTripped {
+		t.Errorf(
+			"status = %s, want %s",
+			tr.Status,
+			common.TestRunStatusGuardTripped,
+		)
+	}
+	if want := guardDetails(tr); tr.Details != want {
+		t.Errorf("details = %q, want %q", tr.Details, want)
+	}
+}
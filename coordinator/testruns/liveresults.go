@@ -0,0 +1,222 @@
+package testruns
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/wire"
+)
+
+// liveSampleFiles describes which files the agents running the given system
+// role tail while the role is running, to report live results of the test run
+var liveSampleFiles = map[common.SystemRole][]string{
+	common.SystemRoleAtomizerCliWatchtower: {"tx_samples_%IDX%.txt"},
+	common.SystemRoleTwoPhaseGen:           {"tx_samples_%IDX%.txt"},
+	common.SystemRolePhaseTwoGen:           {"tx_samples_%IDX%.txt"},
+}
+
+// liveResultsInterval is how often the live results that changed are sent to
+// the frontend
+const liveResultsInterval = 5 * time.Second
+
+// maxLiveResultSeconds limits the seconds of live results kept for a test run,
+// older ones are dropped
+const maxLiveResultSeconds = 3600
+
+// liveWindow is the aggregate of the samples of all load generators in one
+// second
+type liveWindow struct {
+	count      int64
+	latencySum int64
+	histogram  map[int32]int64
+}
+
+func (w *liveWindow) point(sec int64) common.LiveResultPoint {
+	p := common.LiveResultPoint{
+		Time:       sec,
+		Throughput: float64(w.count),
+	}
+	if w.count > 0 {
+		p.LatencyAvg = float64(w.latencySum) / float64(w.count) / 1e9
+	}
+	p.LatencyP50 = common.HistogramPercentile(w.histogram, 50)
+	p.LatencyP99 = common.HistogramPercentile(w.histogram, 99)
+	p.LatencyP999 = common.HistogramPercentile(w.histogram, 99.9)
+	return p
+}
+
+// liveResults aggregates the samples the agents report while a test run is
+// running
+type liveResults struct {
+	lock    sync.Mutex
+	windows map[int64]*liveWindow
+	// The seconds that changed since the last update sent to the frontend
+	changed map[int64]bool
+	// When the first samples were reported and the first second they were
+	// for, and when the last samples with completed transactions were
+	// reported
+	firstReport  time.Time
+	firstSec     int64
+	lastProgress time.Time
+	samples      chan *wire.LiveSamplesMsg
+	stop         chan bool
+	stopped      chan bool
+	// Why a guard of the test run was violated, empty if none was
+	guardReason string
+}
+
+func (l *liveResults) add(msg *wire.LiveSamplesMsg) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	for _, sw := range msg.Windows {
+		if l.firstSec == 0 || sw.Second < l.firstSec {
+			l.firstSec = sw.Second
+		}
+		if sw.Count > 0 {
+			l.lastProgress = now
+		}
+		w, ok := l.windows[sw.Second]
+		if !ok {
+			w = &liveWindow{histogram: map[int32]int64{}}
+			l.windows[sw.Second] = w
+		}
+		w.count += sw.Count
+		w.latencySum += sw.LatencySum
+		for b, n := range sw.LatencyHistogram {
+			w.histogram[b] += n
+		}
+		l.changed[sw.Second] = true
+	}
+	if l.firstReport.IsZero() && len(msg.Windows) > 0 {
+		l.firstReport = now
+	}
+
+	// Drop the oldest seconds beyond the limit
+	if len(l.windows) > maxLiveResultSeconds {
+		secs := l.seconds()
+		for _, sec := range secs[:len(secs)-maxLiveResultSeconds] {
+			delete(l.windows, sec)
+			delete(l.changed, sec)
+		}
+	}
+}
+
+// seconds returns the seconds there are windows for in order, must be called
+// with the lock held
+func (l *liveResults) seconds() []int64 {
+	secs := make([]int64, 0, len(l.windows))
+	for sec := range l.windows {
+		secs = append(secs, sec)
+	}
+	sort.Slice(secs, func(i, j int) bool { return secs[i] < secs[j] })
+	return secs
+}
+
+// points returns the result points of all seconds, or only of the ones that
+// changed since the last call with onlyChanged set
+func (l *liveResults) points(onlyChanged bool) []common.LiveResultPoint {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ret := []common.LiveResultPoint{}
+	for _, sec := range l.seconds() {
+		if onlyChanged && !l.changed[sec] {
+			continue
+		}
+		ret = append(ret, l.windows[sec].point(sec))
+	}
+	if onlyChanged {
+		l.changed = map[int64]bool{}
+	}
+	return ret
+}
+
+// startLiveResults starts aggregating the live samples reported for the test
+// run, sending the results to the frontend and checking the guards of the test
+// run against them
+func (t *TestRunManager) startLiveResults(tr *common.TestRun) {
+	l := &liveResults{
+		windows: map[int64]*liveWindow{},
+		changed: map[int64]bool{},
+		samples: make(chan *wire.LiveSamplesMsg, 100),
+		stop:    make(chan bool),
+		stopped: make(chan bool),
+	}
+	t.live.Store(tr.ID, l)
+	go func() {
+		defer close(l.stopped)
+		ticker := time.NewTicker(liveResultsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg := <-l.samples:
+				l.add(msg)
+			case <-ticker.C:
+				t.sendLiveResults(tr, l)
+				t.checkGuards(tr, l)
+			case <-l.stop:
+				t.sendLiveResults(tr, l)
+				return
+			}
+		}
+	}()
+}
+
+// stopLiveResults sends the last live results of the test run to the frontend
+// and stops aggregating them. Since the guards are no longer checked after
+// that, the reason a guard was violated for is copied to the test run
+func (t *TestRunManager) stopLiveResults(tr *common.TestRun) {
+	v, ok := t.live.Load(tr.ID)
+	if !ok {
+		return
+	}
+	l := v.(*liveResults)
+	close(l.stop)
+	<-l.stopped
+	if reason := l.violation(); reason != "" {
+		tr.GuardReason = reason
+	}
+	t.live.Delete(tr.ID)
+}
+
+// liveSamplesChan returns the channel the live samples reported for the test
+// run should be sent to, or nil if no live results are being aggregated for it
+func (t *TestRunManager) liveSamplesChan(
+	tr *common.TestRun,
+) chan *wire.LiveSamplesMsg {
+	v, ok := t.live.Load(tr.ID)
+	if !ok {
+		return nil
+	}
+	return v.(*liveResults).samples
+}
+
+// LiveResults returns the live results of a running test run, or nil if the
+// test run is not running
+func (t *TestRunManager) LiveResults(id string) []common.LiveResultPoint {
+	v, ok := t.live.Load(id)
+	if !ok {
+		return nil
+	}
+	return v.(*liveResults).points(false)
+}
+
+func (t *TestRunManager) sendLiveResults(
+	tr *common.TestRun,
+	l *liveResults,
+) {
+	points := l.points(true)
+	if len(points) == 0 {
+		return
+	}
+	t.ev <- coordinator.Event{
+		Type: coordinator.EventTypeTestRunLiveResults,
+		Payload: coordinator.TestRunLiveResultsPayload{
+			TestRunID: tr.ID,
+			Points:    points,
+		},
+	}
+}
@@ -6,14 +6,42 @@ import (
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
+var logger = logging.NewLogger("testruns")
+
 // WriteLog writes a statement to a testrun's log file and sends it over the
 // real-time channel to the UI
 func (t *TestRunManager) WriteLog(
 	tr *common.TestRun,
 	format string,
 	a ...interface{},
+) {
+	t.WriteLogFields(tr, nil, format, a...)
+}
+
+// WriteLogFields writes a statement to a testrun's log file like WriteLog, and
+// records the given fields (such as the agent, command or role the statement
+// relates to) alongside it in the test run's structured log
+func (t *TestRunManager) WriteLogFields(
+	tr *common.TestRun,
+	fields logging.Fields,
+	format string,
+	a ...interface{},
+) {
+	t.WriteLogLevel(tr, logging.LogLevelInfo, fields, format, a...)
+}
+
+// WriteLogLevel writes a statement to a testrun's log file like
+// WriteLogFields, at the given level in the structured log such that warnings
+// and errors can be filtered on
+func (t *TestRunManager) WriteLogLevel(
+	tr *common.TestRun,
+	level logging.LogLevel,
+	fields logging.Fields,
+	format string,
+	a ...interface{},
 ) {
 	line := fmt.Sprintf(
 		"[%s] %s",
@@ -27,5 +55,37 @@ func (t *TestRunManager) WriteLog(
 			Log:       line + "\n",
 		},
 	}
-	tr.WriteLog(line)
+	tr.WriteLogLevel(level, line, fields)
+}
+
+// roleLogFields returns the fields identifying a role in the structured log
+func roleLogFields(r *common.TestRunRole) logging.Fields {
+	return logging.Fields{
+		"agent": r.AgentID,
+		"role":  roleID(r),
+	}
+}
+
+// roleID returns the name of a role as it is used in logs and by the roles'
+// environment
+func roleID(r *common.TestRunRole) string {
+	return fmt.Sprintf("%s-%d", r.Role, r.Index)
+}
+
+// WriteLogError writes an error to a testrun's log file like WriteLog
+func (t *TestRunManager) WriteLogError(
+	tr *common.TestRun,
+	format string,
+	a ...interface{},
+) {
+	t.WriteLogLevel(tr, logging.LogLevelError, nil, format, a...)
+}
+
+// WriteLogWarning writes a warning to a testrun's log file like WriteLog
+func (t *TestRunManager) WriteLogWarning(
+	tr *common.TestRun,
+	format string,
+	a ...interface{},
+) {
+	t.WriteLogLevel(tr, logging.LogLevelWarning, nil, format, a...)
 }
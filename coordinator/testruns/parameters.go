@@ -63,20 +63,24 @@ func (t *TestRunManager) SubstituteParameters(
 	return newParams
 }
 
+// LoadGenAffinity returns the indexes of the agent roles that have the same
+// value as the load generator for the label of its affinity constraint, or
+// for the region if the test run has the LoadGenAffinity flag set
 func (t *TestRunManager) LoadGenAffinity(
 	tr *common.TestRun,
 	r *common.TestRunRole,
 ) string {
-	if !tr.LoadGenAffinity {
+	label := "region"
+	if r.Affinity != nil {
+		label = r.Affinity.Label
+	} else if !tr.LoadGenAffinity {
 		return ""
 	}
 	affinity := ""
-	region := t.awsm.GetLaunchTemplateRegion(r.AwsLaunchTemplateID)
+	value := t.placementLabels(r)[label]
 	for _, rr := range tr.Roles {
-
 		if rr.Role == common.SystemRoleAgent {
-			roleRegion := t.awsm.GetLaunchTemplateRegion(rr.AwsLaunchTemplateID)
-			if roleRegion == region {
+			if t.placementLabels(rr)[label] == value {
 				if affinity != "" {
 					affinity += ","
 				}
@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // CalculatePerformancePlot uses a python script to turn the data gathered by
@@ -33,7 +32,7 @@ func (t *TestRunManager) CalculatePerformancePlot(
 	}
 	t.testRunResultsLock.Lock()
 	defer t.testRunResultsLock.Unlock()
-	logging.Infof(
+	logger.Infof(
 		"Calculating performance plot %s for command %s",
 		plotType,
 		commandID,
@@ -55,7 +54,7 @@ func (t *TestRunManager) CalculatePerformancePlot(
 	cmd.Dir = testRunDir
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		logging.Warnf(
+		logger.Warnf(
 			"Unable to calculate performance data: %v\n\n%s",
 			err,
 			string(out),
@@ -13,7 +13,6 @@ import (
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // PersistTestRun stores the test run data in the persisted state. At present,
@@ -37,7 +36,7 @@ func (t *TestRunManager) PersistTestRun(tr *common.TestRun) {
 	)
 	err := os.MkdirAll(testRunDir, 0755)
 	if err != nil && !errors.Is(err, os.ErrExist) {
-		logging.Errorf("Error creating testrun dir: %v", err)
+		logger.Errorf("Error creating testrun dir: %v", err)
 	}
 	f, err := os.OpenFile(
 		filepath.Join(testRunDir, "metadata.json"),
@@ -45,7 +44,7 @@ func (t *TestRunManager) PersistTestRun(tr *common.TestRun) {
 		0644,
 	)
 	if err != nil {
-		logging.Warnf("Unable to persist testrun %s: %v", tr.ID, err)
+		logger.Warnf("Unable to persist testrun %s: %v", tr.ID, err)
 		return
 	}
 	defer f.Close()
@@ -53,7 +52,7 @@ func (t *TestRunManager) PersistTestRun(tr *common.TestRun) {
 	// Encode the testrun as JSON into the created file
 	err = json.NewEncoder(f).Encode(tr)
 	if err != nil {
-		logging.Warnf("Unable to persist testrun %s: %v", tr.ID, err)
+		logger.Warnf("Unable to persist testrun %s: %v", tr.ID, err)
 		return
 	}
 
@@ -82,13 +81,13 @@ func (t *TestRunManager) LoadTestResult(tr *common.TestRun) {
 		var tres common.TestResult
 		err = json.NewDecoder(f).Decode(&tres)
 		if err != nil {
-			logging.Warnf("Unable to decode testrun results %s: %v", tr.ID, err)
+			logger.Warnf("Unable to decode testrun results %s: %v", tr.ID, err)
 		} else {
 			tr.Result = &tres
-			logging.Debugf("Loaded test result for run %s (%f tps)", tr.Result.ThroughputAvg)
+			logger.Debugf("Loaded test result for run %s (%f tps)", tr.ID, tr.Result.ThroughputAvg)
 		}
 	} else {
-		logging.Warnf("Unable to load test results %s: %v", tr.ID, err)
+		logger.Warnf("Unable to load test results %s: %v", tr.ID, err)
 	}
 }
 
@@ -101,7 +100,7 @@ func (t *TestRunManager) LoadAllTestRuns() {
 	// again and again on startup
 	err := os.MkdirAll(archiveDir, 0755)
 	if err != nil && !errors.Is(err, os.ErrExist) {
-		logging.Errorf("Error creating archive dir: %v", err)
+		logger.Errorf("Error creating archive dir: %v", err)
 	}
 
 	// Keep track of test runs that were interrupted such that we can reschedule
@@ -112,16 +111,16 @@ func (t *TestRunManager) LoadAllTestRuns() {
 		activeDir,
 		func(path string, info fs.DirEntry, err error) error {
 			if err != nil {
-				logging.Warnf("Error scanning test run directory: %v", err)
+				logger.Warnf("Error scanning test run directory: %v", err)
 				// If there was an error reading the directory, just quit
 				return nil
 			}
 			relPath, err := filepath.Rel(activeDir, path)
 			if err != nil {
-				logging.Warnf("Error determining relative path: %v", err)
+				logger.Warnf("Error determining relative path: %v", err)
 				return nil
 			}
-			//logging.Infof("Walkdir: %s - Rel: %s", path, relPath)
+			//logger.Infof("Walkdir: %s - Rel: %s", path, relPath)
 
 			if relPath == "." {
 				// Ignore this
@@ -134,7 +133,7 @@ func (t *TestRunManager) LoadAllTestRuns() {
 					testRunID := relPath
 					tr, err := t.LoadTestRun(testRunID)
 					if err != nil {
-						logging.Warnf(
+						logger.Warnf(
 							"Error loading testrun %s: %v",
 							testRunID,
 							err,
@@ -174,10 +173,10 @@ func (t *TestRunManager) LoadAllTestRuns() {
 						// such that we skip scanning over them the next time
 						// around
 						newDir := strings.Replace(path, activeDir, archiveDir, 1)
-						logging.Infof("Archiving testrun %s by moving folder [%s] to [%s]", tr.ID, path, newDir)
+						logger.Infof("Archiving testrun %s by moving folder [%s] to [%s]", tr.ID, path, newDir)
 						err = os.Rename(path, newDir)
 						if err != nil {
-							logging.Warnf(
+							logger.Warnf(
 								"Error moving old testrun %s: %v",
 								testRunID,
 								err,
@@ -194,12 +193,12 @@ func (t *TestRunManager) LoadAllTestRuns() {
 		},
 	)
 	if err != nil {
-		logging.Errorf("Error while loading testruns: %v", err)
+		logger.Errorf("Error while loading testruns: %v", err)
 	}
-	logging.Infof("Done loading test runs")
+	logger.Infof("Done loading test runs")
 	t.testRunsLock.Unlock()
 
-	logging.Infof("Rescheduling %d interrupted runs", len(runsToReschedule))
+	logger.Infof("Rescheduling %d interrupted runs", len(runsToReschedule))
 	for i := range runsToReschedule {
 		t.Reschedule(runsToReschedule[i])
 	}
@@ -227,14 +226,14 @@ func (t *TestRunManager) LoadTestRun(id string) (*common.TestRun, error) {
 		0644,
 	)
 	if err != nil {
-		logging.Warnf("Unable to load testrun %s: %v", id, err)
+		logger.Warnf("Unable to load testrun %s: %v", id, err)
 		return nil, err
 	}
 	defer f.Close()
 	var tr common.TestRun
 	err = json.NewDecoder(f).Decode(&tr)
 	if err != nil {
-		logging.Warnf("Unable to decode testrun %s: %v", id, err)
+		logger.Warnf("Unable to decode testrun %s: %v", id, err)
 		return nil, err
 	}
 
@@ -245,13 +244,13 @@ func (t *TestRunManager) LoadTestRun(id string) (*common.TestRun, error) {
 	raw := map[string]interface{}{}
 	_, err = f.Seek(0, 0)
 	if err != nil {
-		logging.Warnf("Unable to seek to start of stream: %v", id, err)
+		logger.Warnf("Unable to seek to start of stream %s: %v", id, err)
 		return nil, err
 	}
 
 	err = json.NewDecoder(f).Decode(&raw)
 	if err != nil {
-		logging.Warnf("Unable to decode testrun %s: %v", id, err)
+		logger.Warnf("Unable to decode testrun %s: %v", id, err)
 		return nil, err
 	}
 	_, ok := raw["trimSamplesAtStart"]
@@ -0,0 +1,346 @@
+package testruns
+
+import (
+	"fmt"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
+)
+
+func roleName(r *common.TestRunRole) string {
+	return fmt.Sprintf("%s %d", r.Role, r.Index)
+}
+
+// knownLabels returns the labels that are known for the agent a role runs on:
+// the labels its agent advertised if the agent is known, and the region and
+// instance type of its machine template
+func (t *TestRunManager) knownLabels(
+	r *common.TestRunRole,
+) map[string]string {
+	labels := map[string]string{}
+	if r.AgentID != -1 {
+		if a, err := t.coord.GetAgent(r.AgentID); err == nil {
+			for k, v := range a.Labels {
+				labels[k] = v
+			}
+		}
+	}
+	if lt, err := t.prov.Template(r.AwsLaunchTemplateID); err == nil {
+		if _, ok := labels["region"]; !ok {
+			labels["region"] = lt.Region
+		}
+		if _, ok := labels["instanceType"]; !ok {
+			labels["instanceType"] = lt.InstanceType
+		}
+	}
+	return labels
+}
+
+// placementLabels returns the labels of the agent a role runs on as far as
+// they are known, assuming its required labels will be met
+func (t *TestRunManager) placementLabels(
+	r *common.TestRunRole,
+) map[string]string {
+	labels := t.knownLabels(r)
+	for k, v := range r.RequiredLabels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// affinityClasses tracks which roles affinity constraints place on agents with
+// the same value of a label
+type affinityClasses map[string][]int
+
+func (a affinityClasses) find(label string, i int) int {
+	p := a[label]
+	for p[i] != i {
+		p[i] = p[p[i]]
+		i = p[i]
+	}
+	return i
+}
+
+func (a affinityClasses) union(label string, i, j, n int) {
+	if _, ok := a[label]; !ok {
+		a[label] = make([]int, n)
+		for k := range a[label] {
+			a[label][k] = k
+		}
+	}
+	a[label][a.find(label, i)] = a.find(label, j)
+}
+
+func (a affinityClasses) same(label string, i, j int) bool {
+	if _, ok := a[label]; !ok {
+		return false
+	}
+	return a.find(label, i) == a.find(label, j)
+}
+
+// antiAffinityGroup returns the key of the roles a role is spread over by its
+// anti-affinity, or an empty string if it has none
+func antiAffinityGroup(r *common.TestRunRole) string {
+	aa := r.AntiAffinity
+	if aa == nil || aa.Label == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Role, aa.Group, aa.Label)
+}
+
+// affinityClassesOf returns the affinity classes of the roles of the test run
+func affinityClassesOf(tr *common.TestRun) affinityClasses {
+	classes := affinityClasses{}
+	for i, r := range tr.Roles {
+		a := r.Affinity
+		if a == nil || a.Label == "" {
+			continue
+		}
+		for j, o := range tr.Roles {
+			if j != i && o.Role == a.Role && o.Index == a.Index {
+				classes.union(a.Label, i, j, len(tr.Roles))
+				break
+			}
+		}
+	}
+	return classes
+}
+
+// machineRequests returns the machines the given roles of the test run need
+// from the provider, with the placement constraints of the roles. Where a
+// constraint relates a role to roles whose label is known, such as roles
+// that are already placed or have the label as a required label, it becomes
+// a required or excluded label of the request. Otherwise it relates the
+// requests to each other
+func (t *TestRunManager) machineRequests(
+	tr *common.TestRun,
+	roles []int,
+) []provider.MachineRequest {
+	requested := map[int]bool{}
+	for _, i := range roles {
+		requested[i] = true
+	}
+	classes := affinityClassesOf(tr)
+	ret := make([]provider.MachineRequest, len(roles))
+	for n, i := range roles {
+		r := tr.Roles[i]
+		req := provider.MachineRequest{
+			TemplateID:     r.AwsLaunchTemplateID,
+			Labels:         map[string]string{},
+			ExcludedLabels: map[string][]string{},
+		}
+		for k, v := range r.RequiredLabels {
+			req.Labels[k] = v
+		}
+
+		for label := range classes {
+			related := false
+			for j := range tr.Roles {
+				if j == i || !classes.same(label, i, j) {
+					continue
+				}
+				related = true
+				if v, ok := t.placementLabels(tr.Roles[j])[label]; ok {
+					if _, set := req.Labels[label]; !set {
+						req.Labels[label] = v
+					}
+				}
+			}
+			if _, set := req.Labels[label]; related && !set {
+				c := provider.PlacementConstraint{
+					Key: fmt.Sprintf(
+						"%s/affinity/%s/%d",
+						tr.ID,
+						label,
+						classes.find(label, i),
+					),
+					Label: label,
+				}
+				req.Affinity = append(req.Affinity, c)
+			}
+		}
+
+		if group := antiAffinityGroup(r); group != "" {
+			label := r.AntiAffinity.Label
+			for j, o := range tr.Roles {
+				if j == i || requested[j] || antiAffinityGroup(o) != group {
+					continue
+				}
+				if v, ok := t.placementLabels(o)[label]; ok {
+					req.ExcludedLabels[label] = append(
+						req.ExcludedLabels[label],
+						v,
+					)
+				}
+			}
+			c := provider.PlacementConstraint{
+				Key:   fmt.Sprintf("%s/antiAffinity/%s", tr.ID, group),
+				Label: label,
+			}
+			req.AntiAffinity = append(req.AntiAffinity, c)
+		}
+		ret[n] = req
+	}
+	return ret
+}
+
+// ValidatePlacement checks the placement constraints of the roles: the
+// required labels, affinity and anti-affinity. It reports the constraints
+// that cannot be met given what is known about the agents, which before the
+// agents are launched is their machine templates and required labels, and
+// afterwards the labels the agents advertised
+func (t *TestRunManager) ValidatePlacement(tr *common.TestRun) []error {
+	errs := []error{}
+	n := len(tr.Roles)
+	labels := make([]map[string]string, n)
+	for i, r := range tr.Roles {
+		known := t.knownLabels(r)
+		for k, v := range r.RequiredLabels {
+			have, ok := known[k]
+			if ok && have != v {
+				errs = append(errs, common.NewValidationError(
+					common.RoleField(i, "requiredLabels."+k),
+					common.ValidationCodePlacement,
+					"role %s requires label %s=%s, but its agent has %s=%s",
+					roleName(r),
+					k,
+					v,
+					k,
+					have,
+				))
+			} else if !ok && r.AgentID != -1 {
+				errs = append(errs, common.NewValidationError(
+					common.RoleField(i, "requiredLabels."+k),
+					common.ValidationCodePlacement,
+					"role %s requires label %s=%s, but agent %d does not have it",
+					roleName(r),
+					k,
+					v,
+					r.AgentID,
+				))
+			}
+		}
+		labels[i] = t.placementLabels(r)
+	}
+
+	classes := affinityClasses{}
+	for i, r := range tr.Roles {
+		a := r.Affinity
+		if a == nil {
+			continue
+		}
+		if a.Label == "" {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "affinity.label"),
+				common.ValidationCodeRequired,
+				"the affinity of role %s needs a label",
+				roleName(r),
+			))
+			continue
+		}
+		j := -1
+		for k, o := range tr.Roles {
+			if o.Role == a.Role && o.Index == a.Index {
+				j = k
+				break
+			}
+		}
+		if j == -1 {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "affinity"),
+				common.ValidationCodeUnknownReference,
+				"role %s has affinity with role %s %d, which is not part of the test run",
+				roleName(r),
+				a.Role,
+				a.Index,
+			))
+			continue
+		}
+		if j == i {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "affinity"),
+				common.ValidationCodePlacement,
+				"role %s cannot have affinity with itself",
+				roleName(r),
+			))
+			continue
+		}
+		vi, oki := labels[i][a.Label]
+		vj, okj := labels[j][a.Label]
+		if oki && okj && vi != vj {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "affinity"),
+				common.ValidationCodePlacement,
+				"role %s must have the same %s as role %s, but they have %s and %s",
+				roleName(r),
+				a.Label,
+				roleName(tr.Roles[j]),
+				vi,
+				vj,
+			))
+		}
+		classes.union(a.Label, i, j, n)
+	}
+
+	groups := map[string][]int{}
+	groupOrder := []string{}
+	for i, r := range tr.Roles {
+		aa := r.AntiAffinity
+		if aa == nil {
+			continue
+		}
+		if aa.Label == "" {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "antiAffinity.label"),
+				common.ValidationCodeRequired,
+				"the anti-affinity of role %s needs a label",
+				roleName(r),
+			))
+			continue
+		}
+		key := antiAffinityGroup(r)
+		if _, ok := groups[key]; !ok {
+			groupOrder = append(groupOrder, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+	// Report one conflict per group, such that a large group with a single
+	// misconfiguration does not flood the errors
+	for _, key := range groupOrder {
+		members := groups[key]
+		label := tr.Roles[members[0]].AntiAffinity.Label
+	group:
+		for x, i := range members {
+			for _, j := range members[x+1:] {
+				vi, oki := labels[i][label]
+				vj, okj := labels[j][label]
+				if oki && okj && vi == vj {
+					errs = append(errs, common.NewValidationError(
+						common.RoleField(j, "antiAffinity"),
+						common.ValidationCodePlacement,
+						"roles %s and %s must have a different %s, but both have %s",
+						roleName(tr.Roles[i]),
+						roleName(tr.Roles[j]),
+						label,
+						vi,
+					))
+					break group
+				}
+				if classes.same(label, i, j) {
+					errs = append(errs, common.NewValidationError(
+						common.RoleField(j, "antiAffinity"),
+						common.ValidationCodePlacement,
+						"roles %s and %s must have a different %s, but their affinity places them together",
+						roleName(tr.Roles[i]),
+						roleName(tr.Roles[j]),
+						label,
+					))
+					break group
+				}
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,237 @@
+package testruns
+
+import (
+	"strings"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+// TestRunPlan describes what scheduling a test run (or sweep) would do,
+// without launching any infrastructure
+type TestRunPlan struct {
+	// Errors that prevent the test run from being scheduled at all
+	Errors []*common.ValidationError `json:"errors"`
+	Runs   []*PlannedRun             `json:"runs"`
+	// The machines and vCPUs all runs need from the provider per region,
+	// and the vCPU limit of the region
+	MachinesPerRegion map[string]int   `json:"machinesPerRegion"`
+	VCPUsPerRegion    map[string]int32 `json:"vcpusPerRegion"`
+	VCPULimits        map[string]int32 `json:"vcpuLimits"`
+	InstanceHours     float64          `json:"instanceHours"`
+	EstimatedCost     float64          `json:"estimatedCost"`
+	// Whether all runs are valid and can be scheduled
+	Valid bool `json:"valid"`
+}
+
+// PlannedRun describes a single test run that scheduling would create
+type PlannedRun struct {
+	TestRun *common.TestRun `json:"testRun"`
+	// The errors in the configuration of the run, it will fail if scheduled
+	Errors            []*common.ValidationError `json:"errors"`
+	MachinesPerRegion map[string]int            `json:"machinesPerRegion"`
+	VCPUsPerRegion    map[string]int32          `json:"vcpusPerRegion"`
+	// Why the provider cannot supply the machines right now, if it cannot
+	ProviderError string `json:"providerError,omitempty"`
+	// The configuration file, with dummy endpoints since the agents are not
+	// known yet
+	Config        string             `json:"config"`
+	StartSequence []PlannedStartStep `json:"startSequence"`
+	OutputFiles   []PlannedFile      `json:"outputFiles"`
+	// The estimated runtime of each of the instances, and what the estimate
+	// is based on
+	RuntimeHours  float64 `json:"runtimeHours"`
+	EstimateBasis string  `json:"estimateBasis"`
+	EstimatedCost float64 `json:"estimatedCost"`
+}
+
+// PlannedStartStep is an entry of the start sequence of a planned run
+type PlannedStartStep struct {
+	Roles             []string `json:"roles"`
+	WaitBeforeSeconds float64  `json:"waitBeforeSeconds"`
+	TimeoutSeconds    float64  `json:"timeoutSeconds"`
+	// Whether the roles keep running in the background while the rest of the
+	// sequence is started
+	Background bool              `json:"background"`
+	WaitFor    []PlannedPortWait `json:"waitFor"`
+}
+
+// PlannedPortWait is a port the start sequence waits for to be online on the
+// given number of roles before it continues
+type PlannedPortWait struct {
+	Port  int    `json:"port"`
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+}
+
+// PlannedFile is an output file that is collected from a role after the run
+type PlannedFile struct {
+	Role     string `json:"role"`
+	File     string `json:"file"`
+	Optional bool   `json:"optional"`
+}
+
+var portIncrementKinds = map[PortIncrement]string{
+	PortIncrementDefaultPort: "default",
+	PortIncrementRaftPort:    "raft",
+	PortIncrementClientPort:  "client",
+}
+
+// PlanTestRun performs all steps of scheduling and executing the test run
+// that do not touch infrastructure on a copy of it: validation, sweep
+// expansion, config generation with dummy agents and determining the start
+// sequence, and returns the resulting plan
+func (t *TestRunManager) PlanTestRun(
+	spec *common.TestRun,
+) (*TestRunPlan, error) {
+	_, tr, err := common.GetTestRunCopy(spec)
+	if err != nil {
+		return nil, err
+	}
+	plan := &TestRunPlan{
+		Errors:            []*common.ValidationError{},
+		Runs:              []*PlannedRun{},
+		MachinesPerRegion: map[string]int{},
+		VCPUsPerRegion:    map[string]int32{},
+		VCPULimits:        map[string]int32{},
+		Valid:             true,
+	}
+	// The sweep cannot be expanded if it is not valid
+	if errs := t.validateSpec(tr); len(errs) > 0 {
+		plan.Errors = common.ValidationErrors(errs)
+		plan.Valid = false
+		return plan, nil
+	}
+
+	applySpecDefaults(tr)
+	runs := common.ExpandSweepRun(tr, "")
+	estimator := t.RuntimeEstimator()
+	for _, run := range runs {
+		applyRunDefaults(run)
+		p := t.planRun(run, estimator)
+		if len(p.Errors) > 0 {
+			plan.Valid = false
+		}
+		for region, n := range p.MachinesPerRegion {
+			plan.MachinesPerRegion[region] += n
+		}
+		for region, n := range p.VCPUsPerRegion {
+			plan.VCPUsPerRegion[region] += n
+			plan.VCPULimits[region] = t.prov.VCPULimit(region)
+		}
+		plan.InstanceHours += p.RuntimeHours * float64(len(run.Roles))
+		plan.EstimatedCost += p.EstimatedCost
+		plan.Runs = append(plan.Runs, p)
+		// Peak sweeps schedule the next run based on the outcome of the
+		// previous one, so only the first is known in advance
+		if tr.SweepOneAtATime {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// planRun determines the plan for a single run of the test run
+func (t *TestRunManager) planRun(
+	tr *common.TestRun,
+	estimator *RuntimeEstimator,
+) *PlannedRun {
+	p := &PlannedRun{
+		TestRun:           tr,
+		MachinesPerRegion: map[string]int{},
+		VCPUsPerRegion:    t.GetRequiredVCPUs(tr),
+		OutputFiles:       []PlannedFile{},
+	}
+	requests := t.pendingMachineRequests(tr)
+	for _, req := range requests {
+		p.MachinesPerRegion[t.templateRegion(req.TemplateID)]++
+	}
+	if err := t.prov.Available(requests); err != nil {
+		p.ProviderError = err.Error()
+	}
+	p.RuntimeHours, p.EstimateBasis = estimator.Estimate(tr)
+	p.EstimatedCost = t.instanceCost(tr, p.RuntimeHours).Cost
+
+	p.Errors = common.ValidationErrors(t.validateTestRun(tr))
+	// Execution stops after validation fails, so there is nothing more to
+	// plan
+	if len(p.Errors) > 0 {
+		return p
+	}
+
+	cfg, err := t.generateConfig(tr, true)
+	if err != nil {
+		p.Errors = append(p.Errors, common.NewValidationError(
+			"",
+			common.ValidationCodeInvalid,
+			"generating config failed: %v",
+			err,
+		))
+	}
+	p.Config = string(cfg)
+
+	p.StartSequence = t.plannedStartSequence(tr)
+
+	for _, r := range tr.Roles {
+		files := copyFiles[common.SystemRole(r.Role)]
+		for _, f := range t.SubstituteParameters(files, r, tr) {
+			p.OutputFiles = append(p.OutputFiles, PlannedFile{
+				Role:     roleID(r),
+				File:     strings.TrimSuffix(f, "%%OPT"),
+				Optional: strings.HasSuffix(f, "%%OPT"),
+			})
+		}
+	}
+	return p
+}
+
+// plannedStartSequence describes the start sequence of the test run
+func (t *TestRunManager) plannedStartSequence(
+	tr *common.TestRun,
+) []PlannedStartStep {
+	ret := []PlannedStartStep{}
+	for _, seq := range t.createStartSequence(tr) {
+		if len(seq.roles) == 0 {
+			continue
+		}
+		step := PlannedStartStep{
+			Roles:             []string{},
+			WaitBeforeSeconds: seq.waitBefore.Seconds(),
+			TimeoutSeconds:    seq.timeout.Seconds(),
+			Background:        seq.doneChan != nil,
+			WaitFor:           []PlannedPortWait{},
+		}
+		for _, r := range seq.roles {
+			step.Roles = append(step.Roles, roleID(r))
+		}
+		for i, inc := range seq.waitForPort {
+			count := len(seq.roles)
+			if i < len(seq.waitForPortCount) && seq.waitForPortCount[i] > 0 {
+				count = seq.waitForPortCount[i]
+			}
+			step.WaitFor = append(step.WaitFor, PlannedPortWait{
+				Port:  portNums[seq.roles[0].Role] + int(inc),
+				Kind:  portIncrementKinds[inc],
+				Count: count,
+			})
+		}
+		ret = append(ret, step)
+	}
+	return ret
+}
+
+// createStartSequence returns the start sequence for the architecture of the
+// test run
+func (t *TestRunManager) createStartSequence(
+	tr *common.TestRun,
+) []startSequenceEntry {
+	if t.Is2PC(tr.Architecture) {
+		return t.CreateStartSequenceTwoPhase(tr)
+	} else if t.IsAtomizer(tr.Architecture) {
+		return t.CreateStartSequenceAtomizer(
+			tr,
+			make(chan []runningCommand, 1),
+			make(chan error, 1),
+		)
+	}
+	return t.CreateStartSequencePhaseTwo(tr)
+}
@@ -3,13 +3,11 @@ package testruns
 import (
 	"errors"
 	"fmt"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 	"github.com/mit-dci/opencbdc-tctl/wire"
 )
 
@@ -45,15 +43,8 @@ func (t *TestRunManager) PreseedShards(
 	errs := make([]error, 0)
 	errsLock := sync.Mutex{}
 
-	sourceRegion := os.Getenv("AWS_DEFAULT_REGION")
-	if sourceRegion == "" {
-		logging.Warnf(
-			"Environment AWS_DEFAULT_REGION is not set, falling back to us-east-1",
-		)
-		sourceRegion = "us-east-1"
-	}
-
-	bucket := os.Getenv("BINARIES_S3_BUCKET")
+	sourceRegion := t.cfg.AWS.DefaultRegion
+	bucket := t.cfg.AWS.BinariesBucket
 	preseedShard := func(r *common.TestRunRole, trn *common.TestRun, shardStart, shardEnd int, utxoCount int64, envID []byte) {
 		agentID := r.AgentID
 
@@ -0,0 +1,218 @@
+package testruns
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator"
+)
+
+// regressionKey returns the hash of the test run's normalized configuration
+// without its commit, which identifies the test runs that are comparable
+// between commits
+func regressionKey(tr *common.TestRun) []byte {
+	cfg := tr.NormalizedConfig()
+	cfg.CommitHash = ""
+	return cfg.Hash()
+}
+
+// regressionRun is a copy of the fields of a test run that the regression
+// detection uses, taken while holding testRunsLock
+type regressionRun struct {
+	run       *common.TestRun
+	result    *common.TestResult
+	started   time.Time
+	completed time.Time
+}
+
+// commitRuns holds the comparable completed test runs on a single commit
+type commitRuns struct {
+	commit string
+	// When the commit was made, or when the first of its test runs started
+	// if the commit is not in the history of the main branch
+	when time.Time
+	runs []regressionRun
+}
+
+// DetectRegression compares the result of the test run with the results of
+// the most recent earlier commit that has completed test runs with the same
+// configuration. Returns nil if regression detection is disabled or the test
+// run has no result
+func (t *TestRunManager) DetectRegression(
+	tr *common.TestRun,
+) *common.RegressionVerdict {
+	cfg := t.cfg.Regression
+	if cfg.Disabled || tr.Result == nil || tr.CommitHash == "" {
+		return nil
+	}
+
+	key := regressionKey(tr)
+	t.testRunsLock.Lock()
+	candidates := make([]regressionRun, 0)
+	for _, run := range t.testRuns {
+		if run.Status == common.TestRunStatusCompleted && run.Result != nil {
+			candidates = append(candidates, regressionRun{
+				run:       run,
+				result:    run.Result,
+				started:   run.Started,
+				completed: run.Completed,
+			})
+		}
+	}
+	self := regressionRun{
+		run:       tr,
+		result:    tr.Result,
+		started:   tr.Started,
+		completed: tr.Completed,
+	}
+	t.testRunsLock.Unlock()
+
+	byCommit := map[string]*commitRuns{}
+	for _, c := range candidates {
+		run := c.run
+		if run.ID != tr.ID && !bytes.Equal(regressionKey(run), key) {
+			continue
+		}
+		cr, ok := byCommit[run.CommitHash]
+		if !ok {
+			cr = &commitRuns{commit: run.CommitHash}
+			byCommit[run.CommitHash] = cr
+		}
+		cr.runs = append(cr.runs, c)
+	}
+	current, ok := byCommit[tr.CommitHash]
+	if !ok {
+		// The test run itself is not completed yet
+		current = &commitRuns{
+			commit: tr.CommitHash,
+			runs:   []regressionRun{self},
+		}
+		byCommit[tr.CommitHash] = current
+	}
+	for _, c := range byCommit {
+		t.prepareCommitRuns(c, tr.ID, cfg.MaxRuns)
+	}
+
+	var baseline *commitRuns
+	for _, c := range byCommit {
+		if c == current || !c.when.Before(current.when) {
+			continue
+		}
+		if baseline == nil || c.when.After(baseline.when) {
+			baseline = c
+		}
+	}
+
+	verdict := &common.RegressionVerdict{
+		Status:           common.RegressionNoBaseline,
+		BaselineRunIDs:   []string{},
+		RunIDs:           runIDs(current.runs),
+		ThresholdPercent: cfg.ThresholdPercent,
+		Significance:     cfg.Significance,
+		Metrics:          []common.RegressionMetric{},
+		Checked:          time.Now(),
+	}
+	if baseline == nil {
+		return verdict
+	}
+	verdict.BaselineCommit = baseline.commit
+	verdict.BaselineRunIDs = runIDs(baseline.runs)
+	verdict.Status, verdict.Metrics = common.CompareResults(
+		runResults(baseline.runs),
+		runResults(current.runs),
+		cfg.ThresholdPercent,
+		cfg.Significance,
+	)
+	return verdict
+}
+
+// prepareCommitRuns keeps only the most recent maxRuns test runs of the
+// commit, always including the test run with ID keepID, and determines when
+// the commit was made
+func (t *TestRunManager) prepareCommitRuns(
+	c *commitRuns,
+	keepID string,
+	maxRuns int,
+) {
+	sort.Slice(c.runs, func(i, j int) bool {
+		if c.runs[i].run.ID == keepID {
+			return true
+		}
+		if c.runs[j].run.ID == keepID {
+			return false
+		}
+		return c.runs[i].completed.After(c.runs[j].completed)
+	})
+	if len(c.runs) > maxRuns {
+		c.runs = c.runs[:maxRuns]
+	}
+
+	if when, ok := t.src.CommitTime(c.commit); ok {
+		c.when = when
+		return
+	}
+	for _, run := range c.runs {
+		if c.when.IsZero() || run.started.Before(c.when) {
+			c.when = run.started
+		}
+	}
+}
+
+func runIDs(runs []regressionRun) []string {
+	ret := make([]string, len(runs))
+	for i, run := range runs {
+		ret[i] = run.run.ID
+	}
+	return ret
+}
+
+func runResults(runs []regressionRun) []*common.TestResult {
+	ret := make([]*common.TestResult, len(runs))
+	for i, run := range runs {
+		ret[i] = run.result
+	}
+	return ret
+}
+
+// checkRegression runs the regression detection for a test run of which the
+// result just became available, records the verdict on the test run and
+// notifies the frontend
+func (t *TestRunManager) checkRegression(tr *common.TestRun) {
+	// A run that was aborted by a guard collapsed, its result says nothing
+	// about the commit
+	if tr.GuardReason != "" {
+		return
+	}
+	verdict := t.DetectRegression(tr)
+	if verdict == nil {
+		return
+	}
+	switch verdict.Status {
+	case common.RegressionDetected, common.RegressionSuspected:
+		logger.Warnf(
+			"Test run %s on commit %s: %s compared to commit %s",
+			tr.ID,
+			tr.CommitHash,
+			verdict.Status,
+			verdict.BaselineCommit,
+		)
+	default:
+		logger.Debugf(
+			"Test run %s on commit %s: %s",
+			tr.ID,
+			tr.CommitHash,
+			verdict.Status,
+		)
+	}
+	tr.Regression = verdict
+	t.PersistTestRun(tr)
+	t.ev <- coordinator.Event{
+		Type: coordinator.EventTypeTestRunRegressionVerdict,
+		Payload: coordinator.TestRunRegressionVerdictPayload{
+			TestRunID: tr.ID,
+			Verdict:   verdict,
+		},
+	}
+}
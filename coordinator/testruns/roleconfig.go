@@ -26,12 +26,22 @@ func (t *TestRunManager) GenerateConfig(
 		common.TestRunStatusRunning,
 		fmt.Sprintf("Generating config (Dummy=%t)", dummy),
 	)
+	return t.generateConfig(tr, dummy)
+}
+
+// generateConfig generates the configuration file without updating the
+// status of the test run, such that it can also be used for test runs that
+// are not scheduled
+func (t *TestRunManager) generateConfig(
+	tr *common.TestRun,
+	dummy bool,
+) ([]byte, error) {
 	if t.Is2PC(tr.Architecture) {
 		return t.GenerateConfigTwoPhase(tr, dummy)
 	} else if t.IsAtomizer(tr.Architecture) {
 		return t.GenerateConfigAtomizer(tr, dummy)
 	} else {
-		params, err := t.GenerateParams(tr)
+		params, err := t.GenerateParams(tr, dummy)
 		if err != nil {
 			return nil, err
 		}
@@ -51,16 +61,22 @@ var dummyIP = net.IP{0, 0, 0, 0}
 
 // GetAgentOrDummy returns an agent when dummy is false (or an error if it
 // doesn't exist) or a dummy agent when dummy is true. Used in config
-// generation prior to agents being launched.
+// generation prior to agents being launched. Placeholder agent IDs get a
+// distinct dummy IP address, such that it can be replaced by the actual host
+// afterwards (see ManualRunBundle)
 func (t *TestRunManager) GetAgentOrDummy(
 	agentID int32,
 	dummy bool,
 ) (*coordinator.ConnectedAgent, error) {
 	if dummy {
+		ip := dummyIP
+		if i, ok := placeholderIndex(agentID); ok {
+			ip = placeholderIP(i)
+		}
 		return &coordinator.ConnectedAgent{
 			SystemInfo: common.AgentSystemInfo{
 				PrivateIPs: []net.IP{
-					dummyIP,
+					ip,
 				},
 			},
 		}, nil
@@ -3,13 +3,11 @@ package testruns
 import (
 	"encoding/json"
 	"fmt"
-	"sort"
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
-	"github.com/mit-dci/opencbdc-tctl/logging"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
 )
 
 // ScheduleTestRun will add the given testrun to the set of queued testruns.
@@ -23,7 +21,7 @@ func (t *TestRunManager) ScheduleTestRun(tr *common.TestRun) {
 	var err error
 	tr.ID, err = common.RandomID(12)
 	if err != nil {
-		logging.Errorf("Error getting randomness user: %s", err.Error())
+		logger.Errorf("Error getting randomness user: %s", err.Error())
 		return
 	}
 
@@ -32,8 +30,78 @@ func (t *TestRunManager) ScheduleTestRun(tr *common.TestRun) {
 	tr.Started = time.Date(0001, 1, 1, 00, 00, 00, 00, time.UTC)
 	tr.Status = common.TestRunStatusQueued
 	tr.Details = ""
+	tr.GuardReason = ""
 	tr.ExecutedCommands = []*common.ExecutedCommand{}
 
+	applyRunDefaults(tr)
+
+	tr.TerminateChan = make(chan bool, 1)
+	tr.RetrySpawnChan = make(chan bool, 1)
+	t.testRuns = append(t.testRuns, tr)
+	t.ev <- coordinator.Event{
+		Type: coordinator.EventTypeTestRunCreated,
+		Payload: coordinator.TestRunCreatedPayload{
+			Data: tr,
+		},
+	}
+	t.PersistTestRun(tr)
+}
+
+// ScheduleTestRunSpec schedules the test run described by tr on behalf of the
+// user with the given thumbprint, expanding it into the individual runs if it
+// is a sweep or is repeated. It returns the ID of the sweep (empty for a
+// single run) and the IDs of the test runs that were scheduled, or a
+// ValidationFailedError without scheduling any if the test run is not valid
+func (t *TestRunManager) ScheduleTestRunSpec(
+	tr *common.TestRun,
+	createdBy string,
+) (string, []string, error) {
+	if errs := t.ValidateTestRunSpec(tr); len(errs) > 0 {
+		return "", nil, &ValidationFailedError{errs}
+	}
+	tr.CreatedByThumbprint = createdBy
+	tr.SweepID = ""
+
+	sweepID, err := common.RandomID(12)
+	if err != nil {
+		return "", nil, err
+	}
+	applySpecDefaults(tr)
+
+	runs := common.ExpandSweepRun(tr, sweepID)
+	ids := []string{}
+	for i := range runs {
+		t.ScheduleTestRun(runs[i])
+		ids = append(ids, runs[i].ID)
+		if tr.SweepOneAtATime {
+			break
+		}
+	}
+	if len(runs) == 1 && runs[0].SweepID == "" {
+		sweepID = ""
+	}
+	return sweepID, ids, nil
+}
+
+// applySpecDefaults initiates the fields of a test run specification that
+// apply to the sweep as a whole with their defaults if not set
+func applySpecDefaults(tr *common.TestRun) {
+	if tr.Repeat == 0 {
+		tr.Repeat = 1
+	}
+
+	if tr.WatchtowerErrorCacheSize == 0 {
+		tr.WatchtowerErrorCacheSize = 10000000
+	}
+
+	if tr.Sweep == "peak" {
+		tr.SweepOneAtATime = true
+	}
+}
+
+// applyRunDefaults initiates the fields of an individual test run with their
+// defaults if not set
+func applyRunDefaults(tr *common.TestRun) {
 	if tr.ArchiverLogLevel == "" {
 		tr.ArchiverLogLevel = "WARN"
 	}
@@ -55,17 +123,6 @@ func (t *TestRunManager) ScheduleTestRun(tr *common.TestRun) {
 	if tr.AgentRPCInstances <= 0 {
 		tr.AgentRPCInstances = 1
 	}
-
-	tr.TerminateChan = make(chan bool, 1)
-	tr.RetrySpawnChan = make(chan bool, 1)
-	t.testRuns = append(t.testRuns, tr)
-	t.ev <- coordinator.Event{
-		Type: coordinator.EventTypeTestRunCreated,
-		Payload: coordinator.TestRunCreatedPayload{
-			Data: tr,
-		},
-	}
-	t.PersistTestRun(tr)
 }
 
 // Scheduleris the main loop that checks if Queued testruns can commence
@@ -78,38 +135,32 @@ func (t *TestRunManager) Scheduler() {
 			// This is a janitor routine to look for running instances that are
 			// not associated with a running testrun - they might have been left
 			// running by mistake and should be terminated.
-			instances := t.awsm.RunningInstances()
-			killInstances := []*awsmgr.AwsInstance{}
-			for _, i := range instances {
-				testrunID := ""
-				for _, t := range i.Instance.Tags {
-					if *t.Key == "TestRunID" {
-						testrunID = *t.Value
-					}
-				}
-
+			machines := t.prov.Running()
+			killMachines := []string{}
+			for _, m := range machines {
+				testrunID := m.TestRunID
 				if testrunID != "" {
 					r, ok := t.GetTestRun(testrunID)
 					if !ok {
-						logging.Infof(
-							"Instance %s (region %s) is active for an unknown testrun %s - killing",
-							i.Instance.InstanceId,
-							i.Region,
+						logger.Infof(
+							"Machine %s (region %s) is active for an unknown testrun %s - killing",
+							m.ID,
+							m.Region,
 							testrunID,
 						)
-						killInstances = append(killInstances, i)
+						killMachines = append(killMachines, m.ID)
 					} else {
 						if r.Status != "Running" {
-							logging.Infof("Instance %s (region %s) is active for testrun %s with status %s - killing", i.Instance.InstanceId, i.Region, testrunID, r.Status)
-							killInstances = append(killInstances, i)
+							logger.Infof("Machine %s (region %s) is active for testrun %s with status %s - killing", m.ID, m.Region, testrunID, r.Status)
+							killMachines = append(killMachines, m.ID)
 						}
 					}
 				}
 			}
-			if len(killInstances) > 0 {
-				err := t.awsm.StopAgents(killInstances)
+			if len(killMachines) > 0 {
+				err := t.prov.Terminate(killMachines)
 				if err != nil {
-					logging.Warnf("Unable to stop agents: %v", err)
+					logger.Warnf("Unable to stop agents: %v", err)
 				}
 			}
 		}
@@ -122,7 +173,12 @@ func (t *TestRunManager) Scheduler() {
 			// results) while the test agents have already been shut down)
 			runningVCPUs := map[string]int32{}
 			runningAgents := 0
+			userAgents := map[string]int{}
+			pendingMachines := []provider.MachineRequest{}
 			var nextQueued []*common.TestRun
+			// The schedule reasons and budget warnings are sent after
+			// releasing the lock
+			events := []coordinator.Event{}
 			t.testRunsLock.Lock()
 			for _, tr := range t.testRuns {
 				if tr.Status == common.TestRunStatusRunning &&
@@ -137,78 +193,192 @@ func (t *TestRunManager) Scheduler() {
 						}
 					}
 					runningAgents += len(tr.Roles)
+					userAgents[tr.CreatedByThumbprint] += len(tr.Roles)
+					// Machines for running test runs that are not launched
+					// yet are no longer available to queued test runs
+					pendingMachines = append(
+						pendingMachines,
+						t.pendingMachineRequests(tr)...,
+					)
 				}
 			}
 
-			// Sort the queue by priority
-			queue := t.testRuns[:]
-			sort.Slice(queue, func(i, j int) bool {
-				return queue[i].Priority > queue[j].Priority
-			})
+			queue := []*common.TestRun{}
+			for _, tr := range t.testRuns {
+				if tr.Status != common.TestRunStatusQueued {
+					continue
+				}
 
-			for i, tr := range queue {
-				if tr.Status == common.TestRunStatusQueued {
+				// Test runs can depend on other test runs, in which case
+				// they wait until the conditions are met, and are canceled
+				// when they can no longer be met
+				depState, depReason := t.checkDependencies(tr)
+				if depState == common.DependencyFailed {
+					t.UpdateStatus(
+						tr,
+						common.TestRunStatusCanceled,
+						fmt.Sprintf("Canceled: %s", depReason),
+					)
+					continue
+				}
+				if depState == common.DependencyPending {
+					t.setScheduleReason(
+						tr,
+						fmt.Sprintf("Waiting: %s", depReason),
+						&events,
+					)
+					continue
+				}
 
-					// Test runs (specifically: time sweeps) can have a
-					// configuration disallowing running the testrun before a
-					// certain time - in which case this test run shouldn't be
-					// considered for running
-					canRun := tr.DontRunBefore.IsZero() ||
-						tr.DontRunBefore.Before(time.Now())
-					if !canRun {
-						continue
-					}
+				// Test runs (specifically: time sweeps) can have a
+				// configuration disallowing running the testrun before a
+				// certain time - in which case this test run shouldn't be
+				// considered for running
+				if !tr.DontRunBefore.IsZero() &&
+					tr.DontRunBefore.After(time.Now()) {
+					t.setScheduleReason(
+						tr,
+						fmt.Sprintf(
+							"Waiting: not allowed to run before %s",
+							tr.DontRunBefore.Format(time.RFC3339),
+						),
+						&events,
+					)
+					continue
+				}
+				queue = append(queue, tr)
+			}
 
-					// See how many VCPUs are needed for this testrun, and if
-					// they fall within our allowed quota. If not, we cannot
-					// consider this test run for execution
-					requiredVCPUsForTestRun := t.GetRequiredVCPUs(tr)
-					for k, v := range requiredVCPUsForTestRun {
-						cur, ok := runningVCPUs[k]
-						if !ok {
-							if v > t.awsm.GetVCPULimit(k) {
-								canRun = false
-							}
-						} else {
-							if cur+v > t.awsm.GetVCPULimit(k) {
-								canRun = false
-							}
+			// Consider the queued test runs in fair-share order: by priority,
+			// and taking turns between the users at equal priority
+			for len(queue) > 0 {
+				var tr *common.TestRun
+				tr, queue = t.nextFairShare(queue, userAgents)
+				user := tr.CreatedByThumbprint
+
+				// See how many VCPUs are needed for this testrun, and if
+				// they fall within our allowed quota. If not, we cannot
+				// consider this test run for execution
+				canRun := true
+				requiredVCPUsForTestRun := t.GetRequiredVCPUs(tr)
+				for k, v := range requiredVCPUsForTestRun {
+					cur, ok := runningVCPUs[k]
+					if !ok {
+						if v > t.prov.VCPULimit(k) {
+							canRun = false
+						}
+					} else {
+						if cur+v > t.prov.VCPULimit(k) {
+							canRun = false
 						}
 					}
-					if !canRun {
-						logging.Infof(
-							"Can't start test run %s because there's not enough capacity",
-							tr.ID,
-						)
-						continue
-					}
+				}
+				if !canRun {
+					logger.Debugf(
+						"Can't start test run %s because there's not enough capacity",
+						tr.ID,
+					)
+					t.setScheduleReason(
+						tr,
+						"Waiting: not enough vCPU capacity in the AWS account",
+						&events,
+					)
+					continue
+				}
 
-					// Check if executing this test would put the total number
-					// of running agents over the configured limit. If this is
-					// the case, we cannot consider this test for execution.
-					if runningAgents+len(tr.Roles) > t.config.MaxAgents {
-						logging.Infof(
-							"Can't start test run %s because of the max agent limit",
-							tr.ID,
-						)
-						continue
-					}
+				// Check if the provider can supply the machines this test
+				// run needs on top of those of the runs started before it,
+				// for instance enough idle hosts in a static pool
+				requiredMachines := append(
+					append([]provider.MachineRequest{}, pendingMachines...),
+					t.pendingMachineRequests(tr)...,
+				)
+				err := t.prov.Available(requiredMachines)
+				if err != nil {
+					logger.Debugf(
+						"Can't start test run %s because the machines are not available: %v",
+						tr.ID,
+						err,
+					)
+					t.setScheduleReason(
+						tr,
+						fmt.Sprintf("Waiting: %v", err),
+						&events,
+					)
+					continue
+				}
 
-					// It looks like we can start this test run within all
-					// limiting parameters, so let's add it to the array of
-					// test runs to execute, and update the tallied vCPU and
-					// agent count for considering the next run to queue.
-					for k, v := range requiredVCPUsForTestRun {
-						cur, ok := runningVCPUs[k]
-						if !ok {
-							runningVCPUs[k] = v
-						} else {
-							runningVCPUs[k] = cur + v
-						}
+				// Check if executing this test would put the total number
+				// of running agents over the configured limit. If this is
+				// the case, we cannot consider this test for execution.
+				if runningAgents+len(tr.Roles) > t.config.MaxAgents {
+					logger.Debugf(
+						"Can't start test run %s because of the max agent limit",
+						tr.ID,
+					)
+					t.setScheduleReason(tr, fmt.Sprintf(
+						"Waiting: needs %d agents, %d of %d in use",
+						len(tr.Roles),
+						runningAgents,
+						t.config.MaxAgents,
+					), &events)
+					continue
+				}
+
+				// Check if executing this test would put the user that
+				// created it over their quota of concurrent agents
+				quota := t.config.FairShare.quota(user)
+				if quota > 0 && userAgents[user]+len(tr.Roles) > quota {
+					logger.Debugf(
+						"Can't start test run %s because of the user's agent quota",
+						tr.ID,
+					)
+					t.setScheduleReason(tr, fmt.Sprintf(
+						"Waiting: needs %d agents, user quota is %d with %d in use",
+						len(tr.Roles),
+						quota,
+						userAgents[user],
+					), &events)
+					continue
+				}
+
+				// Check if the estimated cost of this test run fits in the
+				// remaining monthly budgets, unless an admin overrode them
+				if reason := t.checkBudget(tr, &events); reason != "" {
+					logger.Debugf(
+						"Can't start test run %s because of the budget",
+						tr.ID,
+					)
+					t.setScheduleReason(
+						tr,
+						fmt.Sprintf("Waiting: %s", reason),
+						&events,
+					)
+					continue
+				}
+
+				// It looks like we can start this test run within all
+				// limiting parameters, so let's add it to the array of
+				// test runs to execute, and update the tallied vCPU and
+				// agent count for considering the next run to queue.
+				t.setScheduleReason(tr, fmt.Sprintf(
+					"Started: turn at priority %d with %d agents in use by user (weight %g)",
+					tr.Priority,
+					userAgents[user],
+					t.config.FairShare.weight(user),
+				), &events)
+				for k, v := range requiredVCPUsForTestRun {
+					cur, ok := runningVCPUs[k]
+					if !ok {
+						runningVCPUs[k] = v
+					} else {
+						runningVCPUs[k] = cur + v
 					}
-					runningAgents += len(tr.Roles)
-					nextQueued = append(nextQueued, t.testRuns[i])
 				}
+				runningAgents += len(tr.Roles)
+				userAgents[user] += len(tr.Roles)
+				pendingMachines = requiredMachines
+				nextQueued = append(nextQueued, tr)
 			}
 
 			// If we have test runs to execute, execute them each in their own
@@ -224,6 +394,9 @@ func (t *TestRunManager) Scheduler() {
 				}
 			}
 			t.testRunsLock.Unlock()
+			for _, ev := range events {
+				t.ev <- ev
+			}
 		}
 		time.Sleep(time.Second * 2)
 	}
@@ -236,11 +409,11 @@ func (t *TestRunManager) Reschedule(tr *common.TestRun) {
 	var newTr common.TestRun
 	b, err := json.Marshal(tr)
 	if err != nil {
-		logging.Warnf("Could not marshal testruns: %v", err)
+		logger.Warnf("Could not marshal testruns: %v", err)
 	}
 	err = json.Unmarshal(b, &newTr)
 	if err != nil {
-		logging.Warnf("Could not unmarshal testruns: %v", err)
+		logger.Warnf("Could not unmarshal testruns: %v", err)
 	}
 
 	for i := range newTr.Roles {
@@ -259,15 +432,15 @@ func (t *TestRunManager) Reschedule(tr *common.TestRun) {
 	}
 }
 
-// GetRequiredVCPUs will use the region and VCPU count of the chosen launch
+// GetRequiredVCPUs will use the region and VCPU count of the chosen machine
 // templates for all the roles in the test run to build a total tally map of
 // region => vcpu_count and return it.
 func (t *TestRunManager) GetRequiredVCPUs(tr *common.TestRun) map[string]int32 {
 	ret := map[string]int32{}
 	for i := range tr.Roles {
-		lt, err := t.awsm.GetLaunchTemplate(tr.Roles[i].AwsLaunchTemplateID)
+		lt, err := t.prov.Template(tr.Roles[i].AwsLaunchTemplateID)
 		if err == nil {
-			key := fmt.Sprintf("%s-ondem", lt.Region)
+			key := lt.Region
 			cur, ok := ret[key]
 			if ok {
 				ret[key] = cur + lt.VCPUCount
@@ -0,0 +1,207 @@
+package testruns
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+	"github.com/mit-dci/opencbdc-tctl/coordinator"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
+)
+
+var fakeTemplates = []provider.MachineTemplate{
+	{ID: "small", Region: "us-east-1", InstanceType: "c5n.large", VCPUCount: 2},
+	{ID: "large", Region: "us-east-1", InstanceType: "c5n.2xlarge", VCPUCount: 8},
+	{ID: "eu", Region: "eu-west-1", InstanceType: "c5n.large", VCPUCount: 2},
+}
+
+// newFakeManager returns a test run manager on a fake provider. The events
+// it sends are dropped
+func newFakeManager(t *testing.T) (*TestRunManager, *provider.FakeProvider) {
+	t.Helper()
+	prov := provider.NewFakeProvider(fakeTemplates, map[string]int32{
+		"us-east-1": 16,
+		"eu-west-1": 4,
+	})
+	ev := make(chan coordinator.Event)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-ev:
+			case <-done:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(done) })
+	return &TestRunManager{prov: prov, ev: ev}, prov
+}
+
+// fakeTestRun returns a test run with a role for each of the templates, where
+// an empty template is a role on an agent that was chosen when scheduling
+func fakeTestRun(templates ...string) *common.TestRun {
+	tr := &common.TestRun{
+		ID:            "run",
+		TerminateChan: make(chan bool, 1),
+	}
+	for i, tpl := range templates {
+		r := &common.TestRunRole{
+			Role:                common.SystemRoleShard,
+			Index:               i,
+			AgentID:             -1,
+			AwsLaunchTemplateID: tpl,
+		}
+		if tpl == "" {
+			r.AgentID = int32(100 + i)
+		}
+		tr.Roles = append(tr.Roles, r)
+	}
+	return tr
+}
+
+func runningIDs(prov *provider.FakeProvider) []string {
+	ids := []string{}
+	for _, m := range prov.Running() {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestGetRequiredVCPUs(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []string
+		want      map[string]int32
+	}{
+		{
+			name:      "per region",
+			templates: []string{"small", "large", "eu", "eu"},
+			want:      map[string]int32{"us-east-1": 10, "eu-west-1": 4},
+		},
+		{
+			name:      "chosen agents and unknown templates need nothing",
+			templates: []string{"", "missing", "small"},
+			want:      map[string]int32{"us-east-1": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newFakeManager(t)
+			got := m.GetRequiredVCPUs(fakeTestRun(tt.templates...))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRequiredVCPUs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingMachineRequests(t *testing.T) {
+	m, prov := newFakeManager(t)
+	tr := fakeTestRun("small", "", "large", "eu")
+	tr.Roles[3].AwsAgentInstanceId = "fake-launched"
+
+	requests := m.pendingMachineRequests(tr)
+	templates := []string{}
+	for _, r := range requests {
+		templates = append(templates, r.TemplateID)
+	}
+	want := []string{"small", "large"}
+	if !reflect.DeepEqual(templates, want) {
+		t.Errorf("pending templates = %v, want %v", templates, want)
+	}
+	if err := prov.Available(requests); err != nil {
+		t.Errorf("Available() = %v", err)
+	}
+}
+
+func TestSpawnAWSInstances(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []string
+		// Machines that were launched for the roles before, by role index
+		launched   map[int]string
+		keep       bool
+		launchErr  error
+		failAfter  int
+		wantOK     bool
+		wantStatus common.TestRunStatus
+		// The fake machines that run afterwards, and for which roles
+		wantRunning []string
+		wantRoles   []string
+	}{
+		{
+			name:        "launches the roles in order",
+			templates:   []string{"small", "", "large"},
+			wantOK:      true,
+			wantRunning: []string{"fake-1", "fake-2"},
+			wantRoles:   []string{"fake-1", "", "fake-2"},
+		},
+		{
+			name:        "respawns roles that did not come online",
+			templates:   []string{"small", "small"},
+			launched:    map[int]string{1: "small"},
+			wantOK:      true,
+			wantRunning: []string{"fake-2", "fake-3"},
+			wantRoles:   []string{"fake-2", "fake-3"},
+		},
+		{
+			name:        "keeps timed out agents if asked to",
+			templates:   []string{"small"},
+			launched:    map[int]string{0: "small"},
+			keep:        true,
+			wantOK:      true,
+			wantRunning: []string{"fake-1", "fake-2"},
+			wantRoles:   []string{"fake-2"},
+		},
+		{
+			name:        "stops the machines that launched on a failure",
+			templates:   []string{"small", "small", "large"},
+			launchErr:   errors.New("out of capacity"),
+			failAfter:   2,
+			wantOK:      false,
+			wantStatus:  common.TestRunStatusFailed,
+			wantRunning: []string{},
+			wantRoles:   []string{"", "", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, prov := newFakeManager(t)
+			tr := fakeTestRun(tt.templates...)
+			for i, tpl := range tt.launched {
+				machines, errs := prov.Launch(
+					[]provider.MachineRequest{{TemplateID: tpl}},
+					tr.ID,
+				)
+				if len(errs) > 0 {
+					t.Fatal(errs)
+				}
+				tr.Roles[i].AwsAgentInstanceId = machines[0].ID
+			}
+			tr.KeepTimedOutAgents = tt.keep
+			prov.LaunchErr = tt.launchErr
+			prov.FailAfter = tt.failAfter
+
+			if ok := m.SpawnAWSInstances(tr); ok != tt.wantOK {
+				t.Errorf("SpawnAWSInstances() = %v, want %v", ok, tt.wantOK)
+			}
+			if tr.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", tr.Status, tt.wantStatus)
+			}
+			if got := runningIDs(prov); !reflect.DeepEqual(got, tt.wantRunning) {
+				t.Errorf("running = %v, want %v", got, tt.wantRunning)
+			}
+			roles := []string{}
+			for _, r := range tr.Roles {
+				roles = append(roles, r.AwsAgentInstanceId)
+			}
+			if !reflect.DeepEqual(roles, tt.wantRoles) {
+				t.Errorf("role machines = %v, want %v", roles, tt.wantRoles)
+			}
+		})
+	}
+}
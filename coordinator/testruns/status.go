@@ -51,6 +51,8 @@ func (t *TestRunManager) UpdateStatus(
 			details,
 		)
 	}
+	completed := newStatus == common.TestRunStatusCompleted &&
+		tr.Status != newStatus
 	tr.Status = newStatus
 
 	// Set start/complete time if the time is Zero and the status indicates that
@@ -58,7 +60,8 @@ func (t *TestRunManager) UpdateStatus(
 	if newStatus == common.TestRunStatusRunning && tr.Started.IsZero() {
 		tr.Started = time.Now()
 	}
-	if (newStatus == common.TestRunStatusFailed || newStatus == common.TestRunStatusCompleted) &&
+	if (newStatus == common.TestRunStatusFailed || newStatus == common.TestRunStatusCompleted ||
+		newStatus == common.TestRunStatusGuardTripped) &&
 		tr.Completed.IsZero() {
 		tr.Completed = time.Now()
 	}
@@ -66,6 +69,11 @@ func (t *TestRunManager) UpdateStatus(
 		tr.Details = details
 	}
 
+	// Calibrate the runtime estimates with the completed test run
+	if completed {
+		t.addCalibrationSample(tr)
+	}
+
 	// Send the update over the realtime channel
 	t.ev <- coordinator.Event{
 		Type: coordinator.EventTypeTestRunStatusChanged,
@@ -88,7 +96,7 @@ func (t *TestRunManager) UpdateStatus(
 // the test had failed. Lastly, it will reschedule the test run if it was
 // configure to be rescheduled on failures
 func (t *TestRunManager) FailTestRun(tr *common.TestRun, err error) {
-	t.WriteLog(tr, "Test run failed: [%s]", err.Error())
+	t.WriteLogError(tr, "Test run failed: [%s]", err.Error())
 
 	// If the test run has roles running in AWS, we need to terminate all of
 	// them when the test fails.
@@ -119,7 +127,7 @@ func (t *TestRunManager) FailTestRun(tr *common.TestRun, err error) {
 	if len(tr.PendingResultDownloads) > 0 {
 		s3Err := t.awsm.DownloadMultipleFromS3(tr.PendingResultDownloads)
 		if s3Err != nil {
-			t.WriteLog(tr, "Failed to download outputs from S3: %v", s3Err)
+			t.WriteLogWarning(tr, "Failed to download outputs from S3: %v", s3Err)
 		}
 	}
 
@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 )
 
 // ShouldTerminate does a non-blocking read on the TerminateChan of the given
@@ -32,17 +31,28 @@ func (t *TestRunManager) TerminateIfNeeded(
 	failures chan *common.ExecutedCommand,
 ) bool {
 	if t.ShouldTerminate(tr) {
+		// The termination is either requested by the user or by one of the
+		// guards of the test run
+		status := common.TestRunStatusAborted
+		details := "Aborted by user request"
+		if reason := t.guardViolation(tr); reason != "" {
+			tr.GuardReason = reason
+		}
+		if tr.GuardReason != "" {
+			status = common.TestRunStatusGuardTripped
+			details = guardDetails(tr)
+		}
 		t.UpdateStatus(
 			tr,
 			common.TestRunStatusRunning,
-			"Aborted by user request, killing all commands",
+			fmt.Sprintf("%s, killing all commands", details),
 		)
 		if len(allCmds) > 0 {
 			err := t.BreakAndTerminateAllCmds(tr, allCmds)
 			if err != nil {
 				// No need to return it, we're going to abort the testrun any
 				// way
-				logging.Warnf("Error terminating commands: %v", err)
+				logger.Warnf("Error terminating commands: %v", err)
 			}
 
 			time.Sleep(5 * time.Second)
@@ -60,15 +70,11 @@ func (t *TestRunManager) TerminateIfNeeded(
 				// No need to return it, we're going to abort the testrun any
 				// way
 				// Cleanup will be retried by the check loop in Scheduler()
-				logging.Warnf("Error killing AWS agents: %v", err2)
+				logger.Warnf("Error killing AWS agents: %v", err2)
 			}
 		}
 
-		t.UpdateStatus(
-			tr,
-			common.TestRunStatusAborted,
-			"Aborted by user request",
-		)
+		t.UpdateStatus(tr, status, details)
 
 		return true
 	}
@@ -78,7 +84,7 @@ func (t *TestRunManager) TerminateIfNeeded(
 		case fail := <-failures:
 			err := t.HandleCommandFailure(tr, allCmds, envs, fail)
 			if err != nil {
-				logging.Errorf("Error handling command failure: %v", err)
+				logger.Errorf("Error handling command failure: %v", err)
 			}
 			t.FailTestRun(
 				tr,
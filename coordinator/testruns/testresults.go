@@ -1,15 +1,17 @@
 package testruns
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
-	"github.com/mit-dci/opencbdc-tctl/logging"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/results"
 )
 
 // resultCalculation is the struct that's used to queue a particular testrun's
@@ -32,85 +34,38 @@ const ParallelResultCalculation = 4
 // ResultCalculator is the main processor for the resultCalculationChan. It will
 // be started `ParallelResultCalculation` times in the background and read from
 // the channel to see which result calculations need to be performed. Once a
-// calculation request has been read from the channel, it will use the result
-// calculation python script to produce the results.
+// calculation request has been read from the channel, it will produce the
+// results with the engine set in the configuration: the result calculation
+// python script, the native calculation, or both to compare them.
 func (t *TestRunManager) ResultCalculator() {
 	for job := range t.resultCalculationChan {
 		tr := job.calculateForRun
-		logging.Debugf("Calculating test run %s results", tr.ID)
+		logger.Debugf("Calculating test run %s results", tr.ID)
 
-		// The result calculation script is expected to be placed next to
-		// the main coordinator assembly
-		exeDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-		if err != nil {
-			if job.responseChan != nil {
-				job.responseChan <- err
-			}
-			continue
-		}
-		calcScript := filepath.Join(exeDir, "calculate_results.py")
-
-		// Create the `plots` subdirectory of the testrun folder where the
-		// time series, latency distribution and throughput distribution plots
-		// will be written to by the calculation script
 		testRunDir := filepath.Join(
 			common.DataDir(),
 			fmt.Sprintf("testruns/%s", tr.ID),
 		)
-		err = os.MkdirAll(filepath.Join(testRunDir, "plots"), 0755)
-		if err != nil && !errors.Is(err, os.ErrExist) {
-			logging.Errorf("Error creating plots dir: %v", err)
-		}
-
-		// Build the command to execute
-		cmd := exec.Command("python3", calcScript)
-
-		// Build the environment variables to use for execution, which we
-		// base on the trimming parameters set for the test run
-		cmd.Env = os.Environ()
-		cmd.Env = append(
-			cmd.Env,
-			fmt.Sprintf("TRIM_SAMPLES=%d", tr.TrimSamplesAtStart),
-		)
-		cmd.Env = append(
-			cmd.Env,
-			fmt.Sprintf("BLOCK_TIME=%d", tr.TargetBlockInterval),
-		)
-		trimZeroes := 1
-		if !tr.TrimZeroesAtStart {
-			trimZeroes = 0
-		}
-		trimZeroesEnd := 1
-		if !tr.TrimZeroesAtEnd {
-			trimZeroesEnd = 0
+		var err error
+		switch t.cfg.Results.Engine {
+		case "go":
+			err = t.calculateResultsNative(tr, testRunDir)
+		case "parity":
+			err = t.calculateResultsScript(tr, testRunDir)
+			if err == nil {
+				t.checkResultParity(tr, testRunDir)
+			}
+		default:
+			err = t.calculateResultsScript(tr, testRunDir)
 		}
-		cmd.Env = append(
-			cmd.Env,
-			fmt.Sprintf("TRIM_ZEROES_START=%d", trimZeroes),
-		)
-		cmd.Env = append(
-			cmd.Env,
-			fmt.Sprintf("TRIM_ZEROES_END=%d", trimZeroesEnd),
-		)
-		cmd.Dir = testRunDir
-
-		// Execute the calculation script
-		out, err := cmd.CombinedOutput()
 		if err != nil {
 			if job.responseChan != nil {
 				job.responseChan <- err
 			}
-			logging.Errorf(
-				"Could not calculate result for testrun %s: %v",
-				tr.ID,
-				err,
-			)
-			logging.Infof("Result calculation output:\r\n%s", string(out))
 			continue
 		}
-		logging.Infof("Result calculation output:\r\n%s", string(out))
 
-		// The python script wrote the results to the results.json file. We
+		// The calculation wrote the results to the results.json file. We
 		// load it into the common.TestRun.Results property by using the
 		// LoadTestResult method
 		t.LoadTestResult(tr)
@@ -118,7 +73,7 @@ func (t *TestRunManager) ResultCalculator() {
 		// If ShouldCalculateResults still returns true, this means the
 		// calculation failed. Log that.
 		if t.ShouldCalculateResults(tr) {
-			logging.Errorf(
+			logger.Errorf(
 				"Test results are still empty after loading %s: %v",
 				tr.ID,
 				tr.Result,
@@ -133,6 +88,9 @@ func (t *TestRunManager) ResultCalculator() {
 					Result:    tr.Result,
 				},
 			}
+
+			// Compare the result with earlier commits
+			t.checkRegression(tr)
 		}
 		// If there was a response chan, signal the succesful completion of the
 		// result calculation
@@ -142,6 +100,172 @@ func (t *TestRunManager) ResultCalculator() {
 	}
 }
 
+// calculateResultsScript calculates the results of the test run and plots
+// them using the result calculation python script
+func (t *TestRunManager) calculateResultsScript(
+	tr *common.TestRun,
+	testRunDir string,
+) error {
+	// The result calculation script is expected to be placed next to
+	// the main coordinator assembly
+	exeDir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	if err != nil {
+		return err
+	}
+	calcScript := filepath.Join(exeDir, "calculate_results.py")
+
+	// Create the `plots` subdirectory of the testrun folder where the
+	// time series, latency distribution and throughput distribution plots
+	// will be written to by the calculation script
+	err = os.MkdirAll(filepath.Join(testRunDir, "plots"), 0755)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		logger.Errorf("Error creating plots dir: %v", err)
+	}
+
+	// Build the command to execute
+	cmd := exec.Command("python3", calcScript)
+
+	// Build the environment variables to use for execution, which we
+	// base on the trimming parameters set for the test run
+	cmd.Env = os.Environ()
+	cmd.Env = append(
+		cmd.Env,
+		fmt.Sprintf("TRIM_SAMPLES=%d", tr.TrimSamplesAtStart),
+	)
+	cmd.Env = append(
+		cmd.Env,
+		fmt.Sprintf("BLOCK_TIME=%d", tr.TargetBlockInterval),
+	)
+	trimZeroes := 1
+	if !tr.TrimZeroesAtStart {
+		trimZeroes = 0
+	}
+	trimZeroesEnd := 1
+	if !tr.TrimZeroesAtEnd {
+		trimZeroesEnd = 0
+	}
+	cmd.Env = append(
+		cmd.Env,
+		fmt.Sprintf("TRIM_ZEROES_START=%d", trimZeroes),
+	)
+	cmd.Env = append(
+		cmd.Env,
+		fmt.Sprintf("TRIM_ZEROES_END=%d", trimZeroesEnd),
+	)
+	cmd.Dir = testRunDir
+
+	// Execute the calculation script
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		logger.Errorf(
+			"Could not calculate result for testrun %s: %v",
+			tr.ID,
+			err,
+		)
+		logger.Infof("Result calculation output:\r\n%s", string(out))
+		return err
+	}
+	logger.Infof("Result calculation output:\r\n%s", string(out))
+	return nil
+}
+
+// calculateResultsNative calculates the results of the test run in Go and
+// writes them to the results.json file, without plotting them
+func (t *TestRunManager) calculateResultsNative(
+	tr *common.TestRun,
+	testRunDir string,
+) error {
+	res, err := results.Calculate(
+		filepath.Join(testRunDir, "outputs"),
+		results.OptionsFor(tr),
+	)
+	if err != nil {
+		logger.Errorf(
+			"Could not calculate result for testrun %s: %v",
+			tr.ID,
+			err,
+		)
+		return err
+	}
+	return results.Write(
+		filepath.Join(
+			testRunDir,
+			fmt.Sprintf("results%d.json", TestResultVersion),
+		),
+		res,
+	)
+}
+
+// resultParityReport is written to results-parity.json when comparing the
+// results of the python script with those of the native calculation
+type resultParityReport struct {
+	Error       string               `json:"error,omitempty"`
+	Native      *common.TestResult   `json:"native"`
+	Differences []results.Difference `json:"differences"`
+}
+
+// checkResultParity calculates the results of the test run natively and
+// compares them with the results the python script wrote. The differences
+// are logged and written to results-parity.json in the test run's directory
+func (t *TestRunManager) checkResultParity(
+	tr *common.TestRun,
+	testRunDir string,
+) {
+	report := resultParityReport{Differences: []results.Difference{}}
+	native, err := results.Calculate(
+		filepath.Join(testRunDir, "outputs"),
+		results.OptionsFor(tr),
+	)
+	if err != nil {
+		report.Error = err.Error()
+		logger.Warnf(
+			"Native result calculation for testrun %s failed: %v",
+			tr.ID,
+			err,
+		)
+	} else {
+		report.Native = native
+		var script common.TestResult
+		b, err := ioutil.ReadFile(filepath.Join(
+			testRunDir,
+			fmt.Sprintf("results%d.json", TestResultVersion),
+		))
+		if err == nil {
+			err = json.Unmarshal(b, &script)
+		}
+		if err != nil {
+			report.Error = err.Error()
+			logger.Warnf(
+				"Unable to read script results of testrun %s: %v",
+				tr.ID,
+				err,
+			)
+		} else {
+			report.Differences = results.Compare(&script, native)
+		}
+	}
+	for _, d := range report.Differences {
+		logger.Warnf(
+			"Native result of testrun %s differs in %s: %g (script: %g)",
+			tr.ID,
+			d.Field,
+			d.Actual,
+			d.Expected,
+		)
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(
+			filepath.Join(testRunDir, "results-parity.json"),
+			b,
+			0644,
+		)
+	}
+	if err != nil {
+		logger.Warnf("Unable to write result parity of %s: %v", tr.ID, err)
+	}
+}
+
 // CalculateResults will enqueue the result calculation if needed onto the job
 // channel and await its completion, returning the result. Use `recalc` set to
 // `true` to force calculation even if results are already present
@@ -10,6 +10,8 @@ import (
 	"github.com/mit-dci/opencbdc-tctl/coordinator"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/agents"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/awsmgr"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/config"
+	"github.com/mit-dci/opencbdc-tctl/coordinator/provider"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
 )
 
@@ -24,7 +26,9 @@ type TestRunManager struct {
 	ev                    chan coordinator.Event
 	am                    *agents.AgentsManager
 	awsm                  *awsmgr.AwsManager
+	prov                  provider.Provider
 	src                   *sources.SourcesManager
+	cfg                   *config.Config
 	commitHash            string
 	testRuns              []*common.TestRun // TODO: this should become persistent
 	testRunsLock          sync.Mutex
@@ -33,6 +37,11 @@ type TestRunManager struct {
 	config                *TestManagerConfig
 	resultCalculationChan chan resultCalculation
 	pendingBinaryUploads  sync.Map
+	budget                *budgetTracker
+	live                  sync.Map
+	// The cached estimator of the runtime of test runs
+	estimator     *RuntimeEstimator
+	estimatorLock sync.Mutex
 }
 
 func NewTestRunManager(
@@ -41,6 +50,8 @@ func NewTestRunManager(
 	src *sources.SourcesManager,
 	ev chan coordinator.Event,
 	awsm *awsmgr.AwsManager,
+	prov provider.Provider,
+	cfg *config.Config,
 	commitHash string,
 ) (*TestRunManager, error) {
 	tr := &TestRunManager{
@@ -57,8 +68,12 @@ func NewTestRunManager(
 		testRuns:             []*common.TestRun{},
 		testRunsLock:         sync.Mutex{},
 		awsm:                 awsm,
+		prov:                 prov,
+		cfg:                  cfg,
 		commitHash:           commitHash,
 		pendingBinaryUploads: sync.Map{},
+		budget:               &budgetTracker{warned: map[string]bool{}},
+		live:                 sync.Map{},
 	}
 	err := tr.LoadConfig()
 	if err != nil {
@@ -13,7 +13,6 @@ import (
 
 	"github.com/mit-dci/opencbdc-tctl/common"
 	"github.com/mit-dci/opencbdc-tctl/coordinator/sources"
-	"github.com/mit-dci/opencbdc-tctl/logging"
 	"github.com/mit-dci/opencbdc-tctl/wire"
 )
 
@@ -122,8 +121,8 @@ func (t *TestRunManager) CopyOutputs(
 					&wire.UploadFileToS3RequestMsg{
 						EnvironmentID: envs[role.AgentID],
 						SourcePath:    f,
-						TargetRegion:  os.Getenv("AWS_REGION"),
-						TargetBucket:  os.Getenv("OUTPUTS_S3_BUCKET"),
+						TargetRegion:  t.cfg.AWS.Region,
+						TargetBucket:  t.cfg.AWS.OutputsBucket,
 						TargetPath:    targetPath,
 					},
 					3*time.Minute,
@@ -133,7 +132,7 @@ func (t *TestRunManager) CopyOutputs(
 					// Watchtower CLI temporarily ignored due to new tx_samples
 					// usage (optional)
 					if ignoreErrors || ignoreFile {
-						logging.Warnf(
+						logger.Warnf(
 							"Ignoring error while copying file %s from agent %d: %v",
 							f,
 							role.AgentID,
@@ -155,8 +154,8 @@ func (t *TestRunManager) CopyOutputs(
 							f,
 						),
 					),
-					SourceRegion: os.Getenv("AWS_REGION"),
-					SourceBucket: os.Getenv("OUTPUTS_S3_BUCKET"),
+					SourceRegion: t.cfg.AWS.Region,
+					SourceBucket: t.cfg.AWS.OutputsBucket,
 					SourcePath:   targetPath,
 					Retries:      10,
 				})
@@ -243,8 +242,8 @@ func (t *TestRunManager) GetPerformanceProfiles(
 					&wire.UploadFileToS3RequestMsg{
 						EnvironmentID: envs[cmd.agentID],
 						SourcePath:    f,
-						TargetRegion:  os.Getenv("AWS_REGION"),
-						TargetBucket:  os.Getenv("OUTPUTS_S3_BUCKET"),
+						TargetRegion:  t.cfg.AWS.Region,
+						TargetBucket:  t.cfg.AWS.OutputsBucket,
 						TargetPath: fmt.Sprintf(
 							"testruns/%s/performanceprofiles/%s",
 							tr.ID,
@@ -266,8 +265,8 @@ func (t *TestRunManager) GetPerformanceProfiles(
 				allDownloadsLock.Lock()
 				allDownloads = append(allDownloads, common.S3Download{
 					TargetPath:   filepath.Join(path, f),
-					SourceRegion: os.Getenv("AWS_REGION"),
-					SourceBucket: os.Getenv("OUTPUTS_S3_BUCKET"),
+					SourceRegion: t.cfg.AWS.Region,
+					SourceBucket: t.cfg.AWS.OutputsBucket,
 					SourcePath: fmt.Sprintf(
 						"testruns/%s/performanceprofiles/%s",
 						tr.ID,
@@ -292,9 +291,9 @@ func (t *TestRunManager) GetPerformanceProfiles(
 	wg.Wait()
 	if len(errs) > 0 {
 		for _, e := range errs {
-			logging.Errorf("%v", e)
+			logger.Errorf("%v", e)
 		}
-		logging.Warnf(
+		logger.Warnf(
 			"%d errors occurred while copying performance data",
 			len(errs),
 		)
@@ -357,8 +356,8 @@ func (t *TestRunManager) GetLogFiles(
 					&wire.UploadFileToS3RequestMsg{
 						EnvironmentID: envs[cmd.agentID],
 						SourcePath:    f,
-						TargetRegion:  os.Getenv("AWS_REGION"),
-						TargetBucket:  os.Getenv("OUTPUTS_S3_BUCKET"),
+						TargetRegion:  t.cfg.AWS.Region,
+						TargetBucket:  t.cfg.AWS.OutputsBucket,
 						TargetPath: fmt.Sprintf(
 							"testruns/%s/logs/%s",
 							tr.ID,
@@ -380,8 +379,8 @@ func (t *TestRunManager) GetLogFiles(
 				allDownloadsLock.Lock()
 				allDownloads = append(allDownloads, common.S3Download{
 					TargetPath:   filepath.Join(path, f),
-					SourceRegion: os.Getenv("AWS_REGION"),
-					SourceBucket: os.Getenv("OUTPUTS_S3_BUCKET"),
+					SourceRegion: t.cfg.AWS.Region,
+					SourceBucket: t.cfg.AWS.OutputsBucket,
 					SourcePath: fmt.Sprintf(
 						"testruns/%s/logs/%s",
 						tr.ID,
@@ -406,9 +405,9 @@ func (t *TestRunManager) GetLogFiles(
 	wg.Wait()
 	if len(errs) > 0 {
 		for _, e := range errs {
-			logging.Errorf("%v", e)
+			logger.Errorf("%v", e)
 		}
-		logging.Warnf(
+		logger.Warnf(
 			"%d errors occurred while copying log files",
 			len(errs),
 		)
@@ -434,9 +433,9 @@ func (t *TestRunManager) RedownloadTestOutputsFromS3(tr *common.TestRun) error {
 	downloads := make([]common.S3Download, 0)
 	prefix := fmt.Sprintf("testruns/%s/", tr.ID)
 
-	for _, bucket := range []string{os.Getenv("OUTPUTS_S3_BUCKET"), os.Getenv("BINARIES_S3_BUCKET")} {
+	for _, bucket := range []string{t.cfg.AWS.OutputsBucket, t.cfg.AWS.BinariesBucket} {
 		objects, err := t.awsm.ListObjectsInS3(
-			os.Getenv("AWS_REGION"),
+			t.cfg.AWS.Region,
 			bucket,
 			prefix,
 		)
@@ -447,14 +446,14 @@ func (t *TestRunManager) RedownloadTestOutputsFromS3(tr *common.TestRun) error {
 		for _, o := range objects {
 			downloads = append(downloads, common.S3Download{
 				Retries:      10,
-				SourceRegion: os.Getenv("AWS_REGION"),
+				SourceRegion: t.cfg.AWS.Region,
 				SourceBucket: bucket,
 				SourcePath:   o,
 				TargetPath:   filepath.Join(common.DataDir(), o),
 			})
 		}
 	}
-	logging.Infof(
+	logger.Infof(
 		"Re-downloading %d outputs from S3 for testrun %s",
 		len(downloads),
 		tr.ID,
@@ -479,8 +478,8 @@ func (t *TestRunManager) BinariesExistInS3(
 	if debug {
 		binariesInS3 = fmt.Sprintf("binaries/%s-debug.tar.gz", hash)
 	}
-	exist, err := t.awsm.FileExistsOnS3(os.Getenv("AWS_REGION"),
-		os.Getenv("BINARIES_S3_BUCKET"),
+	exist, err := t.awsm.FileExistsOnS3(t.cfg.AWS.Region,
+		t.cfg.AWS.BinariesBucket,
 		binariesInS3)
 	if !exist || err != nil {
 		return "", err
@@ -528,8 +527,8 @@ func (t *TestRunManager) UploadBinaries(
 
 	err = t.awsm.UploadToS3IfNotExists(common.S3Upload{
 		SourcePath:   sourcePath,
-		TargetRegion: os.Getenv("AWS_REGION"),
-		TargetBucket: os.Getenv("BINARIES_S3_BUCKET"),
+		TargetRegion: t.cfg.AWS.Region,
+		TargetBucket: t.cfg.AWS.BinariesBucket,
 		TargetPath:   binariesInS3,
 	})
 	t.pendingBinaryUploads.Delete(binariesInS3)
@@ -576,8 +575,8 @@ func (t *TestRunManager) UploadConfig(cfg []byte, tr *common.TestRun) error {
 
 	dl := common.S3Download{
 		TargetPath:   filepath.Join(common.DataDir(), path),
-		SourceRegion: os.Getenv("AWS_REGION"),
-		SourceBucket: os.Getenv("BINARIES_S3_BUCKET"),
+		SourceRegion: t.cfg.AWS.Region,
+		SourceBucket: t.cfg.AWS.BinariesBucket,
 		SourcePath:   path,
 		Retries:      10,
 	}
@@ -588,8 +587,8 @@ func (t *TestRunManager) UploadConfig(cfg []byte, tr *common.TestRun) error {
 
 	return t.awsm.UploadToS3(common.S3Upload{
 		SourcePath:   file.Name(),
-		TargetRegion: os.Getenv("AWS_REGION"),
-		TargetBucket: os.Getenv("BINARIES_S3_BUCKET"),
+		TargetRegion: t.cfg.AWS.Region,
+		TargetBucket: t.cfg.AWS.BinariesBucket,
 		TargetPath:   path,
 	})
 }
@@ -1,21 +1,284 @@
 package testruns
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/mit-dci/opencbdc-tctl/common"
 )
 
+// ValidationFailedError is returned by ScheduleTestRunSpec when the test run
+// is not valid
+type ValidationFailedError struct {
+	Errors []*common.ValidationError
+}
+
+func (v *ValidationFailedError) Error() string {
+	msgs := make([]string, len(v.Errors))
+	for i, e := range v.Errors {
+		msgs[i] = e.Message
+		if e.Field != "" {
+			msgs[i] = fmt.Sprintf("%s: %s", e.Field, e.Message)
+		}
+	}
+	return fmt.Sprintf(
+		"%d error(s) in the test run configuration: %s",
+		len(v.Errors),
+		strings.Join(msgs, "; "),
+	)
+}
+
 // ValidateTestRun validates the role composition of the test run by calling
-// the architecture-specific function and return all errors reported
+// the architecture-specific function, as well as the placement constraints of
+// the roles, and return all errors reported
 func (t *TestRunManager) ValidateTestRun(
 	tr *common.TestRun,
 ) []error {
 
-	ret := []error{}
 	t.UpdateStatus(tr, common.TestRunStatusRunning, "Validating test run")
+	return t.validateTestRun(tr)
+}
+
+// validateTestRun validates the test run without updating its status, such
+// that it can also be used for test runs that are not scheduled
+func (t *TestRunManager) validateTestRun(tr *common.TestRun) []error {
+	ret := t.validateFields(tr)
+	// The role composition checks divide by the replication factor
+	if tr.ShardReplicationFactor < 1 {
+		return ret
+	}
 	if t.Is2PC(tr.Architecture) {
-		ret = t.ValidateTestRunTwoPhase(tr)
+		ret = append(ret, t.ValidateTestRunTwoPhase(tr)...)
 	} else if t.IsAtomizer(tr.Architecture) {
-		ret = t.ValidateTestRunAtomizer(tr)
+		ret = append(ret, t.ValidateTestRunAtomizer(tr)...)
+	} else if t.IsPhaseTwo(tr.Architecture) {
+		ret = append(ret, t.ValidateTestRunPhaseTwo(tr)...)
 	}
+	ret = append(ret, t.ValidatePlacement(tr)...)
 	return ret
 }
+
+// ValidateTestRunSpec validates a test run before it is scheduled: the
+// sweep, the dependencies and each of the runs it expands to, and returns
+// the errors found
+func (t *TestRunManager) ValidateTestRunSpec(
+	spec *common.TestRun,
+) []*common.ValidationError {
+	_, tr, err := common.GetTestRunCopy(spec)
+	if err != nil {
+		return common.ValidationErrors([]error{err})
+	}
+	errs := t.validateSpec(tr)
+	// The sweep cannot be expanded if it is not valid
+	if len(errs) > 0 {
+		return common.ValidationErrors(errs)
+	}
+	applySpecDefaults(tr)
+	for _, run := range common.ExpandSweepRun(tr, "") {
+		applyRunDefaults(run)
+		errs = append(errs, t.validateTestRun(run)...)
+	}
+	return common.ValidationErrors(errs)
+}
+
+// validateSpec validates the fields of a test run specification that apply
+// to the sweep as a whole
+func (t *TestRunManager) validateSpec(tr *common.TestRun) []error {
+	errs := []error{}
+	if err := t.ValidateDependencies(tr); err != nil {
+		errs = append(errs, common.NewValidationError(
+			"dependencies",
+			common.ValidationCodeDependency,
+			"%v",
+			err,
+		))
+	}
+	if tr.Repeat < 0 {
+		errs = append(errs, common.NewValidationError(
+			"repeat",
+			common.ValidationCodeOutOfRange,
+			"the number of repetitions cannot be negative",
+		))
+	}
+
+	switch tr.Sweep {
+	case "", "peak":
+	case "parameter":
+		if tr.SweepParameter == "" {
+			errs = append(errs, common.NewValidationError(
+				"sweepParameterParam",
+				common.ValidationCodeRequired,
+				"a parameter sweep needs a parameter",
+			))
+		}
+		if tr.SweepParameterIncrement <= 0 {
+			errs = append(errs, common.NewValidationError(
+				"sweepParameterIncrement",
+				common.ValidationCodeOutOfRange,
+				"the increment of a parameter sweep should be positive",
+			))
+		}
+		if tr.SweepParameterStop < tr.SweepParameterStart {
+			errs = append(errs, common.NewValidationError(
+				"sweepParameterStop",
+				common.ValidationCodeOutOfRange,
+				"the sweep stops [%g] before it starts [%g]",
+				tr.SweepParameterStop,
+				tr.SweepParameterStart,
+			))
+		}
+	case "time":
+		if tr.SweepTimeRuns < 1 {
+			errs = append(errs, common.NewValidationError(
+				"sweepTimeRuns",
+				common.ValidationCodeOutOfRange,
+				"a time sweep needs at least 1 run",
+			))
+		}
+	case "roles":
+		if tr.SweepRoleRuns < 1 {
+			errs = append(errs, common.NewValidationError(
+				"sweepRoleRuns",
+				common.ValidationCodeOutOfRange,
+				"a role sweep needs at least 1 run",
+			))
+		}
+		if len(tr.SweepRoles) == 0 {
+			errs = append(errs, common.NewValidationError(
+				"sweepRoles",
+				common.ValidationCodeRequired,
+				"a role sweep needs roles to add",
+			))
+		}
+	default:
+		errs = append(errs, common.NewValidationError(
+			"sweep",
+			common.ValidationCodeOutOfRange,
+			"unknown sweep type %s",
+			tr.Sweep,
+		))
+	}
+	return errs
+}
+
+// validateFields validates the fields of a test run that do not depend on
+// the architecture
+func (t *TestRunManager) validateFields(tr *common.TestRun) []error {
+	errs := []error{}
+	if !t.Is2PC(tr.Architecture) && !t.IsAtomizer(tr.Architecture) &&
+		!t.IsPhaseTwo(tr.Architecture) {
+		errs = append(errs, common.NewValidationError(
+			"architectureID",
+			common.ValidationCodeUnknownArchitecture,
+			"unknown architecture %s",
+			tr.Architecture,
+		))
+	}
+	if tr.ShardReplicationFactor < 1 {
+		errs = append(errs, common.NewValidationError(
+			"shardReplicationFactor",
+			common.ValidationCodeOutOfRange,
+			"the shard replication factor should be at least 1",
+		))
+	}
+	if tr.SampleCount < 0 {
+		errs = append(errs, common.NewValidationError(
+			"sampleCount",
+			common.ValidationCodeOutOfRange,
+			"the sample count cannot be negative",
+		))
+	}
+	if tr.MaxRetries < 0 {
+		errs = append(errs, common.NewValidationError(
+			"maxRetries",
+			common.ValidationCodeOutOfRange,
+			"the maximum number of retries cannot be negative",
+		))
+	}
+	rates := []struct {
+		field string
+		value float64
+	}{
+		{"invalidTxRate", tr.InvalidTxRate},
+		{"fixedTxRate", tr.FixedTxRate},
+		{"contentionRate", tr.ContentionRate},
+	}
+	for _, r := range rates {
+		if r.value < 0 || r.value > 1 {
+			errs = append(errs, common.NewValidationError(
+				r.field,
+				common.ValidationCodeOutOfRange,
+				"the rate [%g] should be between 0 and 1",
+				r.value,
+			))
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, r := range tr.Roles {
+		if _, ok := roleBinaries[r.Role]; !ok {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "role"),
+				common.ValidationCodeUnknownReference,
+				"unknown role %s",
+				r.Role,
+			))
+		}
+		if seen[roleID(r)] {
+			errs = append(errs, common.NewValidationError(
+				common.RoleField(i, "roleIdx"),
+				common.ValidationCodeDuplicate,
+				"role %s appears more than once",
+				roleName(r),
+			))
+		}
+		seen[roleID(r)] = true
+		if r.AwsLaunchTemplateID != "" {
+			if _, err := t.prov.Template(r.AwsLaunchTemplateID); err != nil {
+				errs = append(errs, common.NewValidationError(
+					common.RoleField(i, "awsLaunchTemplateID"),
+					common.ValidationCodeUnknownReference,
+					"role %s uses machine template %s, which is not available",
+					roleName(r),
+					r.AwsLaunchTemplateID,
+				))
+			}
+		}
+	}
+	errs = append(errs, t.validateGuards(tr)...)
+	return errs
+}
+
+// minRoles returns an error if there are less than n roles
+func minRoles(roles []*common.TestRunRole, n int, name string) []error {
+	if len(roles) >= n {
+		return nil
+	}
+	return []error{common.NewValidationError(
+		"roles",
+		common.ValidationCodeMinRoles,
+		"the system needs at least %d %s",
+		n,
+		name,
+	)}
+}
+
+// replicationMultiple returns an error if the number of roles is not a
+// multiple of the shard replication factor
+func replicationMultiple(
+	tr *common.TestRun,
+	roles []*common.TestRunRole,
+	name string,
+) []error {
+	if len(roles)%tr.ShardReplicationFactor == 0 {
+		return nil
+	}
+	return []error{common.NewValidationError(
+		"shardReplicationFactor",
+		common.ValidationCodeNotMultiple,
+		"number of %s [%d] should be a multiple of replication factor [%d]",
+		name,
+		len(roles),
+		tr.ShardReplicationFactor,
+	)}
+}
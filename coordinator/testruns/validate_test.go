@@ -0,0 +1,20 @@
+package testruns
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mit-dci/opencbdc-tctl/common"
+)
+
+func TestScheduleInvalidTestRunSpec(t *testing.T) {
+	m, _ := newFakeManager(t)
+	_, ids, err := m.ScheduleTestRunSpec(&common.TestRun{Repeat: -1}, "")
+	var v *ValidationFailedError
+	if !errors.As(err, &v) || len(v.Errors) == 0 {
+		t.Fatalf("ScheduleTestRunSpec() error = %v, want validation errors", err)
+	}
+	if len(ids) > 0 || len(m.GetTestRuns()) > 0 {
+		t.Errorf("scheduled %v for an invalid test run", ids)
+	}
+}
@@ -0,0 +1,79 @@
+import React from "react";
+import * as moment from "moment";
+import * as numeral from "numeral";
+import { CCard, CCardHeader, CCardBody, CCol, CRow } from "@coreui/react";
+import { CChartLine } from "@coreui/react-chartjs";
+
+const chartOptions = (label) => ({
+  animation: false,
+  maintainAspectRatio: false,
+  legend: { display: true },
+  elements: { point: { radius: 0 } },
+  scales: {
+    yAxes: [{ ticks: { beginAtZero: true }, scaleLabel: { display: true, labelString: label } }],
+  },
+});
+
+const LiveResults = (props) => {
+  const points = props.points || [];
+  if (points.length === 0) {
+    return null;
+  }
+
+  const labels = points.map((p) => moment.unix(p.time).format("LTS"));
+  const last = points.length > 1 ? points[points.length - 2] : points[0];
+  return (
+    <CCard>
+      <CCardHeader>
+        <b>Live results</b> (last complete second: {numeral(last.throughput).format("0,0")} TX/s,
+        p99 latency {numeral(last.latencyP99).format("0.000")}s)
+      </CCardHeader>
+      <CCardBody>
+        <CRow>
+          <CCol xs={6} style={{ height: "250px" }}>
+            <CChartLine
+              labels={labels}
+              datasets={[
+                {
+                  label: "Throughput",
+                  borderColor: "#321fdb",
+                  backgroundColor: "transparent",
+                  data: points.map((p) => p.throughput),
+                },
+              ]}
+              options={chartOptions("TX/s")}
+            />
+          </CCol>
+          <CCol xs={6} style={{ height: "250px" }}>
+            <CChartLine
+              labels={labels}
+              datasets={[
+                {
+                  label: "p50",
+                  borderColor: "#2eb85c",
+                  backgroundColor: "transparent",
+                  data: points.map((p) => p.latencyP50),
+                },
+                {
+                  label: "p99",
+                  borderColor: "#f9b115",
+                  backgroundColor: "transparent",
+                  data: points.map((p) => p.latencyP99),
+                },
+                {
+                  label: "p99.9",
+                  borderColor: "#e55353",
+                  backgroundColor: "transparent",
+                  data: points.map((p) => p.latencyP999),
+                },
+              ]}
+              options={chartOptions("Latency (s)")}
+            />
+          </CCol>
+        </CRow>
+      </CCardBody>
+    </CCard>
+  );
+};
+
+export default LiveResults;
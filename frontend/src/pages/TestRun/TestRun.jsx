@@ -20,6 +20,7 @@ import { useHistory, useParams } from "react-router-dom";
 import CommandOutput from "../../components/CommandOutput";
 import "./TestRun.css";
 import TestResult from "../../components/TestResult";
+import LiveResults from "../../components/LiveResults";
 import User from "../../components/User";
 import { useDispatch, useSelector } from 'react-redux';
 import {loadTestRunDetails, retrySpawning, terminateTestRun, subscribeTestRunLog, unsubscribeTestRunLog, selectTestRunRunningCommands} from '../../state/slices/testruns';
@@ -46,6 +47,7 @@ const TestRun = (props) => {
 
   const selectedArchitecture = useSelector(state => state.architectures.architectures.find((a) => a.id === (testRun?.architectureID || 'default')));
   const launchTemplates = useSelector(state => state.agents.launchTemplates);
+  const liveResults = useSelector(state => state.testruns.liveResults[params.testRunID]);
   const testRunLog = useSelector(state => {
      return (view === "log" ? state.testruns.testrunLogs.find((trl) => (trl.id === params.testRunID)) : "")
   });
@@ -94,8 +96,8 @@ const TestRun = (props) => {
       <CCol xs={12} style={{textAlign:'center', paddingBottom: '10px'}}>
         <CButtonGroup>
           <CButton color="primary" variant="outline" active={view === "details"} onClick={(e) => { setView("details") }}>Details &amp; Parameters</CButton>
-          {testRun.status === "Completed" && <CButton color="primary" variant="outline" active={view === "results"} onClick={(e) => { setView("results") }}>Results</CButton>}
-          {(testRun.status === "Completed" || testRun.status === "Failed") && <CButton color="primary" variant="outline" active={view === "performance"} onClick={(e) => { setView("performance") }}>Performance Data</CButton>}
+          {(testRun.status === "Completed" || testRun.status === "GuardTripped") && <CButton color="primary" variant="outline" active={view === "results"} onClick={(e) => { setView("results") }}>Results</CButton>}
+          {(testRun.status === "Completed" || testRun.status === "Failed" || testRun.status === "GuardTripped") && <CButton color="primary" variant="outline" active={view === "performance"} onClick={(e) => { setView("performance") }}>Performance Data</CButton>}
           <CButton color="primary" variant="outline" active={view === "roles"} onClick={(e) => { setView("roles") }}>Roles</CButton>
           <CButton color="primary" variant="outline" active={view === "commands"} onClick={(e) => { setView("commands") }}>Commands</CButton>
           <CButton color="primary" variant="outline" active={view === "log"} onClick={(e) => { setView("log") }}>Log</CButton>
@@ -227,7 +229,7 @@ const TestRun = (props) => {
                   <User thumbPrint={testRun.createdByuserThumbprint} />
                 </b>
               </CCol>
-              {testRun.status === "Completed" && (
+              {(testRun.status === "Completed" || testRun.status === "GuardTripped") && (
                 <CCol xs={{ size: 2, offset: 2 }}>
                   <CButton
                     block
@@ -241,6 +243,93 @@ const TestRun = (props) => {
                 </CCol>
               )}
             </CRow>
+            {testRun.status === "Queued" && testRun.scheduleReason && (
+              <CRow>
+                <CCol xs={2}>Scheduling:</CCol>
+                <CCol xs={10}>
+                  <b>{testRun.scheduleReason}</b>
+                </CCol>
+              </CRow>
+            )}
+            {testRun.dependencies && testRun.dependencies.length > 0 && (
+              <CRow>
+                <CCol xs={2}>Depends on:</CCol>
+                <CCol xs={10}>
+                  {testRun.dependencies.map((d) => (
+                    <div key={d.testRunID}>
+                      <CButton
+                        size="sm"
+                        color="link"
+                        onClick={(e) => {
+                          history.push(`/testrun/${d.testRunID}`);
+                        }}
+                      >
+                        {d.testRunID}
+                      </CButton>{" "}
+                      {d.condition}
+                      {d.predicate &&
+                        ` (${d.predicate.metric} ${d.predicate.operator} ${d.predicate.value})`}
+                    </div>
+                  ))}
+                </CCol>
+              </CRow>
+            )}
+            {testRun.bisectID && (
+              <CRow>
+                <CCol xs={2}>Bisection:</CCol>
+                <CCol xs={10}>{testRun.bisectID}</CCol>
+              </CRow>
+            )}
+            {testRun.parentID && (
+              <CRow>
+                <CCol xs={2}>Cloned from:</CCol>
+                <CCol xs={10}>
+                  <CButton
+                    size="sm"
+                    color="link"
+                    onClick={(e) => {
+                      history.push(`/testrun/${testRun.parentID}`);
+                    }}
+                  >
+                    {testRun.parentID}
+                  </CButton>
+                </CCol>
+              </CRow>
+            )}
+            {testRun.parentID && testRun.parentDiff && (
+              <CRow>
+                <CCol xs={2}>Changes:</CCol>
+                <CCol xs={10}>
+                  {testRun.parentDiff.length === 0 && <i>None</i>}
+                  {testRun.parentDiff.map((c) => (
+                    <div key={c.field}>
+                      <b>{c.field}</b>: {JSON.stringify(c.from)} &rarr;{" "}
+                      {JSON.stringify(c.to)}
+                    </div>
+                  ))}
+                </CCol>
+              </CRow>
+            )}
+            {testRun.regression && (
+              <CRow>
+                <CCol xs={2}>Regression check:</CCol>
+                <CCol xs={10}>
+                  <b>{testRun.regression.status}</b>
+                  {testRun.regression.baselineCommit &&
+                    ` compared to commit ${testRun.regression.baselineCommit.substr(0, 7)} (${testRun.regression.baselineRunIDs.length} vs ${testRun.regression.runIDs.length} runs)`}
+                  {testRun.regression.metrics.map((m) => (
+                    <div key={m.metric}>
+                      {m.metric}: {m.baseline.toFixed(2)} &rarr;{" "}
+                      {m.current.toFixed(2)} (
+                      {m.changePercent > 0 ? "+" : ""}
+                      {m.changePercent.toFixed(1)}%
+                      {m.tested && `, p=${m.pValue.toFixed(3)}`})
+                      {m.regressed && <b> worse than threshold</b>}
+                    </div>
+                  ))}
+                </CCol>
+              </CRow>
+            )}
           </CCardBody>
         </CCard>
       </CCol>}
@@ -267,6 +356,11 @@ const TestRun = (props) => {
           </CCard>
         </CCol>
       )}
+      {view === "details" && testRun.status === "Running" && liveResults && (
+        <CCol xs={12}>
+          <LiveResults points={liveResults} />
+        </CCol>
+      )}
       {view === "details" &&  <CCol xs={12}>
            <TestRunParameters testRunFields={testRunFields} testRun={testRun} commit={commit} selectedArchitecture={selectedArchitecture} />
       </CCol>}
@@ -105,7 +105,7 @@ const TestRuns = (props) => {
                   Resume sweep
                 </CDropdownItem>
               )}
-            {(r.status === "Failed" || r.status === "Aborted") &&
+            {(r.status === "Failed" || r.status === "Aborted" || r.status === "GuardTripped") &&
               r.sweepID &&
               r.sweepOneAtATime === true &&
               r.sweepID !== "" && (
@@ -223,7 +223,7 @@ const TestRuns = (props) => {
             textOverflow: "ellipsis",
           }}
         >
-          {r.details}
+          {r.scheduleReason || r.details}
         </div>
       </td>
     );
@@ -9,6 +9,7 @@ export const TestController = {
     TestRunAdded: 'TEST_CONTROLLER::TESTRUN_ADDED',
     TestRunChanged: 'TEST_CONTROLLER::TESTRUN_CHANGED',
     TestRunLogAppended: 'TEST_CONTROLLER::TESTRUN_LOGAPPENDED',
+    TestRunLiveResults: 'TEST_CONTROLLER::TESTRUN_LIVE_RESULTS',
     TestRunSweepChanged: 'TEST_CONTROLLER::SWEEP_CHANGED',
     AgentAdded: 'TEST_CONTROLLER::AGENT_ADDED',
     AgentCountUpdated: 'TEST_CONTROLLER::AGENT_COUNT_UPDATED',
@@ -69,10 +69,36 @@ const websocketMiddleware = storeAPI => next => action => {
                         // completed commands.
                         storeAPI.dispatch(loadTestRunDetails(msg.payload.testRunID));
                     }
+                    if (msg.payload.status === "GuardTripped") {
+                        toast.warning(`Test run ${msg.payload.testRunID} aborted early: ${msg.payload.details}`);
+                        storeAPI.dispatch(loadTestRunDetails(msg.payload.testRunID));
+                    }
                     if (msg.payload.status === "Failed") {
                         toast.error(`Test run ${msg.payload.testRunID} failed : ${msg.payload.details}`);
                     }
                     break;
+                case "testRunScheduleReasonChanged":
+                    storeAPI.dispatch({
+                        type: TestController.TestRunChanged, payload: {
+                            id: msg.payload.testRunID,
+                            scheduleReason: msg.payload.reason,
+                        }
+                    });
+                    break;
+                case "testRunRegressionVerdict":
+                    storeAPI.dispatch({
+                        type: TestController.TestRunChanged, payload: {
+                            id: msg.payload.testRunID,
+                            regression: msg.payload.verdict,
+                        }
+                    });
+                    break;
+                case "budgetWarning":
+                    storeAPI.dispatch({
+                        type: TestController.Toast.Warning,
+                        payload: `Spending of $${msg.payload.spent.toFixed(2)} reached ${msg.payload.thresholdPercent}% of the ${msg.payload.scope === "user" ? "user's" : "monthly"} budget of $${msg.payload.limit.toFixed(2)}`
+                    });
+                    break;
                 case "testRunTrimParametersChange":
                     storeAPI.dispatch({
                         type: TestController.TestRunChanged, payload: {
@@ -118,6 +144,9 @@ const websocketMiddleware = storeAPI => next => action => {
                 case "testRunLogAppended":
                     storeAPI.dispatch({ type: TestController.TestRunLogAppended, payload: msg.payload });
                     break;
+                case "testRunLiveResults":
+                    storeAPI.dispatch({ type: TestController.TestRunLiveResults, payload: msg.payload });
+                    break;
                 case "redownloadComplete":
                     if(msg.payload.success) {
                         storeAPI.dispatch({ type: TestController.Toast.Success, payload: `Redownload of testrun ${msg.payload.testRunID} outputs succeeded` });
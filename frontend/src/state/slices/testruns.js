@@ -8,6 +8,7 @@ const initialState = {
     testruns: [],
     testrunFields: [],
     testrunLogs: [],
+    liveResults: {},
     architectures: [],
     activeCommandLog: {
         id: '',
@@ -418,6 +419,18 @@ export const reducer = (state = initialState, action) => {
                     newLog
                 ]
             }
+        case TestController.TestRunLiveResults:
+            // Updated seconds replace the ones we have, keep them sorted
+            var points = {};
+            (state.liveResults[action.payload.testRunID] || []).forEach(p => { points[p.time] = p; });
+            action.payload.points.forEach(p => { points[p.time] = p; });
+            return {
+                ...state,
+                liveResults: {
+                    ...state.liveResults,
+                    [action.payload.testRunID]: Object.values(points).sort((a, b) => a.time - b.time),
+                }
+            }
         default:
             return state;
     }
@@ -930,7 +943,7 @@ export const selectSweeps = createSelector(state => state.testruns.sweeps, state
 export const selectActiveTestRunsList = createSelector(state => state.testruns.testruns, state => state.architectures.architectures, state => state.users.users, (testruns, architectures, users) => testruns.filter((tr) => ["Running"].indexOf(tr.status) > -1).map(mapListFields(architectures, users)).sort((a, b) => b.created.valueOf() - a.created.valueOf()));
 export const selectQueuedTestRunsList = createSelector(state => state.testruns.testruns, state => state.architectures.architectures, state => state.users.users, (testruns, architectures, users) => testruns.filter((tr) => ["Queued"].indexOf(tr.status) > -1).map(mapListFields(architectures, users)).sort((a, b) => b.created.valueOf() - a.created.valueOf()));
 export const selectCompletedTestRunsList = createSelector(state => state.testruns.testruns, state => state.architectures.architectures, state => state.users.users, (testruns, architectures, users) => testruns.filter((tr) => tr.status === "Completed").map(mapListFields(architectures, users)).sort((a, b) => b.completed.valueOf() - a.completed.valueOf()));
-export const selectFailedTestRunsList = createSelector(state => state.testruns.testruns, state => state.architectures.architectures, state => state.users.users, (testruns, architectures, users) => testruns.filter((tr) => ["Canceled", "Aborted", "Failed", "Interrupted"].indexOf(tr.status) > -1).map(mapListFields(architectures, users)).sort((a, b) => b.completed.valueOf() - a.completed.valueOf()));
+export const selectFailedTestRunsList = createSelector(state => state.testruns.testruns, state => state.architectures.architectures, state => state.users.users, (testruns, architectures, users) => testruns.filter((tr) => ["Canceled", "Aborted", "GuardTripped", "Failed", "Interrupted"].indexOf(tr.status) > -1).map(mapListFields(architectures, users)).sort((a, b) => b.completed.valueOf() - a.completed.valueOf()));
 export const selectQueuedTestRunCount = createSelector(state => state.testruns.testruns, testruns => testruns.filter(tr => tr.state === 'Queued').length);
 export const selectTestRunLast24hCount = createSelector(state => state.testruns.testruns, testruns => testruns.filter(tr => new Date(tr.completed).valueOf() >= new Date().valueOf() - 86400000).length);
 export const selectNewestTestRun = createSelector(state => state.testruns.testruns, (testruns) => testruns.filter((tr) => tr.status === "Completed").sort((a, b) => new Date(b.completed).valueOf() - new Date(a.completed).valueOf())[0] || {});
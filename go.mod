@@ -20,4 +20,5 @@ require (
 	github.com/kelindar/binary v1.0.9
 	github.com/rs/cors v1.7.0
 	golang.org/x/net v0.0.0-20210510120150-4163338589ed // indirect
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -12,12 +12,20 @@ package logging
 // Warn  -> You should probably take a look at this
 // Error -> Something failed but I'm not quitting
 // Fatal -> Bye
+//
+// The level can be overridden per component (see Logger and
+// SetComponentLogLevel). Log lines are written as human-readable text by
+// default, or as one JSON object per line when JSON output is enabled.
 
 import (
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type LogLevel int
@@ -31,8 +39,85 @@ const (
 
 var logLevel = LogLevelError // the default
 
+// componentLevels holds the per-component overrides of logLevel, guarded by
+// levelsLock since they can be changed at runtime through the API
+var componentLevels = map[string]LogLevel{}
+var levelsLock sync.RWMutex
+
+// jsonOutput is 1 when log lines are written as JSON objects in stead of
+// human-readable text. It is accessed atomically since every logger reads it
+var jsonOutput int32
+
+// std is the logger used by the package-level logging functions
+var std = &Logger{}
+
 func SetLogLevel(newLevel int) {
+	levelsLock.Lock()
 	logLevel = LogLevel(newLevel)
+	levelsLock.Unlock()
+}
+
+// GetLogLevel returns the default log level that applies to components without
+// an override
+func GetLogLevel() LogLevel {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+	return logLevel
+}
+
+// SetComponentLogLevel overrides the log level for a single component
+func SetComponentLogLevel(component string, newLevel LogLevel) {
+	levelsLock.Lock()
+	componentLevels[component] = newLevel
+	levelsLock.Unlock()
+}
+
+// ResetComponentLogLevel removes the override for a component, making it fall
+// back to the default log level
+func ResetComponentLogLevel(component string) {
+	levelsLock.Lock()
+	delete(componentLevels, component)
+	levelsLock.Unlock()
+}
+
+// ComponentLogLevel returns the effective log level for a component
+func ComponentLogLevel(component string) LogLevel {
+	levelsLock.RLock()
+	defer levelsLock.RUnlock()
+	if lvl, ok := componentLevels[component]; ok {
+		return lvl
+	}
+	return logLevel
+}
+
+// ComponentLogLevels returns the effective log level of every component that
+// has created a logger or has an override configured
+func ComponentLogLevels() map[string]LogLevel {
+	ret := map[string]LogLevel{}
+	for _, c := range Components() {
+		ret[c] = ComponentLogLevel(c)
+	}
+	levelsLock.RLock()
+	for c, lvl := range componentLevels {
+		ret[c] = lvl
+	}
+	levelsLock.RUnlock()
+	return ret
+}
+
+// SetJSONOutput switches between human-readable (false) and JSON (true) log
+// output
+func SetJSONOutput(enabled bool) {
+	v := int32(0)
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&jsonOutput, v)
+}
+
+// JSONOutput returns true if log lines are written as JSON
+func JSONOutput() bool {
+	return atomic.LoadInt32(&jsonOutput) == 1
 }
 
 func SetLogFile(logFile io.Writer) {
@@ -41,6 +126,48 @@ func SetLogFile(logFile io.Writer) {
 	log.SetOutput(logOutput)
 }
 
+// String returns the name of the log level as used in the API and in JSON
+// output
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelError:
+		return "error"
+	case LogLevelWarning:
+		return "warn"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelDebug:
+		return "debug"
+	}
+	return strconv.Itoa(int(l))
+}
+
+// MarshalText implements encoding.TextMarshaler such that log levels are
+// rendered by name in JSON
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLogLevel translates a level name (error, warn, info, debug) or its
+// numeric value into a LogLevel
+func ParseLogLevel(s string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error", "err":
+		return LogLevelError, nil
+	case "warn", "warning":
+		return LogLevelWarning, nil
+	case "info":
+		return LogLevelInfo, nil
+	case "debug":
+		return LogLevelDebug, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < int(LogLevelError) || i > int(LogLevelDebug) {
+		return LogLevelError, fmt.Errorf("unknown log level [%s]", s)
+	}
+	return LogLevel(i), nil
+}
+
 func getPrefix(level string) string {
 	return fmt.Sprintf("[%s]", level)
 }
@@ -58,81 +185,49 @@ func Fatal(args ...interface{}) {
 }
 
 func Debugf(format string, args ...interface{}) {
-	if logLevel >= LogLevelDebug {
-		log.Printf(fmt.Sprintf("%s %s", getPrefix("DEBUG"), format), args...)
-	}
+	std.Debugf(format, args...)
 }
 
 func Infof(format string, args ...interface{}) {
-	if logLevel >= LogLevelInfo {
-		log.Printf(fmt.Sprintf("%s %s", getPrefix("INFO"), format), args...)
-	}
+	std.Infof(format, args...)
 }
 
 func Warnf(format string, args ...interface{}) {
-	if logLevel >= LogLevelWarning {
-		log.Printf(fmt.Sprintf("%s %s", getPrefix("WARN"), format), args...)
-	}
+	std.Warnf(format, args...)
 }
 
 func Errorf(format string, args ...interface{}) {
-	if logLevel >= LogLevelError {
-		log.Printf(fmt.Sprintf("%s %s", getPrefix("ERROR"), format), args...)
-	}
+	std.Errorf(format, args...)
 }
 
 func Debugln(args ...interface{}) {
-	if logLevel >= LogLevelDebug {
-		args = append([]interface{}{getPrefix("DEBUG")}, args...)
-		log.Println(args...)
-	}
+	std.Debugln(args...)
 }
 
 func Infoln(args ...interface{}) {
-	if logLevel >= LogLevelInfo {
-		args = append([]interface{}{getPrefix("INFO")}, args...)
-		log.Println(args...)
-	}
+	std.Infoln(args...)
 }
 
 func Warnln(args ...interface{}) {
-	if logLevel >= LogLevelWarning {
-		args = append([]interface{}{getPrefix("WARN")}, args...)
-		log.Println(args...)
-	}
+	std.Warnln(args...)
 }
 
 func Errorln(args ...interface{}) {
-	if logLevel >= LogLevelError {
-		args = append([]interface{}{getPrefix("ERROR")}, args...)
-		log.Println(args...)
-	}
+	std.Errorln(args...)
 }
 
 func Debug(args ...interface{}) {
-	if logLevel >= LogLevelDebug {
-		args = append([]interface{}{getPrefix("DEBUG")}, args...)
-		log.Print(args...)
-	}
+	std.Debug(args...)
 }
 
 func Info(args ...interface{}) {
-	if logLevel >= LogLevelInfo {
-		args = append([]interface{}{getPrefix("INFO")}, args...)
-		log.Print(args...)
-	}
+	std.Info(args...)
 }
 
 func Warn(args ...interface{}) {
-	if logLevel >= LogLevelWarning {
-		args = append([]interface{}{getPrefix("WARN")}, args...)
-		log.Print(args...)
-	}
+	std.Warn(args...)
 }
 
 func Error(args ...interface{}) {
-	if logLevel >= LogLevelError {
-		args = append([]interface{}{getPrefix("ERROR")}, args...)
-		log.Print(args...)
-	}
+	std.Error(args...)
 }
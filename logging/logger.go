@@ -0,0 +1,214 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields holds the key/value pairs that are attached to a log line, such as
+// the test run ID, agent ID, command ID, role or phase the line relates to
+type Fields map[string]interface{}
+
+// Entry is a single structured log line. It is what gets written when JSON
+// output is enabled, and it is the format in which per-test-run structured
+// logs are persisted and queried
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Level     string    `json:"level"`
+	Component string    `json:"component,omitempty"`
+	Message   string    `json:"msg"`
+	Fields    Fields    `json:"fields,omitempty"`
+}
+
+// Matches returns true if the entry matches all of the given filters. The
+// keys "level" and "component" match the entry's level and component, all
+// other keys are matched against the entry's fields by their string value
+func (e Entry) Matches(filter map[string]string) bool {
+	for k, v := range filter {
+		switch k {
+		case "level":
+			if !strings.EqualFold(e.Level, v) {
+				return false
+			}
+		case "component":
+			if e.Component != v {
+				return false
+			}
+		default:
+			f, ok := e.Fields[k]
+			if !ok || fmt.Sprint(f) != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Text renders the entry's message followed by its fields in key=value
+// format, sorted by key
+func (e Entry) Text() string {
+	var sb strings.Builder
+	if e.Component != "" {
+		fmt.Fprintf(&sb, "[%s] ", e.Component)
+	}
+	sb.WriteString(e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&sb, " %s=%v", k, e.Fields[k])
+	}
+	return sb.String()
+}
+
+// components keeps track of the names of all components that created a
+// logger, such that they can be listed (and their levels changed) through the
+// API
+var components = map[string]bool{}
+var componentsLock sync.Mutex
+
+// Components returns the names of all components that created a logger
+func Components() []string {
+	componentsLock.Lock()
+	defer componentsLock.Unlock()
+	ret := make([]string, 0, len(components))
+	for c := range components {
+		ret = append(ret, c)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Logger writes log lines on behalf of a component, decorated with a set of
+// fields. The level at which a Logger writes can be controlled per component
+// using SetComponentLogLevel
+type Logger struct {
+	component string
+	fields    Fields
+}
+
+// NewLogger creates a logger for the given component. Packages normally
+// create one at package level and derive loggers with additional fields from
+// it using WithFields
+func NewLogger(component string) *Logger {
+	componentsLock.Lock()
+	components[component] = true
+	componentsLock.Unlock()
+	return &Logger{component: component}
+}
+
+// WithFields returns a copy of the logger that adds the given fields to every
+// line it writes, on top of the fields the logger already had
+func (l *Logger) WithFields(f Fields) *Logger {
+	newFields := make(Fields, len(l.fields)+len(f))
+	for k, v := range l.fields {
+		newFields[k] = v
+	}
+	for k, v := range f {
+		newFields[k] = v
+	}
+	return &Logger{component: l.component, fields: newFields}
+}
+
+// WithField returns a copy of the logger that adds a single field to every
+// line it writes
+func (l *Logger) WithField(key string, value interface{}) *Logger {
+	return l.WithFields(Fields{key: value})
+}
+
+// Enabled returns true if lines at the given level are written for this
+// logger's component
+func (l *Logger) Enabled(level LogLevel) bool {
+	return ComponentLogLevel(l.component) >= level
+}
+
+// Entry builds the structured entry for a line written by this logger at the
+// given level
+func (l *Logger) Entry(level LogLevel, msg string) Entry {
+	return Entry{
+		Time:      time.Now(),
+		Level:     level.String(),
+		Component: l.component,
+		Message:   msg,
+		Fields:    l.fields,
+	}
+}
+
+// output writes the message at the given level if that level is enabled for
+// the logger's component
+func (l *Logger) output(level LogLevel, msg string) {
+	if !l.Enabled(level) {
+		return
+	}
+	e := l.Entry(level, msg)
+	if JSONOutput() {
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("%s %s", getPrefix("ERROR"), err.Error())
+			return
+		}
+		_, _ = log.Writer().Write(append(b, '\n'))
+		return
+	}
+	log.Printf("%s %s", getPrefix(strings.ToUpper(level.String())), e.Text())
+}
+
+// Logf writes a line at the given level
+func (l *Logger) Logf(level LogLevel, format string, args ...interface{}) {
+	l.output(level, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.output(LogLevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.output(LogLevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.output(LogLevelWarning, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.output(LogLevelError, fmt.Sprintf(format, args...))
+}
+
+func (l *Logger) Debugln(args ...interface{}) {
+	l.output(LogLevelDebug, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (l *Logger) Infoln(args ...interface{}) {
+	l.output(LogLevelInfo, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (l *Logger) Warnln(args ...interface{}) {
+	l.output(LogLevelWarning, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (l *Logger) Errorln(args ...interface{}) {
+	l.output(LogLevelError, strings.TrimSuffix(fmt.Sprintln(args...), "\n"))
+}
+
+func (l *Logger) Debug(args ...interface{}) {
+	l.output(LogLevelDebug, fmt.Sprint(args...))
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	l.output(LogLevelInfo, fmt.Sprint(args...))
+}
+
+func (l *Logger) Warn(args ...interface{}) {
+	l.output(LogLevelWarning, fmt.Sprint(args...))
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	l.output(LogLevelError, fmt.Sprint(args...))
+}
@@ -9,6 +9,8 @@ type HelloMsg struct {
 	Header       MsgHeader
 	SystemInfo   common.AgentSystemInfo
 	AgentVersion string
+	// Labels the agent was started with, which roles can require
+	Labels map[string]string
 }
 
 // HelloResponseMsg is sent from controller to agent in response to HelloMsg and
@@ -141,6 +143,10 @@ type ExecuteCommandRequestMsg struct {
 	S3OutputBucket string
 	// Gather bandwidth stats
 	RecordNetworkTraffic bool
+	// Sample files, relative to the working directory, that the agent tails
+	// while the command runs to report LiveSamplesMsg updates. Each line has
+	// the time a transaction completed and its latency, in nanoseconds
+	LiveSampleFiles []string
 }
 
 // ExecuteCommandResponseMsg is sent by the agent to the controller in response
@@ -254,3 +260,27 @@ type UploadFileToS3ResponseMsg struct {
 	Header  MsgHeader
 	Success bool
 }
+
+// SampleWindow summarizes the samples of a second
+type SampleWindow struct {
+	// Unix time of the second
+	Second int64
+	// The number of transactions completed in the second
+	Count int64
+	// The sum of their latencies in nanoseconds
+	LatencySum int64
+	// The number of transactions per latency bucket, see common.LatencyBucket
+	LatencyHistogram map[int32]int64
+}
+
+// LiveSamplesMsg is sent by the agent to the controller periodically while a
+// command with LiveSampleFiles runs, with the windows of the samples appended
+// to the files since the previous update. A window of the same second can be
+// reported again in the next update if the samples of the second were not
+// complete yet, in which case the counts should be added up
+type LiveSamplesMsg struct {
+	Header MsgHeader
+	// The ID of the command that writes the samples
+	CommandID []byte
+	Windows   []SampleWindow
+}
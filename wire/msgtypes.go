@@ -29,6 +29,7 @@ var TypeToMessageTypeMap = map[reflect.Type]MessageType{
 	reflect.TypeOf(&RenameFileResponseMsg{}):        MessageType(23),
 	reflect.TypeOf(&UploadFileToS3RequestMsg{}):     MessageType(24),
 	reflect.TypeOf(&UploadFileToS3ResponseMsg{}):    MessageType(25),
+	reflect.TypeOf(&LiveSamplesMsg{}):               MessageType(26),
 }
 
 // MessageTypeToTypeMap is the reverse of TypeToMessageTypeMap to translate in
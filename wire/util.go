@@ -25,7 +25,7 @@ func ReceiveWithTimeout(rc chan Msg, timeout time.Duration) (Msg, error) {
 		logging.Debugf("Received message from channel %v", rc)
 		break
 	case <-time.After(timeout):
-		logging.Warn(
+		logging.Warnf(
 			"Nothing received from channel %v within timeout %v",
 			rc,
 			timeout,